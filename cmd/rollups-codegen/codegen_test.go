@@ -0,0 +1,89 @@
+package main
+
+import (
+  "bytes"
+  "flag"
+  "os"
+  "os/exec"
+  "path/filepath"
+  "testing"
+)
+
+var update = flag.Bool("update", false, "update the golden files of testdata")
+
+const specPath = "testdata/market.yaml"
+
+// TestGolden compares the generated code with the golden files, run
+// go test -update to write them
+func TestGolden(t *testing.T) {
+  spec, err := LoadSpec(specPath)
+  if err != nil {
+    t.Fatal(err)
+  }
+  outputs := []struct {
+    golden string
+    generate func() ([]byte, error)
+  }{
+    {"market.go.golden", func() ([]byte, error) { return GenerateGo(spec, "market.yaml") }},
+    {"service.go.golden", func() ([]byte, error) { return GenerateGoStubs(spec) }},
+    {"market.ts.golden", func() ([]byte, error) { return GenerateTs(spec, "market.yaml") }},
+  }
+  for _, output := range outputs {
+    got, err := output.generate()
+    if err != nil {
+      t.Errorf("%s: %s", output.golden, err)
+      continue
+    }
+    path := filepath.Join("testdata", output.golden)
+    if *update {
+      if err := os.WriteFile(path, got, 0644); err != nil {
+        t.Fatal(err)
+      }
+      continue
+    }
+    expected, err := os.ReadFile(path)
+    if err != nil {
+      t.Fatal(err)
+    }
+    if !bytes.Equal(got, expected) {
+      t.Errorf("%s: the generated code changed, check it and run go test -update", output.golden)
+    }
+  }
+}
+
+// TestGeneratedGo builds the routes and stubs generated by the command, in a
+// package of the module so the generated imports resolve
+func TestGeneratedGo(t *testing.T) {
+  if testing.Short() {
+    t.Skip("builds the generated package")
+  }
+  goBin, err := exec.LookPath("go")
+  if err != nil {
+    t.Skip("go command not found")
+  }
+  dir, err := os.MkdirTemp("testdata", "build")
+  if err != nil {
+    t.Fatal(err)
+  }
+  defer os.RemoveAll(dir)
+
+  stubsPath := filepath.Join(dir, "service.go")
+  if err := run(specPath, filepath.Join(dir, "routes.go"), stubsPath, filepath.Join(dir, "client", "market.ts")); err != nil {
+    t.Fatal(err)
+  }
+  out, err := exec.Command(goBin, "vet", "./" + filepath.ToSlash(dir)).CombinedOutput()
+  if err != nil {
+    t.Fatalf("the generated code doesn't build: %s\n%s", err, out)
+  }
+
+  // the stubs aren't overwritten
+  if err := os.WriteFile(stubsPath, []byte("package market\n"), 0644); err != nil {
+    t.Fatal(err)
+  }
+  if err := run(specPath, "", stubsPath, ""); err != nil {
+    t.Fatal(err)
+  }
+  if stubs, _ := os.ReadFile(stubsPath); string(stubs) != "package market\n" {
+    t.Errorf("stubs overwritten")
+  }
+}
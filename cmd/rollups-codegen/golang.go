@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"strings"
+	"text/template"
+
+	"github.com/lynoferraz/abigo"
+)
+
+var goTemplate = template.Must(template.New("go").Funcs(template.FuncMap{
+  "exported": exported,
+  "goFields": goFields,
+}).Parse(`// Code generated by rollups-codegen from {{.Source}}. DO NOT EDIT.
+
+package {{.Spec.Package}}
+
+import (
+  "context"
+{{- if .BigInt}}
+  "math/big"
+{{- end}}
+
+  hdl "github.com/prototyp3-dev/go-rollups/handler"
+  "github.com/prototyp3-dev/go-rollups/handler/abi"
+)
+
+const {{exported .Spec.Name}}Framework = "{{.Spec.Framework}}"
+{{range .Spec.Routes}}
+// {{exported .Method}}Args are the params of the {{.Method}} {{.Kind}} route
+type {{exported .Method}}Args struct {
+{{goFields .Fields}}}
+
+var {{exported .Method}}Codec = abihandler.NewHeaderStructCodec({{exported $.Spec.Name}}Framework, "{{.Method}}", {{exported .Method}}Args{})
+{{end}}
+{{- range .Spec.Notices}}
+type {{exported .Name}}Notice struct {
+{{goFields .Fields}}}
+
+var {{exported .Name}}NoticeCodec = {{if .Header}}abihandler.NewHeaderStructCodec({{exported $.Spec.Name}}Framework, "{{.Name}}", {{exported .Name}}Notice{}){{else}}abihandler.NewStructCodec({{exported .Name}}Notice{}){{end}}
+
+// Send{{exported .Name}}Notice sends the encoded notice as an output of req
+func Send{{exported .Name}}Notice(req *hdl.Request, notice {{exported .Name}}Notice) (uint64, error) {
+  payload, err := {{exported .Name}}NoticeCodec.Encode(notice)
+  if err != nil {
+    return 0, err
+  }
+  return req.Notice(payload)
+}
+{{end}}
+{{- range .Spec.Reports}}
+type {{exported .Name}}Report struct {
+{{goFields .Fields}}}
+
+var {{exported .Name}}ReportCodec = {{if .Header}}abihandler.NewHeaderStructCodec({{exported $.Spec.Name}}Framework, "{{.Name}}", {{exported .Name}}Report{}){{else}}abihandler.NewStructCodec({{exported .Name}}Report{}){{end}}
+
+// Send{{exported .Name}}Report sends the encoded report as an output of req
+func Send{{exported .Name}}Report(req *hdl.Request, report {{exported .Name}}Report) error {
+  payload, err := {{exported .Name}}ReportCodec.Encode(report)
+  if err != nil {
+    return err
+  }
+  return req.Report(payload)
+}
+{{end}}
+// {{exported .Spec.Name}}Handlers handles the routes of the {{.Spec.Framework}} framework
+type {{exported .Spec.Name}}Handlers interface {
+{{- range .Spec.Routes}}
+  {{exported .Method}}(ctx context.Context, req *hdl.Request, args {{exported .Method}}Args) error
+{{- end}}
+}
+
+// Register{{exported .Spec.Name}}Routes adds the routes of the {{.Spec.Framework}} framework to h
+func Register{{exported .Spec.Name}}Routes(h *abihandler.AbiHandler, handlers {{exported .Spec.Name}}Handlers) {
+{{- range .Spec.Routes}}
+  abihandler.Handle{{if eq .Kind "advance"}}Advance{{else}}Inspect{{end}}RouteTyped(h, {{exported .Method}}Codec, handlers.{{exported .Method}})
+{{- end}}
+}
+`))
+
+var stubsTemplate = template.Must(template.New("stubs").Funcs(template.FuncMap{
+  "exported": exported,
+}).Parse(`package {{.Spec.Package}}
+
+import (
+  "context"
+  "fmt"
+
+  hdl "github.com/prototyp3-dev/go-rollups/handler"
+)
+
+// {{exported .Spec.Name}}Service implements {{exported .Spec.Name}}Handlers
+type {{exported .Spec.Name}}Service struct {
+}
+{{range .Spec.Routes}}
+func (s *{{exported $.Spec.Name}}Service) {{exported .Method}}(ctx context.Context, req *hdl.Request, args {{exported .Method}}Args) error {
+  return fmt.Errorf("{{exported .Method}}: not implemented")
+}
+{{end}}`))
+
+type goData struct {
+  Source string
+  Spec *Spec
+  BigInt bool
+}
+
+// GenerateGo returns the typed handlers, codecs and the register function of
+// the spec routes
+func GenerateGo(spec *Spec, source string) ([]byte, error) {
+  data := goData{Source: source, Spec: spec}
+  for _, fields := range specFields(spec) {
+    for _, f := range fields {
+      if strings.Contains(goType(mustParseType(f.Type)), "*big.Int") {
+        data.BigInt = true
+      }
+    }
+  }
+  return execute(goTemplate, data)
+}
+
+// GenerateGoStubs returns a service type with a method for each route that
+// implements the generated handlers interface
+func GenerateGoStubs(spec *Spec) ([]byte, error) {
+  return execute(stubsTemplate, goData{Spec: spec})
+}
+
+func execute(t *template.Template, data goData) ([]byte, error) {
+  var b bytes.Buffer
+  if err := t.Execute(&b, data); err != nil {
+    return nil, fmt.Errorf("GenerateGo: %s", err)
+  }
+  src, err := format.Source(b.Bytes())
+  if err != nil {
+    return nil, fmt.Errorf("GenerateGo: generated invalid code: %s", err)
+  }
+  return src, nil
+}
+
+func specFields(spec *Spec) [][]Field {
+  var fields [][]Field
+  for _, r := range spec.Routes {
+    fields = append(fields, r.Fields)
+  }
+  for _, o := range append(spec.Notices, spec.Reports...) {
+    fields = append(fields, o.Fields)
+  }
+  return fields
+}
+
+// goFields returns the struct fields with their abi tags
+func goFields(fields []Field) string {
+  var b strings.Builder
+  for _, f := range fields {
+    fmt.Fprintf(&b, "  %s %s `abi:\"%s %s\"`\n", exported(f.Name), goType(mustParseType(f.Type)), f.Type, f.Name)
+  }
+  return b.String()
+}
+
+// goType returns the type of the decoded values of typ
+func goType(typ *abi.Type) string {
+  switch typ.Kind() {
+  case abi.KindSlice:
+    return "[]" + goType(typ.Elem())
+  case abi.KindArray:
+    return fmt.Sprintf("[%d]%s", typ.Size(), goType(typ.Elem()))
+  case abi.KindAddress:
+    return "abihandler.Address"
+  case abi.KindBytes:
+    return "[]byte"
+  case abi.KindFixedBytes:
+    return fmt.Sprintf("[%d]byte", typ.Size())
+  default:
+    // bool, string, uint8 to uint64, int8 to int64 and *big.Int
+    return typ.GoType().String()
+  }
+}
+
+// mustParseType parses the types already checked by the spec validation
+func mustParseType(s string) *abi.Type {
+  typ, err := parseType(s)
+  if err != nil {
+    panic(err)
+  }
+  return typ
+}
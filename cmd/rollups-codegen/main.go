@@ -0,0 +1,85 @@
+// rollups-codegen generates the typed Go handlers of the abi header routes of
+// a spec and the TypeScript (viem) encoders of its inputs and decoders of its
+// outputs.
+//
+//  rollups-codegen -spec market.yaml -go market/routes.go -stubs market/service.go -ts client/market.ts
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+var infolog = log.New(os.Stderr, "[ info ]  ", log.Lshortfile)
+
+func main() {
+  specPath := flag.String("spec", "", "route spec (.json, .yaml or .yml)")
+  goPath := flag.String("go", "", "output file of the Go routes")
+  stubsPath := flag.String("stubs", "", "output file of the Go handler stubs, not overwritten if it exists")
+  tsPath := flag.String("ts", "", "output file of the TypeScript encoders and decoders")
+  flag.Parse()
+
+  if *specPath == "" || (*goPath == "" && *stubsPath == "" && *tsPath == "") {
+    flag.Usage()
+    os.Exit(2)
+  }
+  if err := run(*specPath, *goPath, *stubsPath, *tsPath); err != nil {
+    fmt.Fprintln(os.Stderr, err)
+    os.Exit(1)
+  }
+}
+
+func run(specPath string, goPath string, stubsPath string, tsPath string) error {
+  spec, err := LoadSpec(specPath)
+  if err != nil {
+    return err
+  }
+  source := filepath.Base(specPath)
+
+  if goPath != "" {
+    src, err := GenerateGo(spec, source)
+    if err != nil {
+      return err
+    }
+    if err = writeFile(goPath, src); err != nil {
+      return err
+    }
+  }
+  if stubsPath != "" {
+    if _, err := os.Stat(stubsPath); err == nil {
+      infolog.Println("Stubs file",stubsPath,"exists, skipping")
+    } else {
+      src, err := GenerateGoStubs(spec)
+      if err != nil {
+        return err
+      }
+      if err = writeFile(stubsPath, src); err != nil {
+        return err
+      }
+    }
+  }
+  if tsPath != "" {
+    src, err := GenerateTs(spec, source)
+    if err != nil {
+      return err
+    }
+    if err = writeFile(tsPath, src); err != nil {
+      return err
+    }
+  }
+  return nil
+}
+
+func writeFile(path string, data []byte) error {
+  if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+    return fmt.Errorf("writeFile: %s", err)
+  }
+  if err := os.WriteFile(path, data, 0644); err != nil {
+    return fmt.Errorf("writeFile: %s", err)
+  }
+  infolog.Println("Generated",path)
+  return nil
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/lynoferraz/abigo"
+	"gopkg.in/yaml.v3"
+)
+
+// Spec is the route definitions of a framework, e.g.
+//
+//  package: market
+//  framework: market
+//  routes:
+//    - method: PlaceOrder
+//      kind: advance
+//      fields:
+//        - {name: token, type: address}
+//        - {name: amount, type: uint256}
+//  notices:
+//    - name: OrderPlaced
+//      header: true
+//      fields:
+//        - {name: id, type: uint64}
+type Spec struct {
+  Package string    `json:"package" yaml:"package"`
+  Framework string  `json:"framework" yaml:"framework"`
+  // Name prefixes the handlers interface and the register function, the
+  // framework by default
+  Name string       `json:"name,omitempty" yaml:"name,omitempty"`
+  Routes []Route    `json:"routes" yaml:"routes"`
+  Notices []Output  `json:"notices,omitempty" yaml:"notices,omitempty"`
+  Reports []Output  `json:"reports,omitempty" yaml:"reports,omitempty"`
+}
+
+type Field struct {
+  Name string  `json:"name" yaml:"name"`
+  Type string  `json:"type" yaml:"type"`
+}
+
+// Route is a header route of the framework, kind is advance or inspect
+type Route struct {
+  Method string    `json:"method" yaml:"method"`
+  Kind string      `json:"kind" yaml:"kind"`
+  Fields []Field   `json:"fields" yaml:"fields"`
+}
+
+// Output is the codec of a notice or report, with the framework header if
+// header is set
+type Output struct {
+  Name string      `json:"name" yaml:"name"`
+  Header bool      `json:"header,omitempty" yaml:"header,omitempty"`
+  Fields []Field   `json:"fields" yaml:"fields"`
+}
+
+func LoadSpec(path string) (*Spec, error) {
+  data, err := os.ReadFile(path)
+  if err != nil {
+    return nil, fmt.Errorf("LoadSpec: %s", err)
+  }
+  var spec Spec
+  switch strings.ToLower(filepath.Ext(path)) {
+  case ".yaml", ".yml":
+    err = yaml.Unmarshal(data, &spec)
+  default:
+    err = json.Unmarshal(data, &spec)
+  }
+  if err != nil {
+    return nil, fmt.Errorf("LoadSpec: error decoding %s: %s", path, err)
+  }
+  if err = spec.validate(); err != nil {
+    return nil, fmt.Errorf("LoadSpec: %s", err)
+  }
+  return &spec, nil
+}
+
+func (s *Spec) validate() error {
+  if !isIdentifier(s.Package) {
+    return fmt.Errorf("invalid package %q", s.Package)
+  }
+  if s.Framework == "" {
+    return fmt.Errorf("empty framework")
+  }
+  if s.Name == "" {
+    s.Name = s.Framework
+  }
+  if !isIdentifier(s.Name) {
+    return fmt.Errorf("invalid name %q, set a name for the framework", s.Name)
+  }
+  if len(s.Routes) == 0 {
+    return fmt.Errorf("no routes")
+  }
+  // the generated names of routes and outputs must not collide
+  names := make(map[string]string)
+  addName := func(name string, what string) error {
+    if !isIdentifier(name) {
+      return fmt.Errorf("invalid %s name %q", what, name)
+    }
+    if other, ok := names[exported(name)]; ok {
+      return fmt.Errorf("%s %s collides with %s", what, name, other)
+    }
+    names[exported(name)] = what + " " + name
+    return nil
+  }
+  for _, r := range s.Routes {
+    if err := addName(r.Method, "route"); err != nil {
+      return err
+    }
+    if r.Kind != "advance" && r.Kind != "inspect" {
+      return fmt.Errorf("route %s: kind must be advance or inspect", r.Method)
+    }
+    if err := checkFields(r.Fields); err != nil {
+      return fmt.Errorf("route %s: %s", r.Method, err)
+    }
+  }
+  for _, o := range s.Notices {
+    if err := addName(o.Name + "Notice", "notice"); err != nil {
+      return err
+    }
+    if err := checkFields(o.Fields); err != nil {
+      return fmt.Errorf("notice %s: %s", o.Name, err)
+    }
+  }
+  for _, o := range s.Reports {
+    if err := addName(o.Name + "Report", "report"); err != nil {
+      return err
+    }
+    if err := checkFields(o.Fields); err != nil {
+      return fmt.Errorf("report %s: %s", o.Name, err)
+    }
+  }
+  return nil
+}
+
+func checkFields(fields []Field) error {
+  names := make(map[string]bool)
+  for _, f := range fields {
+    if !isIdentifier(f.Name) {
+      return fmt.Errorf("invalid field name %q", f.Name)
+    }
+    if names[exported(f.Name)] {
+      return fmt.Errorf("duplicated field %s", f.Name)
+    }
+    names[exported(f.Name)] = true
+    typ, err := parseType(f.Type)
+    if err != nil {
+      return fmt.Errorf("field %s: invalid type %s: %s", f.Name, f.Type, err)
+    }
+    if hasKind(typ, abi.KindTuple) || hasKind(typ, abi.KindFunction) || hasKind(typ, abi.KindFixedPoint) {
+      return fmt.Errorf("field %s: type %s is not supported", f.Name, f.Type)
+    }
+  }
+  return nil
+}
+
+func parseType(s string) (typ *abi.Type, err error) {
+  // abigo panics on invalid sizes
+  defer func() {
+    if r := recover(); r != nil {
+      err = fmt.Errorf("%v", r)
+    }
+  }()
+  return abi.NewType(s)
+}
+
+func hasKind(typ *abi.Type, kind abi.Kind) bool {
+  for ; typ != nil; typ = typ.Elem() {
+    if typ.Kind() == kind {
+      return true
+    }
+  }
+  return false
+}
+
+// abiFields returns the codec fields, e.g. "uint256 amount"
+func abiFields(fields []Field) []string {
+  abiFields := make([]string,0)
+  for _, f := range fields {
+    abiFields = append(abiFields, f.Type + " " + f.Name)
+  }
+  return abiFields
+}
+
+func isIdentifier(name string) bool {
+  // the first letter is exported in the generated code
+  if name == "" || !(('a' <= name[0] && name[0] <= 'z') || ('A' <= name[0] && name[0] <= 'Z')) {
+    return false
+  }
+  for i := 0; i < len(name); i++ {
+    ch := name[i]
+    if !(ch == '_' || ('a' <= ch && ch <= 'z') || ('A' <= ch && ch <= 'Z') || ('0' <= ch && ch <= '9')) {
+      return false
+    }
+  }
+  return true
+}
+
+// exported returns name with the first letter in upper case
+func exported(name string) string {
+  return strings.ToUpper(name[:1]) + name[1:]
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/prototyp3-dev/go-rollups/handler/abi"
+
+	"github.com/lynoferraz/abigo"
+)
+
+var tsTemplate = template.Must(template.New("ts").Funcs(template.FuncMap{
+  "exported": exported,
+  "unexported": unexported,
+  "tsParams": tsParams,
+  "tsFields": tsFields,
+  "tsValues": tsValues,
+  "tsDecoded": tsDecoded,
+  "header": header,
+  "cleanFields": cleanFields,
+}).Parse(`// Code generated by rollups-codegen from {{.Source}}. DO NOT EDIT.
+
+import { concat, decodeAbiParameters, encodeAbiParameters, keccak256, stringToHex } from "viem";
+import type { Address, Hex } from "viem";
+
+export const {{unexported .Spec.Name}}Framework = "{{.Spec.Framework}}";
+
+// codecHeader is the CodecHeader of the go-rollups abi handler
+export function codecHeader(framework: string, method: string, fields: readonly string[]): Hex {
+  return keccak256(concat([
+    keccak256(stringToHex(framework)),
+    keccak256(stringToHex(method)),
+    keccak256(stringToHex(` + "`(${fields.join(\",\")})`" + `)),
+  ]));
+}
+{{range .Spec.Routes}}
+export const {{unexported .Method}}Params = [
+{{tsParams .Fields}}] as const;
+
+// {{header $.Spec.Framework .Method .Fields}}
+export const {{unexported .Method}}Header = codecHeader({{unexported $.Spec.Name}}Framework, "{{.Method}}", [{{cleanFields .Fields}}]);
+
+export interface {{exported .Method}}Args {
+{{tsFields .Fields}}}
+
+// encode{{exported .Method}} returns the payload of a {{.Method}} {{.Kind}} input
+export function encode{{exported .Method}}(args: {{exported .Method}}Args): Hex {
+  return concat([{{unexported .Method}}Header, encodeAbiParameters({{unexported .Method}}Params, [{{tsValues .Fields}}])]);
+}
+{{end}}
+{{- range .Outputs}}
+export const {{unexported .Type}}Params = [
+{{tsParams .Fields}}] as const;
+{{if .Header}}
+// {{header $.Spec.Framework .Name .Fields}}
+export const {{unexported .Type}}Header = codecHeader({{unexported $.Spec.Name}}Framework, "{{.Name}}", [{{cleanFields .Fields}}]);
+{{end}}
+export interface {{.Type}} {
+{{tsFields .Fields}}}
+
+export function decode{{.Type}}(payload: Hex): {{.Type}} {
+{{- if .Header}}
+  if (payload.slice(0, 66).toLowerCase() !== {{unexported .Type}}Header) {
+    throw new Error("decode{{.Type}}: header does not match");
+  }
+  const values = decodeAbiParameters({{unexported .Type}}Params, ` + "`0x${payload.slice(66)}`" + `);
+{{- else}}
+  const values = decodeAbiParameters({{unexported .Type}}Params, payload);
+{{- end}}
+  return {
+{{tsDecoded .Fields}}  };
+}
+{{end}}`))
+
+type tsOutput struct {
+  Output
+  // Type is the output name with the Notice or Report suffix
+  Type string
+}
+
+type tsData struct {
+  Source string
+  Spec *Spec
+  Outputs []tsOutput
+}
+
+// GenerateTs returns a viem module with the encoders of the spec routes and
+// the decoders of its notices and reports
+func GenerateTs(spec *Spec, source string) ([]byte, error) {
+  data := tsData{Source: source, Spec: spec}
+  for _, o := range spec.Notices {
+    data.Outputs = append(data.Outputs, tsOutput{o, exported(o.Name) + "Notice"})
+  }
+  for _, o := range spec.Reports {
+    data.Outputs = append(data.Outputs, tsOutput{o, exported(o.Name) + "Report"})
+  }
+  var b bytes.Buffer
+  if err := tsTemplate.Execute(&b, data); err != nil {
+    return nil, fmt.Errorf("GenerateTs: %s", err)
+  }
+  return b.Bytes(), nil
+}
+
+// header returns the codec header computed by the abi handler, to check the
+// header computed by the client
+func header(framework string, method string, fields []Field) string {
+  return abihandler.NewHeaderCodec(framework, method, abiFields(fields)).Header
+}
+
+// cleanFields returns the types used in the header, e.g. uint256 for uint
+func cleanFields(fields []Field) string {
+  var types []string
+  for _, field := range abihandler.CleanFields(abihandler.GetType(abiFields(fields))) {
+    types = append(types, fmt.Sprintf("%q", field))
+  }
+  return strings.Join(types, ", ")
+}
+
+func tsParams(fields []Field) string {
+  var b strings.Builder
+  for _, f := range fields {
+    fmt.Fprintf(&b, "  { name: %q, type: %q },\n", f.Name, mustParseType(f.Type).String())
+  }
+  return b.String()
+}
+
+func tsFields(fields []Field) string {
+  var b strings.Builder
+  for _, f := range fields {
+    fmt.Fprintf(&b, "  %s: %s;\n", f.Name, tsType(mustParseType(f.Type)))
+  }
+  return b.String()
+}
+
+func tsValues(fields []Field) string {
+  var values []string
+  for _, f := range fields {
+    values = append(values, "args." + f.Name)
+  }
+  return strings.Join(values, ", ")
+}
+
+func tsDecoded(fields []Field) string {
+  var b strings.Builder
+  for i, f := range fields {
+    fmt.Fprintf(&b, "    %s: values[%d],\n", f.Name, i)
+  }
+  return b.String()
+}
+
+// tsType returns the viem type of the abi type, integers up to 48 bits are
+// numbers
+func tsType(typ *abi.Type) string {
+  switch typ.Kind() {
+  case abi.KindSlice:
+    return "readonly " + tsType(typ.Elem()) + "[]"
+  case abi.KindArray:
+    elems := make([]string, typ.Size())
+    for i := range elems {
+      elems[i] = tsType(typ.Elem())
+    }
+    return "readonly [" + strings.Join(elems, ", ") + "]"
+  case abi.KindUInt, abi.KindInt:
+    if typ.Size() <= 48 {
+      return "number"
+    }
+    return "bigint"
+  case abi.KindAddress:
+    return "Address"
+  case abi.KindBytes, abi.KindFixedBytes:
+    return "Hex"
+  case abi.KindBool:
+    return "boolean"
+  default:
+    return "string"
+  }
+}
+
+// unexported returns name with the first letter in lower case
+func unexported(name string) string {
+  return strings.ToLower(name[:1]) + name[1:]
+}
@@ -72,16 +72,11 @@ func (this *MyApp) ChangeFee(metadata *rollups.Metadata, payloadMap map[string]i
 }
 
 func (this *MyApp) GetFeeUri(payloadMap map[string]interface{}) error {
-  addrStr, ok1 := payloadMap["address"].(string)
+  addr, ok1 := payloadMap["address"].(abihandler.Address)
   if !ok1 {
     return fmt.Errorf("GetFee: parameters error")
   }
 
-  addr,err := abihandler.Hex2Address(addrStr)
-  if err != nil {
-    return fmt.Errorf("GetFee: parameters error: %s", err)
-  }
-
   return this.GetFee(map[string]interface{}{"address":addr})
 }
 
@@ -157,7 +152,7 @@ func main() {
   appHandler.HandleAdvanceRoute(abihandler.NewHeaderCodec("dapp","fee",[]string{}), myApp.PayFee)
   appHandler.HandleFixedAddressAdvance(abihandler.Address2Hex(developerAddress),abihandler.NewHeaderCodec("dapp","changeFee",[]string{"uint256 fee"}), myApp.ChangeFee)
   appHandler.HandleInspectRoute(abihandler.NewHeaderCodec("dapp","fee",[]string{"address address"}), myApp.GetFee)
-  myApp.dappWallet.UriHandler().HandleInspectRoute("/fee/:address", myApp.GetFeeUri)
+  myApp.dappWallet.UriHandler().HandleInspectRoute("/fee/:address<address>", myApp.GetFeeUri)
   
   appHandler.HandleDefault(myApp.HandleWrongWay)
 
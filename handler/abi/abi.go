@@ -1,6 +1,8 @@
 package abihandler
 
 import (
+  "context"
+  "fmt"
   "strings"
   hdl "github.com/prototyp3-dev/go-rollups/handler"
   "github.com/prototyp3-dev/go-rollups/rollups"
@@ -47,7 +49,7 @@ func (h *AbiHandler) HandleAdvanceRoute(routeCodec *Codec, fnHandle AdvanceMapHa
 	if fnHandle == nil {
 		panic("abi handler: nil handler")
 	}
-  if len(routeCodec.Header) > 0 && len(routeCodec.Header) != 66 {
+  if len(routeCodec.Header) > 0 && len(routeCodec.Header) != 66 && !routeCodec.IsSelector() {
     panic("abi handler: codec header format")
   }
   if len(routeCodec.Fields) != 0 && len(routeCodec.PackedFields) != 0 {
@@ -59,6 +61,7 @@ func (h *AbiHandler) HandleAdvanceRoute(routeCodec *Codec, fnHandle AdvanceMapHa
   if h.AdvanceCodecs == nil {
     h.AdvanceCodecs = make(map[string]*Codec)
   }
+  checkCollision(h.AdvanceCodecs, routeCodec)
 	if h.RouteAdvanceHandlers[routeCodec.Header] != nil {
 		panic("abi handler: route already added")
 	}
@@ -68,14 +71,14 @@ func (h *AbiHandler) HandleAdvanceRoute(routeCodec *Codec, fnHandle AdvanceMapHa
   fnHandler := AdvanceMapHandler{fnHandle}
   h.RouteAdvanceHandlers[routeCodec.Header] = &fnHandler
   h.AdvanceCodecs[routeCodec.Header] = routeCodec
-  if h.Handler.LogLevel >= hdl.Debug {hdl.DebugLogger.Println("Created ABI Advance route for",routeCodec) }
+  h.Handler.Logger().Debug("Created ABI Advance route", "route", routeCodec)
 }
 
 func (h *AbiHandler) HandleFixedAddressAdvance(address string, routeCodec *Codec, fnHandle AdvanceMapHandlerFunc) {
 	if fnHandle == nil {
 		panic("abi handler: nil handler")
 	}
-  if len(routeCodec.Header) > 0 && len(routeCodec.Header) != 66 {
+  if len(routeCodec.Header) > 0 && len(routeCodec.Header) != 66 && !routeCodec.IsSelector() {
     panic("abi handler: codec header format")
   }
   if len(routeCodec.Fields) != 0 && len(routeCodec.PackedFields) != 0 {
@@ -95,6 +98,7 @@ func (h *AbiHandler) HandleFixedAddressAdvance(address string, routeCodec *Codec
     h.FixedAdvanceCodecs[address] = make(map[string]*Codec)
   }
 
+  checkCollision(h.FixedAdvanceCodecs[address], routeCodec)
 	if h.FixedAddressAdvanceHandlers[address][routeCodec.Header] != nil {
 		panic("abi handler: route already added")
 	}
@@ -109,14 +113,14 @@ func (h *AbiHandler) HandleFixedAddressAdvance(address string, routeCodec *Codec
     h.Handler.HandleFixedAddressRoutes(address, h.abiFixedAdvanceHandler(address))
   }
 
-  if h.Handler.LogLevel >= hdl.Debug {hdl.DebugLogger.Println("Created Fixed ABI Advance route for",address,routeCodec) }
+  h.Handler.Logger().Debug("Created Fixed ABI Advance route", "address", address, "route", routeCodec)
 }
 
 func (h *AbiHandler) HandleInspectRoute(routeCodec *Codec, fnHandle InspectMapHandlerFunc) {
 	if fnHandle == nil {
 		panic("abi handler: nil handler")
 	}
-  if len(routeCodec.Header) > 0 && len(routeCodec.Header) != 66 {
+  if len(routeCodec.Header) > 0 && len(routeCodec.Header) != 66 && !routeCodec.IsSelector() {
     panic("abi handler: codec header format")
   }
   if len(routeCodec.Fields) != 0 && len(routeCodec.PackedFields) != 0 {
@@ -128,6 +132,7 @@ func (h *AbiHandler) HandleInspectRoute(routeCodec *Codec, fnHandle InspectMapHa
   if h.InspectCodecs == nil {
     h.InspectCodecs = make(map[string]*Codec)
   }
+  checkCollision(h.InspectCodecs, routeCodec)
 	if h.RouteInspectHandlers[routeCodec.Header] != nil {
 		panic("abi handler: route already added")
 	}
@@ -137,7 +142,7 @@ func (h *AbiHandler) HandleInspectRoute(routeCodec *Codec, fnHandle InspectMapHa
   fnHandler := InspectMapHandler{fnHandle}
   h.RouteInspectHandlers[routeCodec.Header] = &fnHandler
   h.InspectCodecs[routeCodec.Header] = routeCodec
-  if h.Handler.LogLevel >= hdl.Debug {hdl.DebugLogger.Println("Created ABI Inspect route for",routeCodec) }
+  h.Handler.Logger().Debug("Created ABI Inspect route", "route", routeCodec)
 }
 
 func (h *AbiHandler) abiAdvanceHandler(metadata *rollups.Metadata, payloadHex string) (error,bool) {
@@ -147,19 +152,22 @@ func (h *AbiHandler) abiAdvanceHandler(metadata *rollups.Metadata, payloadHex st
     if err != nil {
       return err,true
     }
-    if h.Handler.LogLevel >= hdl.Trace {hdl.TraceLogger.Println("Received ABI no header Advance Request:",result) }
-    return h.RouteAdvanceHandlers[""].Handler.Handle(metadata,result),true
+    h.Handler.Logger().Log(context.Background(), hdl.LevelTrace, "Received ABI Advance request", "params", result)
+    return h.Handler.Dispatch("abi", result, func(req *hdl.Request) error {
+      return h.RouteAdvanceHandlers[""].Handler.Handle(req.Metadata,req.Params)
+    }),true
   }
-  if len(payloadHex) >= 66 {
-    header := payloadHex[:66]
+  if header, ok := routeHeader(payloadHex, h.AdvanceCodecs); ok {
     if h.RouteAdvanceHandlers[header] != nil {
       codec := h.AdvanceCodecs[header]
       result,err := codec.Decode(payloadHex)
       if err != nil {
         return err,true
       }
-      if h.Handler.LogLevel >= hdl.Trace {hdl.TraceLogger.Println("Received ABI route",header,"Advance Request:",result) }
-      return h.RouteAdvanceHandlers[header].Handler.Handle(metadata,result),true
+      h.Handler.Logger().Log(context.Background(), hdl.LevelTrace, "Received ABI Advance request", "route", header, "params", result)
+      return h.Handler.Dispatch(header, result, func(req *hdl.Request) error {
+        return h.RouteAdvanceHandlers[header].Handler.Handle(req.Metadata,req.Params)
+      }),true
     }
   }
   return nil,false
@@ -172,19 +180,22 @@ func (h *AbiHandler) abiInspectHandler(payloadHex string) (error,bool) {
     if err != nil {
       return err,true
     }
-    if h.Handler.LogLevel >= hdl.Trace {hdl.TraceLogger.Println("Received ABI no header Inspect Request:",result) }
-    return h.RouteInspectHandlers[""].Handler.Handle(result),true
+    h.Handler.Logger().Log(context.Background(), hdl.LevelTrace, "Received ABI Inspect request", "params", result)
+    return h.Handler.Dispatch("abi", result, func(req *hdl.Request) error {
+      return h.RouteInspectHandlers[""].Handler.Handle(req.Params)
+    }),true
   }
-  if len(payloadHex) >= 66 {
-    header := payloadHex[:66]
+  if header, ok := routeHeader(payloadHex, h.InspectCodecs); ok {
     if h.RouteInspectHandlers[header] != nil {
       codec := h.InspectCodecs[header]
       result,err := codec.Decode(payloadHex)
       if err != nil {
         return err,true
       }
-      if h.Handler.LogLevel >= hdl.Trace {hdl.TraceLogger.Println("Received ABI route",header,"Inspect Request:",result) }
-      return h.RouteInspectHandlers[header].Handler.Handle(result),true
+      h.Handler.Logger().Log(context.Background(), hdl.LevelTrace, "Received ABI Inspect request", "route", header, "params", result)
+      return h.Handler.Dispatch(header, result, func(req *hdl.Request) error {
+        return h.RouteInspectHandlers[header].Handler.Handle(req.Params)
+      }),true
     }
   }
   return nil,false
@@ -199,30 +210,100 @@ func (h *AbiHandler) abiFixedAdvanceHandler(address string) (func(*rollups.Metad
       if err != nil {
         return err,true
       }
-      if h.Handler.LogLevel >= hdl.Trace {hdl.TraceLogger.Println("Received ABI no header Fixed Advance Request:",result) }
-      return h.FixedAddressAdvanceHandlers[address][""].Handler.Handle(metadata,result),true
+      h.Handler.Logger().Log(context.Background(), hdl.LevelTrace, "Received ABI fixed Advance request", "params", result)
+      return h.Handler.Dispatch(address, result, func(req *hdl.Request) error {
+        return h.FixedAddressAdvanceHandlers[address][""].Handler.Handle(req.Metadata,req.Params)
+      }),true
     }
-    if len(payloadHex) >= 66 {
-      header := payloadHex[:66]
+    if header, ok := routeHeader(payloadHex, h.FixedAdvanceCodecs[address]); ok {
       if h.FixedAddressAdvanceHandlers[address][header] != nil {
         codec := h.FixedAdvanceCodecs[address][header]
         result,err := codec.Decode(payloadHex)
         if err != nil {
           return err,true
         }
-        if h.Handler.LogLevel >= hdl.Trace {hdl.TraceLogger.Println("Received ABI route",header,"Advance Request:",result) }
-        return h.FixedAddressAdvanceHandlers[address][header].Handler.Handle(metadata,result),true
+        h.Handler.Logger().Log(context.Background(), hdl.LevelTrace, "Received ABI Advance request", "route", header, "params", result)
+        return h.Handler.Dispatch(header, result, func(req *hdl.Request) error {
+          return h.FixedAddressAdvanceHandlers[address][header].Handler.Handle(req.Metadata,req.Params)
+        }),true
       }
     }
     return nil,false
   }
 }
 
+// routeHeader returns the header of the payload with a codec, the 32 bytes
+// headers are tried before the 4 bytes selectors
+func routeHeader(payloadHex string, codecs map[string]*Codec) (string,bool) {
+  for _, size := range []int{66,10} {
+    if len(payloadHex) >= size && codecs[payloadHex[:size]] != nil {
+      return payloadHex[:size], true
+    }
+  }
+  return "", false
+}
+
+// checkCollision panics if two different function signatures have the same
+// selector, or if a selector is the prefix of a 32 bytes header, as the header
+// would take the inputs of the selector route
+func checkCollision(codecs map[string]*Codec, routeCodec *Codec) {
+  current := codecs[routeCodec.Header]
+  if current != nil && current.IsSelector() && routeCodec.IsSelector() && current.Signature() != routeCodec.Signature() {
+    panic(fmt.Sprintf("abi handler: selector %s of %s collides with %s", routeCodec.Header, routeCodec.Signature(), current.Signature()))
+  }
+  for _, codec := range codecs {
+    selector, long := codec, routeCodec
+    if routeCodec.IsSelector() {
+      selector, long = routeCodec, codec
+    }
+    if selector.IsSelector() && len(long.Header) == 66 && strings.HasPrefix(long.Header, selector.Header) {
+      panic(fmt.Sprintf("abi handler: header %s starts with the selector of %s", long.Header, selector.Signature()))
+    }
+  }
+}
+
+// ContextAdvance adapts fnHandle to the AdvanceMapHandlerFunc signature, the
+// decoded params are in the request Params
+func (h *AbiHandler) ContextAdvance(fnHandle hdl.ContextHandlerFunc) AdvanceMapHandlerFunc {
+	if fnHandle == nil {
+		panic("abi handler: nil handler")
+	}
+  return func(metadata *rollups.Metadata, payloadMap map[string]interface{}) error {
+    req := h.Handler.Request()
+    return fnHandle(req.Context(), req)
+  }
+}
+
+func (h *AbiHandler) ContextInspect(fnHandle hdl.ContextHandlerFunc) InspectMapHandlerFunc {
+	if fnHandle == nil {
+		panic("abi handler: nil handler")
+	}
+  return func(payloadMap map[string]interface{}) error {
+    req := h.Handler.Request()
+    return fnHandle(req.Context(), req)
+  }
+}
+
+func (h *AbiHandler) HandleAdvanceRouteContext(routeCodec *Codec, fnHandle hdl.ContextHandlerFunc) {
+  h.HandleAdvanceRoute(routeCodec, h.ContextAdvance(fnHandle))
+}
+
+func (h *AbiHandler) HandleInspectRouteContext(routeCodec *Codec, fnHandle hdl.ContextHandlerFunc) {
+  h.HandleInspectRoute(routeCodec, h.ContextInspect(fnHandle))
+}
+
+func (h *AbiHandler) HandleFixedAddressAdvanceContext(address string, routeCodec *Codec, fnHandle hdl.ContextHandlerFunc) {
+  h.HandleFixedAddressAdvance(address, routeCodec, h.ContextAdvance(fnHandle))
+}
+
 func (h *AbiHandler) SetDebug() {h.Handler.SetDebug()}
 func (h *AbiHandler) SetLogLevel(logLevel hdl.LogLevel) {h.Handler.SetLogLevel(logLevel)}
+func (h *AbiHandler) Use(middlewares ...hdl.Middleware) {h.Handler.Use(middlewares...)}
 func (h *AbiHandler) HandleDefault(fnHandle hdl.InspectHandlerFunc) {h.Handler.HandleDefault(fnHandle)}
 func (h *AbiHandler) HandleInspect(fnHandle hdl.InspectHandlerFunc) {h.Handler.HandleInspect(fnHandle)}
 func (h *AbiHandler) HandleAdvance(fnHandle hdl.AdvanceHandlerFunc) {h.Handler.HandleAdvance(fnHandle)}
+func (h *AbiHandler) HandleAdvanceContext(fnHandle hdl.ContextHandlerFunc) {h.Handler.HandleAdvanceContext(fnHandle)}
+func (h *AbiHandler) HandleInspectContext(fnHandle hdl.ContextHandlerFunc) {h.Handler.HandleInspectContext(fnHandle)}
 func (h *AbiHandler) HandleRollupsFixedAddresses(fnHandle hdl.AdvanceHandlerFunc) {h.Handler.HandleRollupsFixedAddresses(fnHandle)}
 func (h *AbiHandler) HandleFixedAddress(address string, fnHandle hdl.AdvanceHandlerFunc) {h.Handler.HandleFixedAddress(address,fnHandle)}
 func (h *AbiHandler) SendNotice(payloadHex string) (uint64,error) {return h.Handler.SendNotice(payloadHex)}
@@ -0,0 +1,94 @@
+package abihandler
+
+import (
+  "math/big"
+  "reflect"
+  "testing"
+
+  "github.com/prototyp3-dev/go-rollups/handler/handlertest"
+  "github.com/prototyp3-dev/go-rollups/rollups"
+)
+
+func mustPanic(t *testing.T, name string, fn func()) {
+  t.Helper()
+  defer func() {
+    if recover() == nil {
+      t.Errorf("%s: didn't panic", name)
+    }
+  }()
+  fn()
+}
+
+func TestSelectorRouting(t *testing.T) {
+  to := Address{0x01}
+  transfer := NewSelectorCodec("transfer(address to,uint256 amount)")
+  transferData := NewSelectorCodec("transfer(address to,uint256 amount,bytes data)")
+  var routed string
+  var params map[string]interface{}
+  abiHandler := NewAbiHandler()
+  for name, codec := range map[string]*Codec{"transfer": transfer, "transferData": transferData, "add": addCodec} {
+    name := name
+    abiHandler.HandleAdvanceRoute(codec, func(metadata *rollups.Metadata, payloadMap map[string]interface{}) error {
+      routed, params = name, payloadMap
+      return nil
+    })
+  }
+  abiHandler.HandleDefault(func(payloadHex string) error {
+    routed, params = "default", nil
+    return nil
+  })
+  driver := handlertest.NewDriver(abiHandler.Handler)
+
+  tests := []struct {
+    name string
+    payload string
+    route string
+    params map[string]interface{}
+  }{
+    {"selector", rollups.Bin2Hex(mustEncode(transfer, to, big.NewInt(5))), "transfer",
+      map[string]interface{}{"to": to, "amount": big.NewInt(5)}},
+    {"overload", rollups.Bin2Hex(mustEncode(transferData, to, big.NewInt(5), []byte{1})), "transferData",
+      map[string]interface{}{"to": to, "amount": big.NewInt(5), "data": []byte{1}}},
+    {"header", rollups.Bin2Hex(mustEncode(addCodec, big.NewInt(2))), "add", map[string]interface{}{"value": big.NewInt(2)}},
+    {"unknown selector", "0x12345678" + transfer.Header[2:], "default", nil},
+    {"short payload", transfer.Header[:8], "default", nil},
+  }
+  for _, tt := range tests {
+    routed, params = "none", nil
+    if result := driver.Advance(sender, tt.payload); !result.Accepted() {
+      t.Errorf("%s: rejected: %v", tt.name, result.Err)
+    }
+    if routed != tt.route || !reflect.DeepEqual(params, tt.params) {
+      t.Errorf("%s: routed to %s with %v, expected %s with %v", tt.name, routed, params, tt.route, tt.params)
+    }
+  }
+}
+
+func TestSelectorCollision(t *testing.T) {
+  noop := func(metadata *rollups.Metadata, payloadMap map[string]interface{}) error { return nil }
+  transfer := NewSelectorCodec("transfer(address,uint256)")
+  // a codec of another signature with the selector of transfer
+  collision := NewSelectorCodec("approve(address,uint256)")
+  collision.Header = transfer.Header
+  // a header starting with the selector of transfer
+  prefixed := *addCodec
+  prefixed.Header = transfer.Header + addCodec.Header[10:]
+
+  abiHandler := NewAbiHandler()
+  abiHandler.HandleAdvanceRoute(transfer, noop)
+  mustPanic(t, "same route", func() { abiHandler.HandleAdvanceRoute(NewSelectorCodec("transfer(address,uint256)"), noop) })
+  mustPanic(t, "selector collision", func() { abiHandler.HandleAdvanceRoute(collision, noop) })
+  mustPanic(t, "header after the selector", func() { abiHandler.HandleAdvanceRoute(&prefixed, noop) })
+  mustPanic(t, "fixed address header", func() {
+    abiHandler.HandleFixedAddressAdvance(sender, transfer, noop)
+    abiHandler.HandleFixedAddressAdvance(sender, &prefixed, noop)
+  })
+
+  abiHandler = NewAbiHandler()
+  abiHandler.HandleInspectRoute(&prefixed, func(payloadMap map[string]interface{}) error { return nil })
+  mustPanic(t, "selector after the header", func() {
+    abiHandler.HandleInspectRoute(transfer, func(payloadMap map[string]interface{}) error { return nil })
+  })
+  // the routes of advance and inspect don't collide
+  abiHandler.HandleAdvanceRoute(transfer, noop)
+}
@@ -0,0 +1,131 @@
+package abihandler
+
+import (
+  "fmt"
+  "sort"
+
+  hdl "github.com/prototyp3-dev/go-rollups/handler"
+
+  "github.com/lynoferraz/abigo"
+)
+
+// AbiBindings maps the functions of an abi to their handlers, by name or by
+// signature (e.g. "transfer(address,uint256)"). Overloaded functions can only
+// be bound by signature.
+type AbiBindings map[string]hdl.ContextHandlerFunc
+
+// BindAbi adds a route for each function of the abi json, matched by the
+// function selector. View and pure functions are inspect routes, the others
+// advance routes. It fails if a function has no handler, a handler has no
+// function or a route can't be added, and then no route is added.
+func (h *AbiHandler) BindAbi(abiJson []byte, bindings AbiBindings) error {
+  return h.bindAbi(abiJson, bindings, func(method *abi.Method) *Codec {
+    return newMethodCodec(method)
+  })
+}
+
+// BindAbiHeaders is BindAbi with header routes of framework, the method of
+// each route is the function name
+func (h *AbiHandler) BindAbiHeaders(abiJson []byte, framework string, bindings AbiBindings) error {
+  return h.bindAbi(abiJson, bindings, func(method *abi.Method) *Codec {
+    return NewHeaderCodec(framework, method.Name, methodFields(method))
+  })
+}
+
+func parseAbi(abiJson []byte) (parsed *abi.ABI, err error) {
+  // abigo panics on invalid types
+  defer func() {
+    if r := recover(); r != nil {
+      err = fmt.Errorf("%v", r)
+    }
+  }()
+  return abi.NewABI(string(abiJson))
+}
+
+func (h *AbiHandler) bindAbi(abiJson []byte, bindings AbiBindings, newCodec func(*abi.Method) *Codec) error {
+  parsed, err := parseAbi(abiJson)
+  if err != nil {
+    return fmt.Errorf("BindAbi: invalid abi: %s", err)
+  }
+  // abigo keys the overloads of a function by name plus a number (transfer,
+  // transfer0, ...), the bindings use the function name or signature instead
+  names := make([]string,0)
+  overloads := make(map[string]int)
+  for name, method := range parsed.Methods {
+    names = append(names, name)
+    overloads[method.Name]++
+  }
+  sort.Strings(names)
+
+  handlers := make(map[string]hdl.ContextHandlerFunc)
+  used := make(map[string]bool)
+  for _, name := range names {
+    method := parsed.Methods[name]
+    key := method.Sig()
+    if bindings[key] == nil && overloads[method.Name] == 1 {
+      key = method.Name
+    }
+    if bindings[key] == nil {
+      return fmt.Errorf("BindAbi: function %s has no handler", method.Sig())
+    }
+    handlers[name] = bindings[key]
+    used[key] = true
+  }
+  for key := range bindings {
+    if !used[key] {
+      return fmt.Errorf("BindAbi: handler %s has no function in the abi", key)
+    }
+  }
+
+  // all the routes are checked before adding any of them
+  codecs := make(map[string]*Codec)
+  advance, inspect := copyCodecs(h.AdvanceCodecs), copyCodecs(h.InspectCodecs)
+  for _, name := range names {
+    method := parsed.Methods[name]
+    codec := newCodec(method)
+    routes := advance
+    if method.Const {
+      routes = inspect
+    }
+    if err := checkRoute(routes, codec); err != nil {
+      return fmt.Errorf("BindAbi: function %s: %s", method.Sig(), err)
+    }
+    routes[codec.Header] = codec
+    codecs[name] = codec
+  }
+
+  for _, name := range names {
+    if parsed.Methods[name].Const {
+      h.HandleInspectRouteContext(codecs[name], handlers[name])
+    } else {
+      h.HandleAdvanceRouteContext(codecs[name], handlers[name])
+    }
+  }
+  return nil
+}
+
+func copyCodecs(codecs map[string]*Codec) map[string]*Codec {
+  result := make(map[string]*Codec)
+  for header, codec := range codecs {
+    result[header] = codec
+  }
+  return result
+}
+
+// checkRoute returns the error of adding a route of codec to codecs, instead
+// of panicking
+func checkRoute(codecs map[string]*Codec, codec *Codec) (err error) {
+  defer func() {
+    if r := recover(); r != nil {
+      err = fmt.Errorf("%v", r)
+    }
+  }()
+  checkCollision(codecs, codec)
+  if codecs[codec.Header] != nil {
+    return fmt.Errorf("route %s already added", codec.Header)
+  }
+  if codecs[""] != nil {
+    return fmt.Errorf("a codec with no header is already added")
+  }
+  return nil
+}
@@ -0,0 +1,133 @@
+package abihandler
+
+import (
+  "context"
+  "math/big"
+  "testing"
+
+  hdl "github.com/prototyp3-dev/go-rollups/handler"
+  "github.com/prototyp3-dev/go-rollups/handler/handlertest"
+  "github.com/prototyp3-dev/go-rollups/rollups"
+)
+
+const marketAbi = `[
+  {"type":"function","name":"placeOrder","stateMutability":"nonpayable","inputs":[{"name":"amount","type":"uint256"}],"outputs":[]},
+  {"type":"function","name":"balanceOf","stateMutability":"view","inputs":[{"name":"owner","type":"address"}],"outputs":[{"name":"","type":"uint256"}]},
+  {"type":"function","name":"transfer","stateMutability":"nonpayable","inputs":[{"name":"to","type":"address"},{"name":"amount","type":"uint256"}],"outputs":[]},
+  {"type":"function","name":"transfer","stateMutability":"nonpayable","inputs":[{"name":"to","type":"address"},{"name":"amount","type":"uint256"},{"name":"data","type":"bytes"}],"outputs":[]}
+]`
+
+// bindings returns the bindings of marketAbi, each handler sets routed to its
+// key
+func bindings(routed *string, keys ...string) AbiBindings {
+  result := make(AbiBindings)
+  for _, key := range keys {
+    key := key
+    result[key] = func(ctx context.Context, req *hdl.Request) error {
+      *routed = key
+      return nil
+    }
+  }
+  return result
+}
+
+func TestBindAbi(t *testing.T) {
+  var routed string
+  valid := []string{"placeOrder", "balanceOf", "transfer(address,uint256)", "transfer(address,uint256,bytes)"}
+  tests := []struct {
+    name string
+    abi string
+    keys []string
+    valid bool
+  }{
+    {"all functions", marketAbi, valid, true},
+    {"by signature", marketAbi, []string{"placeOrder(uint256)", "balanceOf(address)", "transfer(address,uint256)", "transfer(address,uint256,bytes)"}, true},
+    // the overloads are only bound by signature, not by the abigo keys
+    {"overload by name", marketAbi, []string{"placeOrder", "balanceOf", "transfer", "transfer(address,uint256,bytes)"}, false},
+    {"overload by abigo key", marketAbi, []string{"placeOrder", "balanceOf", "transfer(address,uint256)", "transfer0"}, false},
+    {"function without handler", marketAbi, valid[:3], false},
+    {"handler without function", marketAbi, append([]string{"cancel"}, valid...), false},
+    {"invalid abi", `[{"type":"function","name":"x","inputs":[{"name":"a","type":"uint7"}]}]`, []string{"x"}, false},
+  }
+  for _, tt := range tests {
+    abiHandler := NewAbiHandler()
+    err := abiHandler.BindAbi([]byte(tt.abi), bindings(&routed, tt.keys...))
+    if (err == nil) != tt.valid {
+      t.Errorf("%s: error %v, expected valid %t", tt.name, err, tt.valid)
+    }
+    if !tt.valid && (len(abiHandler.AdvanceCodecs) != 0 || len(abiHandler.InspectCodecs) != 0) {
+      t.Errorf("%s: routes added by a failed bind", tt.name)
+    }
+  }
+
+  abiHandler := NewAbiHandler()
+  if err := abiHandler.BindAbi([]byte(marketAbi), bindings(&routed, valid...)); err != nil {
+    t.Fatal(err)
+  }
+  driver := handlertest.NewDriver(abiHandler.Handler)
+  to := Address{0x01}
+  inputs := []struct {
+    inspect bool
+    payload []byte
+    route string
+  }{
+    {false, mustEncode(NewSelectorCodec("placeOrder(uint256)"), big.NewInt(1)), "placeOrder"},
+    {true, mustEncode(NewSelectorCodec("balanceOf(address)"), to), "balanceOf"},
+    {false, mustEncode(NewSelectorCodec("transfer(address,uint256)"), to, big.NewInt(1)), "transfer(address,uint256)"},
+    {false, mustEncode(NewSelectorCodec("transfer(address,uint256,bytes)"), to, big.NewInt(1), []byte{}), "transfer(address,uint256,bytes)"},
+    // view functions aren't advance routes
+    {false, mustEncode(NewSelectorCodec("balanceOf(address)"), to), ""},
+  }
+  for _, input := range inputs {
+    routed = ""
+    if input.inspect {
+      driver.Inspect(rollups.Bin2Hex(input.payload))
+    } else {
+      driver.Advance(sender, rollups.Bin2Hex(input.payload))
+    }
+    if routed != input.route {
+      t.Errorf("%s: routed to %q, expected %q", rollups.Bin2Hex(input.payload[:4]), routed, input.route)
+    }
+  }
+}
+
+func TestBindAbiCollision(t *testing.T) {
+  var routed string
+  keys := []string{"placeOrder", "balanceOf", "transfer(address,uint256)", "transfer(address,uint256,bytes)"}
+  noop := func(metadata *rollups.Metadata, payloadMap map[string]interface{}) error { return nil }
+
+  // a route of the abi already added fails the whole bind
+  abiHandler := NewAbiHandler()
+  abiHandler.HandleAdvanceRoute(NewSelectorCodec("transfer(address,uint256)"), noop)
+  if err := abiHandler.BindAbi([]byte(marketAbi), bindings(&routed, keys...)); err == nil {
+    t.Errorf("bound a function with a route already added")
+  }
+  if len(abiHandler.AdvanceCodecs) != 1 || len(abiHandler.InspectCodecs) != 0 {
+    t.Errorf("failed bind added routes: %d advance, %d inspect", len(abiHandler.AdvanceCodecs), len(abiHandler.InspectCodecs))
+  }
+
+  // the header routes are keyed by framework, name and fields
+  abiHandler = NewAbiHandler()
+  if err := abiHandler.BindAbiHeaders([]byte(marketAbi), "market", bindings(&routed, keys...)); err != nil {
+    t.Fatal(err)
+  }
+  headers := []*Codec{
+    NewHeaderCodec("market", "placeOrder", []string{"uint256 amount"}),
+    NewHeaderCodec("market", "transfer", []string{"address to", "uint256 amount"}),
+    NewHeaderCodec("market", "transfer", []string{"address to", "uint256 amount", "bytes data"}),
+  }
+  for _, codec := range headers {
+    if abiHandler.AdvanceCodecs[codec.Header] == nil {
+      t.Errorf("no route for %s", codec)
+    }
+  }
+  if len(abiHandler.AdvanceCodecs) != 3 || len(abiHandler.InspectCodecs) != 1 {
+    t.Errorf("bound %d advance and %d inspect routes, expected 3 and 1", len(abiHandler.AdvanceCodecs), len(abiHandler.InspectCodecs))
+  }
+  if err := abiHandler.BindAbiHeaders([]byte(marketAbi), "market", bindings(&routed, keys...)); err == nil {
+    t.Errorf("bound the headers twice")
+  }
+  if len(abiHandler.AdvanceCodecs) != 3 || len(abiHandler.InspectCodecs) != 1 {
+    t.Errorf("failed bind added routes")
+  }
+}
@@ -3,6 +3,7 @@ package abihandler
 import (
   "strings"
   "fmt"
+  "reflect"
 
   "github.com/prototyp3-dev/go-rollups/rollups"
 
@@ -48,6 +49,9 @@ func (c Codec) String() string {
     atts = append(atts, fmt.Sprintf("Header(%s)",c.Header))
     atts = append(atts, fmt.Sprintf("Framework(%s)",c.Framework))
     atts = append(atts, fmt.Sprintf("Method(%s)",c.Method))
+  } else if c.IsSelector() && c.Method != "" {
+    atts = append(atts, fmt.Sprintf("Selector(%s)",c.Header))
+    atts = append(atts, fmt.Sprintf("Signature(%s)",c.Signature()))
   }
   if len(c.Fields) > 0 {
     atts = append(atts, fmt.Sprintf("Fields(%s)",c.Fields))
@@ -121,6 +125,44 @@ func VoucherHeader(method string, fields []string) string {
   headerKeccak := ethgo.Keccak256([]byte(method+"("+strings.Join(fields, ",")+")"))
   return rollups.Bin2Hex(headerKeccak[:4])
 }
+
+// NewSelectorCodec creates a codec for calldata with the 4 bytes selector of
+// a solidity function, e.g. "transfer(address to,uint256 amount)". The
+// decoded params are keyed by the argument names (or positions if unnamed).
+func NewSelectorCodec(signature string) *Codec {
+  method, err := abi.NewMethod(signature)
+  if err != nil {
+    panic(fmt.Sprintf("abi handler: invalid signature %s: %s", signature, err))
+  }
+  if method.Name == "" {
+    panic(fmt.Sprintf("abi handler: invalid signature %s", signature))
+  }
+  return newMethodCodec(method)
+}
+
+func newMethodCodec(method *abi.Method) *Codec {
+  return &Codec{Method: method.Name, Header: rollups.Bin2Hex(method.ID()), Fields: methodFields(method), typ: method.Inputs}
+}
+
+// methodFields returns the named fields of the method inputs, including the
+// names of the tuple elements
+func methodFields(method *abi.Method) []string {
+  fields := make([]string,0)
+  for _, elem := range method.Inputs.TupleElems() {
+    fields = append(fields, strings.TrimSpace(elem.Elem.Format(true) + " " + elem.Name))
+  }
+  return fields
+}
+
+// Signature returns the solidity signature of the codec method and fields
+func (c Codec) Signature() string {
+  return (&abi.Method{Name: c.Method, Inputs: c.typ}).Sig()
+}
+
+// IsSelector reports if the codec header is a 4 bytes function selector
+func (c Codec) IsSelector() bool {
+  return len(c.Header) == 10
+}
 func (c *Codec) Decode(payloadHex string) (map[string]interface{},error) {
 	var result map[string]interface{}
   payloadBytes, err := rollups.Hex2Bin(payloadHex)
@@ -128,10 +170,10 @@ func (c *Codec) Decode(payloadHex string) (map[string]interface{},error) {
     return result,fmt.Errorf("Decode: %s", err)
   }
   if len(c.Header) > 0 {
-    if payloadHex[:66] != c.Header {
+    if len(payloadHex) < len(c.Header) || payloadHex[:len(c.Header)] != c.Header {
       return result,fmt.Errorf("Decode: Header does not match")
     }
-    payloadBytes = payloadBytes[32:]
+    payloadBytes = payloadBytes[(len(c.Header)-2)/2:]
   }
 
   var fields []string
@@ -186,6 +228,15 @@ func (c *Codec) Encode(payload interface{}) (string,error) {
   var payloadMap map[string]interface{}
   typ := c.typ
 
+  // tagged structs are encoded as the list of their abi fields
+  if rv := reflect.ValueOf(payload); rv.Kind() == reflect.Struct || (rv.Kind() == reflect.Ptr && rv.Elem().Kind() == reflect.Struct) {
+    values, err := structValues(rv)
+    if err != nil {
+      return result,fmt.Errorf("Encode: %s",err)
+    }
+    payload = values
+  }
+
   payloadSlice, ok := payload.([]interface{})
   if ok {
     if len(fields) != len(payloadSlice) {
@@ -194,9 +245,11 @@ func (c *Codec) Encode(payload interface{}) (string,error) {
     
     tupleFields := make([]string,0)
     payloadMap = make(map[string]interface{})
+    // the fields named by position, the tuple elements keep their names
+    elems := c.typ.TupleElems()
     for i := 0; i < len(fields); i += 1 {
       key := fmt.Sprintf("f%d",i)
-      tupleFields = append(tupleFields,fmt.Sprintf("%s %s",fields[i],key))
+      tupleFields = append(tupleFields,fmt.Sprintf("%s %s",elems[i].Elem.Format(true),key))
       payloadMap[key] = payloadSlice[i]
     }
     typ, _ = abi.NewType("tuple("+ strings.Join(tupleFields, ",") +")")
@@ -0,0 +1,281 @@
+package abihandler
+
+import (
+  "fmt"
+  "reflect"
+  "strconv"
+  "strings"
+
+  hdl "github.com/prototyp3-dev/go-rollups/handler"
+  "github.com/prototyp3-dev/go-rollups/rollups"
+
+  "github.com/lynoferraz/abigo"
+  "github.com/umbracle/ethgo"
+)
+
+// eventPayloadType is the encoding of event notices, the topics and data of
+// an EVM log: abi.encode(bytes32[] topics, bytes data)
+var eventPayloadType = abi.MustNewType("tuple(bytes32[] topics,bytes data)")
+
+// Event is a solidity event emitted as notices. The first topic is the hash
+// of the event signature, followed by the indexed params, and the data is the
+// abi encoding of the other params, like the logs of a contract.
+type Event struct {
+  Name string
+  Topic string
+  event *abi.Event
+}
+
+// NewEvent creates an event from its declaration, e.g.
+// "event Transfer(address indexed from, address indexed to, uint256 amount)".
+// Indexed strings and bytes are emitted as their hash, indexed arrays and
+// tuples are not supported and at most 3 params can be indexed.
+func NewEvent(signature string) *Event {
+  if !strings.HasPrefix(signature, "event ") {
+    signature = "event " + signature
+  }
+  event, err := abi.NewEvent(signature)
+  if err != nil {
+    panic(fmt.Sprintf("abi handler: invalid event %s: %s", signature, err))
+  }
+  if event.Name == "" {
+    panic(fmt.Sprintf("abi handler: invalid event %s", signature))
+  }
+  indexed := 0
+  for _, elem := range event.Inputs.TupleElems() {
+    if !elem.Indexed {
+      continue
+    }
+    if elem.Elem.Kind() == abi.KindTuple || elem.Elem.Kind() == abi.KindSlice || elem.Elem.Kind() == abi.KindArray {
+      panic(fmt.Sprintf("abi handler: event %s: indexed %s is not supported", event.Name, elem.Elem))
+    }
+    indexed++
+  }
+  // the logs have at most 4 topics, the first is the signature hash
+  if indexed > 3 {
+    panic(fmt.Sprintf("abi handler: event %s: more than 3 indexed params", event.Name))
+  }
+  id := event.ID()
+  return &Event{Name: event.Name, Topic: rollups.Bin2Hex(id[:]), event: event}
+}
+
+// Signature returns the signature hashed in the event topic
+func (e *Event) Signature() string {
+  return e.event.Sig()
+}
+
+func (e *Event) String() string {
+  return fmt.Sprintf("Event{Topic(%s),Signature(%s)}", e.Topic, e.Signature())
+}
+
+// Encode returns the notice payload of the event with values, that can be
+// the list of params, a map of the params by name or a struct with abi tags
+func (e *Event) Encode(values interface{}) (string,error) {
+  args, err := e.args(values)
+  if err != nil {
+    return "", fmt.Errorf("Encode: %s", err)
+  }
+  topics := []ethgo.Hash{e.event.ID()}
+  dataFields := make([]string,0)
+  data := make(map[string]interface{})
+  for i, elem := range e.event.Inputs.TupleElems() {
+    if elem.Indexed {
+      topic, err := encodeTopic(elem.Elem, args[i])
+      if err != nil {
+        return "", fmt.Errorf("Encode: param %s: %s", paramKey(elem, i), err)
+      }
+      topics = append(topics, topic)
+      continue
+    }
+    key := fmt.Sprintf("f%d",i)
+    dataFields = append(dataFields, elem.Elem.Format(true) + " " + key)
+    data[key] = args[i]
+  }
+  dataType, err := abi.NewType("tuple("+ strings.Join(dataFields, ",") +")")
+  if err != nil {
+    return "", fmt.Errorf("Encode: %s", err)
+  }
+  dataBytes, err := abi.Encode(data, dataType)
+  if err != nil {
+    return "", fmt.Errorf("Encode: %s", err)
+  }
+  encoded, err := abi.Encode(map[string]interface{}{"topics": topics, "data": dataBytes}, eventPayloadType)
+  if err != nil {
+    return "", fmt.Errorf("Encode: %s", err)
+  }
+  return rollups.Bin2Hex(encoded), nil
+}
+
+// args returns the values of the event params in order
+func (e *Event) args(values interface{}) ([]interface{},error) {
+  elems := e.event.Inputs.TupleElems()
+  if rv := reflect.ValueOf(values); rv.Kind() == reflect.Struct || (rv.Kind() == reflect.Ptr && rv.Elem().Kind() == reflect.Struct) {
+    structArgs, err := structValues(rv)
+    if err != nil {
+      return nil, err
+    }
+    values = structArgs
+  }
+  switch v := values.(type) {
+  case []interface{}:
+    if len(v) != len(elems) {
+      return nil, fmt.Errorf("Wrong values length")
+    }
+    return v, nil
+  case map[string]interface{}:
+    args := make([]interface{}, len(elems))
+    for i, elem := range elems {
+      value, ok := v[paramKey(elem, i)]
+      if !ok {
+        return nil, fmt.Errorf("missing param %s", paramKey(elem, i))
+      }
+      args[i] = value
+    }
+    return args, nil
+  default:
+    return nil, fmt.Errorf("Wrong values")
+  }
+}
+
+// Decode returns the params of an event notice by name (or position if
+// unnamed), the indexed strings and bytes are their hash
+func (e *Event) Decode(payloadHex string) (map[string]interface{},error) {
+  topics, data, err := decodeEventPayload(payloadHex)
+  if err != nil {
+    return nil, fmt.Errorf("Decode: %s", err)
+  }
+  if len(topics) == 0 || topics[0] != e.event.ID() {
+    return nil, fmt.Errorf("Decode: topic does not match")
+  }
+  topics = topics[1:]
+
+  elems := e.event.Inputs.TupleElems()
+  dataFields := make([]string,0)
+  for i, elem := range elems {
+    if !elem.Indexed {
+      dataFields = append(dataFields, elem.Elem.Format(true) + " " + fmt.Sprintf("f%d",i))
+    }
+  }
+  if len(topics) != len(elems) - len(dataFields) {
+    return nil, fmt.Errorf("Decode: wrong number of topics")
+  }
+  dataType, err := abi.NewType("tuple("+ strings.Join(dataFields, ",") +")")
+  if err != nil {
+    return nil, fmt.Errorf("Decode: %s", err)
+  }
+  var dataValues map[string]interface{}
+  if len(dataFields) > 0 {
+    decoded, err := abi.Decode(dataType, data)
+    if err != nil {
+      return nil, fmt.Errorf("Decode: %s", err)
+    }
+    dataValues, _ = decoded.(map[string]interface{})
+  }
+
+  result := make(map[string]interface{})
+  for i, elem := range elems {
+    if !elem.Indexed {
+      result[paramKey(elem, i)] = dataValues[fmt.Sprintf("f%d",i)]
+      continue
+    }
+    value, err := decodeTopic(elem.Elem, topics[0])
+    if err != nil {
+      return nil, fmt.Errorf("Decode: param %s: %s", paramKey(elem, i), err)
+    }
+    result[paramKey(elem, i)] = value
+    topics = topics[1:]
+  }
+  return result, nil
+}
+
+// Emit sends the event with values as a notice of req
+func (e *Event) Emit(req *hdl.Request, values interface{}) (uint64,error) {
+  payload, err := e.Encode(values)
+  if err != nil {
+    return 0, fmt.Errorf("Emit: %s: %s", e.Name, err)
+  }
+  return req.Notice(payload)
+}
+
+// DecodeEvent decodes a notice of one of events, matched by the topic
+func DecodeEvent(payloadHex string, events ...*Event) (*Event,map[string]interface{},error) {
+  topics, _, err := decodeEventPayload(payloadHex)
+  if err != nil {
+    return nil, nil, fmt.Errorf("DecodeEvent: %s", err)
+  }
+  if len(topics) == 0 {
+    return nil, nil, fmt.Errorf("DecodeEvent: no topics")
+  }
+  for _, event := range events {
+    if topics[0] == event.event.ID() {
+      params, err := event.Decode(payloadHex)
+      return event, params, err
+    }
+  }
+  return nil, nil, fmt.Errorf("DecodeEvent: unknown topic %s", topics[0])
+}
+
+func decodeEventPayload(payloadHex string) ([]ethgo.Hash,[]byte,error) {
+  payloadBytes, err := rollups.Hex2Bin(payloadHex)
+  if err != nil {
+    return nil, nil, err
+  }
+  decoded, err := abi.Decode(eventPayloadType, payloadBytes)
+  if err != nil {
+    return nil, nil, err
+  }
+  decodedMap, ok := decoded.(map[string]interface{})
+  if !ok {
+    return nil, nil, fmt.Errorf("convert decoded payload to map error")
+  }
+  rawTopics, okTopics := decodedMap["topics"].([][32]byte)
+  data, okData := decodedMap["data"].([]byte)
+  if !okTopics || !okData {
+    return nil, nil, fmt.Errorf("wrong event payload")
+  }
+  topics := make([]ethgo.Hash, len(rawTopics))
+  for i, topic := range rawTopics {
+    topics[i] = topic
+  }
+  return topics, data, nil
+}
+
+func encodeTopic(typ *abi.Type, value interface{}) (ethgo.Hash,error) {
+  var topic ethgo.Hash
+  switch typ.Kind() {
+  case abi.KindString, abi.KindBytes:
+    // dynamic values are indexed by their hash
+    var valueBytes []byte
+    switch v := value.(type) {
+    case string:
+      valueBytes = []byte(v)
+    case []byte:
+      valueBytes = v
+    default:
+      return topic, fmt.Errorf("expected %s, got %T", typ, value)
+    }
+    copy(topic[:], ethgo.Keccak256(valueBytes))
+  default:
+    encoded, err := abi.Encode(value, typ)
+    if err != nil {
+      return topic, err
+    }
+    copy(topic[:], encoded)
+  }
+  return topic, nil
+}
+
+func decodeTopic(typ *abi.Type, topic ethgo.Hash) (interface{},error) {
+  if typ.Kind() == abi.KindString || typ.Kind() == abi.KindBytes {
+    return topic, nil
+  }
+  return abi.Decode(typ, topic[:])
+}
+
+// paramKey is the name of the param, or its position if unnamed
+func paramKey(elem *abi.TupleElem, i int) string {
+  if elem.Name == "" {
+    return strconv.Itoa(i)
+  }
+  return elem.Name
+}
@@ -0,0 +1,150 @@
+package abihandler
+
+import (
+  "math/big"
+  "reflect"
+  "strings"
+  "testing"
+
+  "github.com/umbracle/ethgo"
+)
+
+// word returns the 32 bytes hex of a topic or an abi word, without 0x
+func word(hex string) string {
+  hex = strings.TrimPrefix(hex, "0x")
+  return strings.Repeat("0", 64-len(hex)) + hex
+}
+
+func TestEventTopic(t *testing.T) {
+  tests := []struct {
+    declaration string
+    signature string
+    topic string
+  }{
+    {"event Transfer(address indexed from, address indexed to, uint256 value)", "Transfer(address,address,uint256)",
+      "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"},
+    {"Approval(address indexed owner, address indexed spender, uint256 value)", "Approval(address,address,uint256)",
+      "0x8c5be1e5ebec7d5bd14f71427d1e84f3dd0314c0f7b2291e5b200ac8c7c3b925"},
+  }
+  for _, tt := range tests {
+    event := NewEvent(tt.declaration)
+    if event.Signature() != tt.signature || event.Topic != tt.topic {
+      t.Errorf("%s: %s %s, expected %s %s", tt.declaration, event.Signature(), event.Topic, tt.signature, tt.topic)
+    }
+  }
+
+  invalid := []string{
+    "event (uint256 a)",
+    "event A(uint256 indexed a, uint256 indexed b, uint256 indexed c, uint256 indexed d)",
+    "event A(uint256[] indexed a)",
+    "event A(uint256[2] indexed a)",
+    "event A(tuple(uint256 x) indexed a)",
+  }
+  for _, declaration := range invalid {
+    mustPanic(t, declaration, func() { NewEvent(declaration) })
+  }
+}
+
+// TestEventEncode checks the notice is abi.encode(bytes32[] topics, bytes data)
+// with the topics of the contract log
+func TestEventEncode(t *testing.T) {
+  from := "0xf39fd6e51aad88f6f4ce6ab8827279cfffb92266"
+  to := "0x70997970c51812dc3a010c7d01b50e0d17dc79c8"
+  transfer := NewEvent("event Transfer(address indexed from, address indexed to, uint256 value)")
+  expected := "0x" + word("40") + word("c0") +
+    word("3") + word(transfer.Topic) + word(from) + word(to) +
+    word("20") + word("5")
+  payload, err := transfer.Encode([]interface{}{ethgo.HexToAddress(from), ethgo.HexToAddress(to), big.NewInt(5)})
+  if err != nil {
+    t.Fatal(err)
+  }
+  if payload != expected {
+    t.Errorf("payload %s, expected %s", payload, expected)
+  }
+
+  // the indexed strings and bytes are their keccak256 hash
+  named := NewEvent("event Named(string indexed name, bytes indexed data, uint256 value)")
+  payload, err = named.Encode(map[string]interface{}{"name": "hello", "data": []byte{}, "value": big.NewInt(1)})
+  if err != nil {
+    t.Fatal(err)
+  }
+  hello := "1c8aff950685c2ed4bc3174f3472287b56d9517b9c948127319a09a7a36deac8"
+  empty := "c5d2460186f7233c927e7db2dcc703c0e500b653ca82273b7bfad8045d85a470"
+  expected = "0x" + word("40") + word("c0") + word("3") + word(named.Topic) + hello + empty + word("20") + word("1")
+  if payload != expected {
+    t.Errorf("indexed dynamic params: payload %s, expected %s", payload, expected)
+  }
+  params, err := named.Decode(payload)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if params["name"] != ethgo.HexToHash(hello) || params["data"] != ethgo.HexToHash(empty) {
+    t.Errorf("decoded %v, expected the hashes of the indexed params", params)
+  }
+
+  for name, values := range map[string]interface{}{
+    "short list": []interface{}{ethgo.HexToAddress(from)},
+    "missing param": map[string]interface{}{"from": ethgo.HexToAddress(from), "to": ethgo.HexToAddress(to)},
+    "wrong indexed type": []interface{}{"x", ethgo.HexToAddress(to), big.NewInt(5)},
+    "not values": 5,
+  } {
+    if _, err := transfer.Encode(values); err == nil {
+      t.Errorf("%s: encoded", name)
+    }
+  }
+}
+
+type orderEvent struct {
+  Owner Address `abi:"address owner"`
+  Id *big.Int `abi:"uint256 id"`
+  Note string `abi:"string note"`
+  Amounts []*big.Int `abi:"uint256[] amounts"`
+  Filled bool `abi:"bool filled"`
+}
+
+func TestEventRoundTrip(t *testing.T) {
+  order := NewEvent("event Order(address indexed owner, uint256 indexed id, string note, uint256[] amounts, bool filled)")
+  cancel := NewEvent("event Cancel(uint256 indexed, string)")
+  value := orderEvent{Owner: Address{0x01}, Id: big.NewInt(3), Note: "buy", Amounts: []*big.Int{big.NewInt(1), big.NewInt(2)}, Filled: true}
+  expected := map[string]interface{}{"owner": value.Owner, "id": value.Id, "note": value.Note, "amounts": value.Amounts, "filled": true}
+
+  for name, values := range map[string]interface{}{
+    "struct": value,
+    "pointer": &value,
+    "map": expected,
+    "list": []interface{}{value.Owner, value.Id, value.Note, value.Amounts, value.Filled},
+  } {
+    payload, err := order.Encode(values)
+    if err != nil {
+      t.Errorf("%s: %s", name, err)
+      continue
+    }
+    event, params, err := DecodeEvent(payload, cancel, order)
+    if err != nil || event != order {
+      t.Errorf("%s: decoded %v: %v", name, event, err)
+      continue
+    }
+    if !reflect.DeepEqual(params, expected) {
+      t.Errorf("%s: decoded %v, expected %v", name, params, expected)
+    }
+  }
+
+  // the unnamed params are keyed by position
+  payload, err := cancel.Encode([]interface{}{big.NewInt(9), "late"})
+  if err != nil {
+    t.Fatal(err)
+  }
+  params, err := cancel.Decode(payload)
+  if err != nil || !reflect.DeepEqual(params, map[string]interface{}{"0": big.NewInt(9), "1": "late"}) {
+    t.Errorf("unnamed params: %v %v", params, err)
+  }
+  if _, err := order.Decode(payload); err == nil {
+    t.Errorf("decoded the notice of another event")
+  }
+  if _, _, err := DecodeEvent(payload, order); err == nil {
+    t.Errorf("decoded an unknown event")
+  }
+  if _, _, err := DecodeEvent("0x1234", order); err == nil {
+    t.Errorf("decoded an invalid payload")
+  }
+}
@@ -0,0 +1,41 @@
+package abihandler
+
+import (
+  "context"
+  "fmt"
+
+  hdl "github.com/prototyp3-dev/go-rollups/handler"
+  "github.com/prototyp3-dev/go-rollups/rollups"
+)
+
+// MulticallCodec is the route of the inputs with several calls, each call is
+// the payload of an input of the abi, json or uri routes
+var MulticallCodec = NewHeaderCodec("rollups", "Multicall", []string{"bytes[] calls"})
+
+// HandleMulticall adds the multicall route. The calls are routed in order as
+// inputs nested in the multicall input, with the same metadata, so their
+// outputs keep the calls order. A call that fails, or that no route handles,
+// rejects the whole input and the registered states are restored. A multicall
+// can't be nested in another one, e.g. as a call or as a signed input of one.
+func (h *AbiHandler) HandleMulticall() {
+  h.HandleAdvanceRouteContext(MulticallCodec, h.multicall)
+  h.HandleInspectRouteContext(MulticallCodec, h.multicall)
+}
+
+func (h *AbiHandler) multicall(ctx context.Context, req *hdl.Request) error {
+  calls, ok := req.Params["calls"].([][]byte)
+  if !ok {
+    return fmt.Errorf("Multicall: parameters error")
+  }
+  for parent := req.Parent(); parent != nil; parent = parent.Parent() {
+    if parent.Route == req.Route {
+      return fmt.Errorf("Multicall: nested multicall")
+    }
+  }
+  for i, call := range calls {
+    if err := h.Handler.ProcessNested(nil, rollups.Bin2Hex(call)); err != nil {
+      return fmt.Errorf("Multicall: call %d: %w", i, err)
+    }
+  }
+  return nil
+}
@@ -0,0 +1,120 @@
+package abihandler
+
+import (
+  "context"
+  "fmt"
+  "math/big"
+  "strings"
+  "testing"
+
+  hdl "github.com/prototyp3-dev/go-rollups/handler"
+  "github.com/prototyp3-dev/go-rollups/handler/handlertest"
+  "github.com/prototyp3-dev/go-rollups/rollups"
+)
+
+const sender = "0xf39fd6e51aad88f6f4ce6ab8827279cfffb92266"
+
+var (
+  addCodec = NewHeaderCodec("test", "Add", []string{"uint256 value"})
+  failCodec = NewHeaderCodec("test", "Fail", []string{})
+)
+
+// total is a registered state
+type total struct {
+  value int64
+}
+
+func (s *total) Snapshot() (interface{}, error) {
+  return s.value, nil
+}
+
+func (s *total) Restore(snapshot interface{}) error {
+  s.value = snapshot.(int64)
+  return nil
+}
+
+func mustEncode(codec *Codec, values ...interface{}) []byte {
+  payload, err := codec.Encode(values)
+  if err != nil {
+    panic(err)
+  }
+  call, err := rollups.Hex2Bin(payload)
+  if err != nil {
+    panic(err)
+  }
+  return call
+}
+
+func multicallPayload(calls ...[]byte) string {
+  return rollups.Bin2Hex(mustEncode(MulticallCodec, calls))
+}
+
+func TestMulticall(t *testing.T) {
+  state := &total{}
+  defaults := 0
+  abiHandler := NewAbiHandler()
+  abiHandler.Handler.RegisterState(state)
+  abiHandler.HandleMulticall()
+  abiHandler.HandleAdvanceRouteContext(addCodec, func(ctx context.Context, req *hdl.Request) error {
+    value := req.Params["value"].(*big.Int)
+    state.value += value.Int64()
+    _, err := req.Notice(rollups.Str2Hex(value.String()))
+    return err
+  })
+  abiHandler.HandleAdvanceRouteContext(failCodec, func(ctx context.Context, req *hdl.Request) error {
+    return fmt.Errorf("fail")
+  })
+  abiHandler.Handler.HandleDefault(func(payloadHex string) error {
+    defaults++
+    return nil
+  })
+  driver := handlertest.NewDriver(abiHandler.Handler)
+
+  add := func(value int64) []byte { return mustEncode(addCodec, big.NewInt(value)) }
+  tests := []struct {
+    name string
+    payload string
+    accepted bool
+    err string
+    notices []string
+    total int64
+  }{
+    {"all calls succeed", multicallPayload(add(1), add(2)), true, "", []string{"1", "2"}, 3},
+    {"no calls", multicallPayload(), true, "", nil, 3},
+    {"middle call fails", multicallPayload(add(1), mustEncode(failCodec), add(2)), false, "call 1: fail", nil, 3},
+    // the default handler doesn't handle the calls
+    {"unknown call", multicallPayload(add(1), []byte{0xde, 0xad, 0xbe, 0xef}), false, "call 1: ProcessNested", nil, 3},
+    {"nested multicall", multicallPayload(add(1), mustEncode(MulticallCodec, [][]byte{add(2)})), false, "nested multicall", nil, 3},
+    {"last call", multicallPayload(add(4)), true, "", []string{"4"}, 7},
+  }
+  for _, tt := range tests {
+    result := driver.Advance(sender, tt.payload)
+    if result.Accepted() != tt.accepted {
+      t.Errorf("%s: status %s, expected accepted %t: %v", tt.name, result.Status, tt.accepted, result.Err)
+    }
+    if tt.err != "" && (result.Err == nil || !strings.Contains(result.Err.Error(), tt.err)) {
+      t.Errorf("%s: error %v, expected %q", tt.name, result.Err, tt.err)
+    }
+    if tt.accepted {
+      var notices []string
+      for _, notice := range result.Notices {
+        payload, _ := rollups.Hex2Str(notice.Payload)
+        notices = append(notices, payload)
+      }
+      if strings.Join(notices, ",") != strings.Join(tt.notices, ",") {
+        t.Errorf("%s: notices %v, expected %v", tt.name, notices, tt.notices)
+      }
+    }
+    if state.value != tt.total {
+      t.Errorf("%s: total %d, expected %d", tt.name, state.value, tt.total)
+    }
+  }
+  if defaults != 0 {
+    t.Errorf("default handler called %d times for the calls", defaults)
+  }
+
+  // the default handler still handles the inputs
+  if result := driver.Advance(sender, "0xdeadbeef"); !result.Accepted() || defaults != 1 {
+    t.Errorf("unknown input: status %s, default handler called %d times", result.Status, defaults)
+  }
+}
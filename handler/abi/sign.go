@@ -0,0 +1,49 @@
+package abihandler
+
+import (
+  "fmt"
+  "strconv"
+
+  "github.com/umbracle/ethgo"
+  "github.com/umbracle/ethgo/wallet"
+)
+
+// PersonalSignHash returns the EIP-191 hash of message signed by
+// personal_sign: keccak256("\x19Ethereum Signed Message:\n" + len + message)
+func PersonalSignHash(message []byte) ethgo.Hash {
+  prefix := "\x19Ethereum Signed Message:\n" + strconv.Itoa(len(message))
+  var hash ethgo.Hash
+  copy(hash[:], ethgo.Keccak256(append([]byte(prefix), message...)))
+  return hash
+}
+
+// RecoverPersonalSign returns the address that signed message with
+// personal_sign
+func RecoverPersonalSign(message []byte, signature []byte) (Address,error) {
+  signer, err := RecoverSigner(PersonalSignHash(message), signature)
+  if err != nil {
+    return Address{}, fmt.Errorf("RecoverPersonalSign: %s", err)
+  }
+  return signer, nil
+}
+
+// RecoverSigner returns the address that signed hash, the signature is r, s
+// and v (27 or 28, or 0 or 1)
+func RecoverSigner(hash ethgo.Hash, signature []byte) (Address,error) {
+  if len(signature) != 65 {
+    return Address{}, fmt.Errorf("RecoverSigner: wrong signature length")
+  }
+  v := signature[64]
+  if v >= 27 {
+    v -= 27
+  }
+  if v > 1 {
+    return Address{}, fmt.Errorf("RecoverSigner: invalid signature v")
+  }
+  sig := append(append([]byte{}, signature[:64]...), v)
+  signer, err := wallet.Ecrecover(hash[:], sig)
+  if err != nil {
+    return Address{}, fmt.Errorf("RecoverSigner: %s", err)
+  }
+  return signer, nil
+}
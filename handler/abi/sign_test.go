@@ -0,0 +1,66 @@
+package abihandler
+
+import (
+  "testing"
+
+  "github.com/prototyp3-dev/go-rollups/rollups"
+)
+
+func TestPersonalSignHash(t *testing.T) {
+  tests := []struct {
+    message string
+    hash string
+  }{
+    {"hello", "0x50b2c43fd39106bafbba0da34fc430e1f91e3c96ea2acee2bc34119f92b37750"},
+    {"Some data", "0x1da44b586eb0729ff70a73c326926f6ed5a25f5b056e7f47fbc6e58d86871655"},
+  }
+  for _, tt := range tests {
+    hash := PersonalSignHash([]byte(tt.message))
+    if got := rollups.Bin2Hex(hash[:]); got != tt.hash {
+      t.Errorf("%q: hash %s, expected %s", tt.message, got, tt.hash)
+    }
+  }
+}
+
+// TestRecoverPersonalSign uses the signature of the web3.js accounts.sign
+// example, by 0x2c7536E3605D9C16a7a3D7b1898e529396a65c23
+func TestRecoverPersonalSign(t *testing.T) {
+  signature := "0xb91467e570a6466aa9e9876cbcd013baba02900b8979d43fe208a4a4f339f5fd" +
+    "6007e74cd82e037b800186422fc2da167c747ef045e5d18a5f5d4300f8e1a029"
+  signer := "0x2c7536e3605d9c16a7a3d7b1898e529396a65c23"
+  tests := []struct {
+    name string
+    message string
+    signature string
+    signer string
+    err bool
+  }{
+    {"v 28", "Some data", signature + "1c", signer, false},
+    {"v 1", "Some data", signature + "01", signer, false},
+    {"other message", "Some other data", signature + "1c", "", false},
+    {"invalid v", "Some data", signature + "1d", "", true},
+    {"short signature", "Some data", signature, "", true},
+  }
+  for _, tt := range tests {
+    sig, err := rollups.Hex2Bin(tt.signature)
+    if err != nil {
+      t.Fatal(err)
+    }
+    address, err := RecoverPersonalSign([]byte(tt.message), sig)
+    if tt.err {
+      if err == nil {
+        t.Errorf("%s: expected error", tt.name)
+      }
+      continue
+    }
+    if err != nil {
+      t.Errorf("%s: %s", tt.name, err)
+      continue
+    }
+    if got := Address2Hex(address); tt.signer != "" && got != tt.signer {
+      t.Errorf("%s: signer %s, expected %s", tt.name, got, tt.signer)
+    } else if tt.signer == "" && got == signer {
+      t.Errorf("%s: recovered the signer of another message", tt.name)
+    }
+  }
+}
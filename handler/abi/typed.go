@@ -0,0 +1,225 @@
+package abihandler
+
+import (
+  "context"
+  "errors"
+  "fmt"
+  "reflect"
+  "strconv"
+  "strings"
+
+  hdl "github.com/prototyp3-dev/go-rollups/handler"
+
+  "github.com/lynoferraz/abigo"
+)
+
+// FieldError is the error of a struct field that can't be decoded
+type FieldError struct {
+  Field string
+  Type string
+  Err error
+}
+
+func (e *FieldError) Error() string {
+  return fmt.Sprintf("field %s (%s): %s", e.Field, e.Type, e.Err)
+}
+
+func (e *FieldError) Unwrap() error {
+  return e.Err
+}
+
+// structFields returns the indexes and the abi tags of the tagged fields of
+// the struct t, e.g. Amount *big.Int `abi:"uint256"`
+func structFields(t reflect.Type) ([]int,[]string,error) {
+  for t.Kind() == reflect.Ptr {
+    t = t.Elem()
+  }
+  if t.Kind() != reflect.Struct {
+    return nil, nil, fmt.Errorf("%s is not a struct", t)
+  }
+  var indexes []int
+  var fields []string
+  for i := 0; i < t.NumField(); i++ {
+    tag := t.Field(i).Tag.Get("abi")
+    if tag == "" || tag == "-" {
+      continue
+    }
+    if !t.Field(i).IsExported() {
+      return nil, nil, fmt.Errorf("field %s is not exported", t.Field(i).Name)
+    }
+    indexes = append(indexes, i)
+    fields = append(fields, tag)
+  }
+  // an empty struct is a route without params
+  if len(fields) == 0 && t.NumField() > 0 {
+    return nil, nil, fmt.Errorf("%s has no abi fields", t)
+  }
+  if _, err := abi.NewType("tuple("+ strings.Join(fields, ",") +")"); err != nil {
+    return nil, nil, fmt.Errorf("%s: %s", t, err)
+  }
+  return indexes, fields, nil
+}
+
+// StructFields returns the codec fields of the abi tags of the struct v
+func StructFields(v interface{}) []string {
+  _, fields, err := structFields(reflect.TypeOf(v))
+  if err != nil {
+    panic(fmt.Sprintf("abi handler: %s", err))
+  }
+  return fields
+}
+
+func NewStructCodec(v interface{}) *Codec {
+  return NewCodec(StructFields(v))
+}
+
+func NewHeaderStructCodec(framework string, method string, v interface{}) *Codec {
+  return NewHeaderCodec(framework, method, StructFields(v))
+}
+
+// DecodeParams sets the fields of the struct pointed by out with the decoded
+// params of a route, the tagged fields in order are the codec fields
+func DecodeParams(params map[string]interface{}, out interface{}) error {
+  v := reflect.ValueOf(out)
+  if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+    return fmt.Errorf("DecodeParams: out must be a pointer to struct")
+  }
+  if err := decodeStruct(params, v.Elem(), ""); err != nil {
+    return fmt.Errorf("DecodeParams: %w", err)
+  }
+  return nil
+}
+
+func decodeStruct(params map[string]interface{}, v reflect.Value, prefix string) error {
+  indexes, fields, err := structFields(v.Type())
+  if err != nil {
+    return err
+  }
+  typ := GetType(fields)
+  for i, elem := range typ.TupleElems() {
+    field := v.Type().Field(indexes[i])
+    key := elem.Name
+    if key == "" {
+      key = strconv.Itoa(i)
+    }
+    value, ok := params[key]
+    if !ok {
+      return &FieldError{Field: prefix + field.Name, Type: elem.Elem.String(), Err: fmt.Errorf("missing param %s", key)}
+    }
+    if err := setField(v.Field(indexes[i]), value, prefix + field.Name); err != nil {
+      var fieldErr *FieldError
+      if errors.As(err, &fieldErr) {
+        return err
+      }
+      return &FieldError{Field: prefix + field.Name, Type: elem.Elem.String(), Err: err}
+    }
+  }
+  return nil
+}
+
+func setField(field reflect.Value, value interface{}, name string) error {
+  if value == nil {
+    return fmt.Errorf("nil value")
+  }
+  rv := reflect.ValueOf(value)
+  switch {
+  case rv.Type().AssignableTo(field.Type()):
+    field.Set(rv)
+  case rv.Kind() == field.Kind() && rv.Type().ConvertibleTo(field.Type()):
+    field.Set(rv.Convert(field.Type()))
+  case rv.Kind() == reflect.Map && field.Kind() == reflect.Struct:
+    nested, ok := value.(map[string]interface{})
+    if !ok {
+      return fmt.Errorf("expected tuple, got %T", value)
+    }
+    return decodeStruct(nested, field, name + ".")
+  default:
+    return fmt.Errorf("expected %s, got %T", field.Type(), value)
+  }
+  return nil
+}
+
+// structValues returns the values of the tagged fields of a struct in the
+// codec order, nested structs are converted to tuples
+func structValues(v reflect.Value) ([]interface{},error) {
+  for v.Kind() == reflect.Ptr {
+    if v.IsNil() {
+      return nil, fmt.Errorf("nil struct")
+    }
+    v = v.Elem()
+  }
+  indexes, _, err := structFields(v.Type())
+  if err != nil {
+    return nil, err
+  }
+  values := make([]interface{}, len(indexes))
+  for i, index := range indexes {
+    field := v.Field(index)
+    if field.Kind() == reflect.Struct {
+      if _, _, err := structFields(field.Type()); err == nil {
+        if values[i], err = structValues(field); err != nil {
+          return nil, err
+        }
+        continue
+      }
+    }
+    values[i] = field.Interface()
+  }
+  return values, nil
+}
+
+// namedFields returns the fields of typ with the names used as param keys
+func namedFields(typ *abi.Type) string {
+  var fields []string
+  for _, elem := range typ.TupleElems() {
+    fields = append(fields, strings.TrimSpace(elem.Elem.String() + " " + elem.Name))
+  }
+  return strings.Join(fields, ",")
+}
+
+func checkStructCodec(t reflect.Type, routeCodec *Codec) {
+  if t.Kind() != reflect.Struct {
+    panic(fmt.Sprintf("abi handler: %s is not a struct", t))
+  }
+  _, fields, err := structFields(t)
+  if err != nil {
+    panic(fmt.Sprintf("abi handler: %s", err))
+  }
+  codecFields := namedFields(routeCodec.typ)
+  structFields := namedFields(GetType(fields))
+  if codecFields != structFields {
+    panic(fmt.Sprintf("abi handler: codec fields (%s) don't match %s fields (%s)", codecFields, t, structFields))
+  }
+}
+
+// TypedHandlerFunc is a context handler that receives the route params
+// decoded into args
+type TypedHandlerFunc[T any] func(ctx context.Context, req *hdl.Request, args T) error
+
+func typedHandler[T any](routeCodec *Codec, fnHandle TypedHandlerFunc[T]) hdl.ContextHandlerFunc {
+  if fnHandle == nil {
+    panic("abi handler: nil handler")
+  }
+  checkStructCodec(reflect.TypeOf((*T)(nil)).Elem(), routeCodec)
+  return func(ctx context.Context, req *hdl.Request) error {
+    var args T
+    if err := DecodeParams(req.Params, &args); err != nil {
+      return err
+    }
+    return fnHandle(ctx, req, args)
+  }
+}
+
+// HandleAdvanceRouteTyped adds an advance route whose params are decoded into
+// a T struct. It panics if the codec fields are not the abi fields of T.
+func HandleAdvanceRouteTyped[T any](h *AbiHandler, routeCodec *Codec, fnHandle TypedHandlerFunc[T]) {
+  h.HandleAdvanceRouteContext(routeCodec, typedHandler(routeCodec, fnHandle))
+}
+
+func HandleInspectRouteTyped[T any](h *AbiHandler, routeCodec *Codec, fnHandle TypedHandlerFunc[T]) {
+  h.HandleInspectRouteContext(routeCodec, typedHandler(routeCodec, fnHandle))
+}
+
+func HandleFixedAddressAdvanceTyped[T any](h *AbiHandler, address string, routeCodec *Codec, fnHandle TypedHandlerFunc[T]) {
+  h.HandleFixedAddressAdvanceContext(address, routeCodec, typedHandler(routeCodec, fnHandle))
+}
@@ -0,0 +1,164 @@
+package abihandler
+
+import (
+  "context"
+  "errors"
+  "math/big"
+  "reflect"
+  "testing"
+
+  hdl "github.com/prototyp3-dev/go-rollups/handler"
+  "github.com/prototyp3-dev/go-rollups/handler/handlertest"
+)
+
+type pair struct {
+  Id uint64 `abi:"uint64 id"`
+  Data []byte `abi:"bytes data"`
+}
+
+type order struct {
+  Owner Address `abi:"address owner"`
+  Amount *big.Int `abi:"uint256 amount"`
+  Pair pair `abi:"tuple(uint64 id,bytes data) pair"`
+  // untagged and skipped fields aren't codec fields
+  Note string
+  Skipped int `abi:"-"`
+}
+
+type unnamed struct {
+  To Address `abi:"address"`
+  Amount *big.Int `abi:"uint256"`
+}
+
+func orderParams() map[string]interface{} {
+  return map[string]interface{}{
+    "owner": Address{0x01},
+    "amount": big.NewInt(10),
+    "pair": map[string]interface{}{"id": uint64(7), "data": []byte{0xaa}},
+  }
+}
+
+func TestDecodeParams(t *testing.T) {
+  expected := order{Owner: Address{0x01}, Amount: big.NewInt(10), Pair: pair{Id: 7, Data: []byte{0xaa}}}
+  tests := []struct {
+    name string
+    change func(params map[string]interface{})
+    // the path and type of the FieldError, empty if it decodes
+    field string
+    typ string
+  }{
+    {"valid", func(params map[string]interface{}) {}, "", ""},
+    {"extra param", func(params map[string]interface{}) { params["other"] = 1 }, "", ""},
+    {"missing param", func(params map[string]interface{}) { delete(params, "amount") }, "Amount", "uint256"},
+    {"nil value", func(params map[string]interface{}) { params["owner"] = nil }, "Owner", "address"},
+    {"wrong type", func(params map[string]interface{}) { params["amount"] = "10" }, "Amount", "uint256"},
+    {"tuple not a map", func(params map[string]interface{}) { params["pair"] = 5 }, "Pair", "tuple(uint64,bytes)"},
+    {"nested missing param", func(params map[string]interface{}) {
+      delete(params["pair"].(map[string]interface{}), "data")
+    }, "Pair.Data", "bytes"},
+    {"nested wrong type", func(params map[string]interface{}) {
+      params["pair"].(map[string]interface{})["id"] = "7"
+    }, "Pair.Id", "uint64"},
+  }
+  for _, tt := range tests {
+    params := orderParams()
+    tt.change(params)
+    var got order
+    err := DecodeParams(params, &got)
+    if tt.field == "" {
+      if err != nil {
+        t.Errorf("%s: %s", tt.name, err)
+      } else if !reflect.DeepEqual(got, expected) {
+        t.Errorf("%s: decoded %+v, expected %+v", tt.name, got, expected)
+      }
+      continue
+    }
+    var fieldErr *FieldError
+    if !errors.As(err, &fieldErr) {
+      t.Errorf("%s: error %v, expected a FieldError", tt.name, err)
+      continue
+    }
+    if fieldErr.Field != tt.field || fieldErr.Type != tt.typ {
+      t.Errorf("%s: field %s (%s), expected %s (%s)", tt.name, fieldErr.Field, fieldErr.Type, tt.field, tt.typ)
+    }
+  }
+
+  // the unnamed fields are keyed by their index
+  var args unnamed
+  if err := DecodeParams(map[string]interface{}{"0": Address{0x02}, "1": big.NewInt(3)}, &args); err != nil ||
+    args.To != (Address{0x02}) || args.Amount.Int64() != 3 {
+    t.Errorf("unnamed fields: %+v %v", args, err)
+  }
+
+  for name, out := range map[string]interface{}{"struct": order{}, "nil pointer": (*order)(nil), "pointer to int": new(int)} {
+    if err := DecodeParams(orderParams(), out); err == nil {
+      t.Errorf("decoded into %s", name)
+    }
+  }
+}
+
+// TestStructCodec encodes a struct with nested tuples and decodes it back
+func TestStructCodec(t *testing.T) {
+  codec := NewHeaderStructCodec("test", "Order", order{})
+  if !reflect.DeepEqual(codec.Fields, []string{"address owner", "uint256 amount", "tuple(uint64 id,bytes data) pair"}) {
+    t.Errorf("fields %v", codec.Fields)
+  }
+  value := order{Owner: Address{0x03}, Amount: big.NewInt(99), Pair: pair{Id: 1, Data: []byte("x")}}
+  payload, err := codec.Encode(value)
+  if err != nil {
+    t.Fatal(err)
+  }
+  params, err := codec.Decode(payload)
+  if err != nil {
+    t.Fatal(err)
+  }
+  var got order
+  if err := DecodeParams(params, &got); err != nil {
+    t.Fatal(err)
+  }
+  if !reflect.DeepEqual(got, value) {
+    t.Errorf("decoded %+v, expected %+v", got, value)
+  }
+
+  invalid := map[string]interface{}{
+    "untagged": struct{ A int }{},
+    "unexported": struct{ a int `abi:"uint256"` }{},
+    "invalid type": struct{ A int `abi:"uint7"` }{},
+    "not a struct": 1,
+  }
+  for name, v := range invalid {
+    mustPanic(t, name, func() { StructFields(v) })
+  }
+}
+
+func TestTypedRoute(t *testing.T) {
+  noop := func(ctx context.Context, req *hdl.Request, args order) error { return nil }
+  mismatches := map[string]*Codec{
+    "missing field": NewHeaderCodec("test", "Order", []string{"address owner", "uint256 amount"}),
+    "other name": NewHeaderCodec("test", "Order", []string{"address owner", "uint256 value", "tuple(uint64 id,bytes data) pair"}),
+    "other order": NewHeaderCodec("test", "Order", []string{"uint256 amount", "address owner", "tuple(uint64 id,bytes data) pair"}),
+    "other tuple": NewHeaderCodec("test", "Order", []string{"address owner", "uint256 amount", "tuple(uint64 id) pair"}),
+  }
+  for name, codec := range mismatches {
+    mustPanic(t, name, func() { HandleAdvanceRouteTyped(NewAbiHandler(), codec, noop) })
+  }
+
+  var got order
+  codec := NewHeaderStructCodec("test", "Order", order{})
+  abiHandler := NewAbiHandler()
+  HandleAdvanceRouteTyped(abiHandler, codec, func(ctx context.Context, req *hdl.Request, args order) error {
+    got = args
+    return nil
+  })
+  value := order{Owner: Address{0x04}, Amount: big.NewInt(1), Pair: pair{Id: 2, Data: []byte{}}}
+  payload, err := codec.Encode(&value)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if result := handlertest.NewDriver(abiHandler.Handler).Advance(sender, payload); !result.Accepted() {
+    t.Fatalf("rejected: %v", result.Err)
+  }
+  if !reflect.DeepEqual(got, value) {
+    t.Errorf("handler got %+v, expected %+v", got, value)
+  }
+}
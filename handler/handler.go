@@ -5,10 +5,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
-	"net/http"
+	"log/slog"
+	"math/big"
 	"os"
-	"strconv"
 	"strings"
 
 	"github.com/prototyp3-dev/go-rollups/rollups"
@@ -26,12 +25,12 @@ const (
 )
 
 type NetworkAddresses struct {
-  DappAddressRelay string           `json:"DAPP_RELAY_ADDRESS"`
-  EtherPortalAddress string         `json:"ETHER_PORTAL_ADDRESS"`
-  Erc20PortalAddress string         `json:"ERC20_PORTAL_ADDRESS"`
-  Erc721PortalAddress string        `json:"ERC721_PORTAL_ADDRESS"`
-  Erc1155SinglePortalAddress string `json:"ERC1155_SINGLE_PORTAL_ADDRESS"`
-  Erc1155BatchPortalAddress string  `json:"ERC1155_BATCH_PORTAL_ADDRESS"`
+  DappAddressRelay string           `json:"DAPP_RELAY_ADDRESS,omitempty" yaml:"DAPP_RELAY_ADDRESS,omitempty"`
+  EtherPortalAddress string         `json:"ETHER_PORTAL_ADDRESS" yaml:"ETHER_PORTAL_ADDRESS"`
+  Erc20PortalAddress string         `json:"ERC20_PORTAL_ADDRESS" yaml:"ERC20_PORTAL_ADDRESS"`
+  Erc721PortalAddress string        `json:"ERC721_PORTAL_ADDRESS" yaml:"ERC721_PORTAL_ADDRESS"`
+  Erc1155SinglePortalAddress string `json:"ERC1155_SINGLE_PORTAL_ADDRESS" yaml:"ERC1155_SINGLE_PORTAL_ADDRESS"`
+  Erc1155BatchPortalAddress string  `json:"ERC1155_BATCH_PORTAL_ADDRESS" yaml:"ERC1155_BATCH_PORTAL_ADDRESS"`
 }
 
 type AdvanceHandlerFunc func(*rollups.Metadata,string) error
@@ -75,12 +74,18 @@ type Handler struct {
   RoutesInspectHandlers []*RoutesInspectHandler
   RollupsFixedAddressHandler *AdvanceHandler
   FixedAddressHandlers map[string]*RoutesAdvanceHandler
+  Transport rollups.Transport
+  RollupsVersion rollups.Version
+  RollupsAddresses NetworkAddresses
+  PanicPolicy PanicPolicy
+  journal io.Writer
+  recording *recordingTransport
+  middlewares []Middleware
+  logger *slog.Logger
+  request *Request
+  states []Stateful
 }
 
-var ErrorLogger *log.Logger
-var TraceLogger *log.Logger
-var DebugLogger *log.Logger
-
 var LocalHandler = NewSimpleHandler()
 
 func (h *Handler) SetDebug() {
@@ -91,6 +96,17 @@ func (h *Handler) SetLogLevel(logLevel LogLevel) {
   h.LogLevel = logLevel
 }
 
+func (h *Handler) SetRollupsVersion(version rollups.Version) {
+  if version != rollups.V1 && version != rollups.V2 {
+    panic("rollups handler: invalid rollups version")
+  }
+  h.RollupsVersion = version
+}
+
+func (h *Handler) IsV2() bool {
+  return h.RollupsVersion == rollups.V2
+}
+
 func HandleDefault(fnHandle InspectHandlerFunc) {
   LocalHandler.HandleDefault(fnHandle)
 }
@@ -130,7 +146,7 @@ func HandleRollupsFixedAddresses(fnHandle AdvanceHandlerFunc) {
   LocalHandler.HandleRollupsFixedAddresses(fnHandle)
 }
 func (h *Handler) HandleRollupsFixedAddresses(fnHandle AdvanceHandlerFunc) {
-  if RollupsAddresses == (NetworkAddresses{}) {
+  if h.Addresses() == (NetworkAddresses{}) {
 		panic("rollups handler: uninitialized RollupsAddresses")
   }
 	if fnHandle == nil {
@@ -154,7 +170,9 @@ func (h *Handler) HandleFixedAddress(address string, fnHandle AdvanceHandlerFunc
     h.FixedAddressHandlers = make(map[string]*RoutesAdvanceHandler)
   }
   fnHandler := RoutesAdvanceHandler{func(metadata *rollups.Metadata,payloadHex string) (error,bool) {
-    return fnHandle(metadata,payloadHex),true
+    return h.Dispatch("fixed", nil, func(req *Request) error {
+      return fnHandle(req.Metadata,req.Payload)
+    }),true
   }}
   h.FixedAddressHandlers[strings.ToLower(address)] = &fnHandler
 }
@@ -198,82 +216,153 @@ func (h *Handler) HandleInspectRoutes(fnHandle RoutesInspectHandlerFunc) {
   h.RoutesInspectHandlers = append(h.RoutesInspectHandlers,&fnHandler)
 }
 
+func (h *Handler) getTransport() (rollups.Transport,error) {
+  if h.recording != nil {
+    return h.recording,nil
+  }
+  if h.Transport == nil {
+    // the default transport doesn't set the global rollup server, so each
+    // handler keeps its own
+    server := rollups.GetRollupServer()
+    if server == "" {
+      server = os.Getenv("ROLLUP_HTTP_SERVER_URL")
+    }
+    if server == "" {
+      return nil,fmt.Errorf("rollup server not defined")
+    }
+    h.Transport = rollups.NewHttpTransport(server)
+  }
+  return h.Transport,nil
+}
+
+func (h *Handler) SetTransport(transport rollups.Transport) {
+  if transport == nil {
+    panic("rollups handler: nil transport")
+  }
+  h.Transport = transport
+}
+
 func (h *Handler) SendNotice(payloadHex string) (uint64,error) {
   notice := &rollups.Notice{Payload:payloadHex}
-  if h.LogLevel >= Trace {TraceLogger.Println("Sending notice status",notice)}
-  res, err := rollups.SendNotice(notice)
+  h.trace("Sending notice", "notice", notice)
+  transport, err := h.getTransport()
   if err != nil {
-    return 0,fmt.Errorf("SendNotice: error making http request: %s", err)
+    return 0,fmt.Errorf("SendNotice: %s", err)
   }
- 
-  body, err := io.ReadAll(res.Body)
+  index, err := transport.Notice(notice)
   if err != nil {
-    return 0,fmt.Errorf("SendNotice: could not read response body: %s", err)
+    return 0,fmt.Errorf("SendNotice: error sending notice: %w", err)
   }
-  
-  var indexRes rollups.IndexResponse
-  err = json.Unmarshal(body, &indexRes)
-  if err != nil {
-    return 0,fmt.Errorf("SendNotice: Error unmarshaling body: %s", err)
-  }
-  if h.LogLevel >= Debug {DebugLogger.Println("Received notice status", strconv.Itoa(res.StatusCode), "body", string(body), "index", strconv.FormatUint(indexRes.Index,10))}
+  h.Logger().Debug("Sent notice", "index", index)
 
-  return indexRes.Index,nil
+  return index,nil
 }
 
 func (h *Handler) SendVoucher(destination string, payloadHex string) (uint64,error) {
   voucher := &rollups.Voucher{Destination: destination, Payload: payloadHex}
-  if h.LogLevel >= Trace {TraceLogger.Println("Sending voucher status",voucher)}
-  res, err := rollups.SendVoucher(voucher)
+  h.trace("Sending voucher", "voucher", voucher)
+  transport, err := h.getTransport()
+  if err != nil {
+    return 0,fmt.Errorf("SendVoucher: %s", err)
+  }
+  index, err := transport.Voucher(voucher)
+  if err != nil {
+    return 0,fmt.Errorf("SendVoucher: error sending voucher: %w", err)
+  }
+  h.Logger().Debug("Sent voucher", "index", index)
+
+  return index,nil
+}
+
+// SendPayableVoucher sends a voucher that transfers value (in wei) from the
+// application contract to destination (v2 only)
+func (h *Handler) SendPayableVoucher(destination string, value *big.Int, payloadHex string) (uint64,error) {
+  if !h.IsV2() {
+    return 0,fmt.Errorf("SendPayableVoucher: payable vouchers require rollups v2")
+  }
+  voucher := &rollups.Voucher{Destination: destination, Value: rollups.Uint256Hex(value), Payload: payloadHex}
+  h.trace("Sending voucher", "voucher", voucher)
+  transport, err := h.getTransport()
+  if err != nil {
+    return 0,fmt.Errorf("SendPayableVoucher: %s", err)
+  }
+  index, err := transport.Voucher(voucher)
   if err != nil {
-    return 0,fmt.Errorf("SendVoucher: error making http request: %s", err)
+    return 0,fmt.Errorf("SendPayableVoucher: error sending voucher: %w", err)
+  }
+  h.Logger().Debug("Sent voucher", "index", index)
+
+  return index,nil
+}
+
+func (h *Handler) SendDelegateCallVoucher(destination string, payloadHex string) (uint64,error) {
+  if !h.IsV2() {
+    return 0,fmt.Errorf("SendDelegateCallVoucher: delegate call vouchers require rollups v2")
   }
- 
-  body, err := io.ReadAll(res.Body)
+  voucher := &rollups.DelegateCallVoucher{Destination: destination, Payload: payloadHex}
+  h.trace("Sending delegate call voucher", "voucher", voucher)
+  transport, err := h.getTransport()
   if err != nil {
-    return 0,fmt.Errorf("SendVoucher: could not read response body: %s", err)
+    return 0,fmt.Errorf("SendDelegateCallVoucher: %s", err)
   }
-  
-  var indexRes rollups.IndexResponse
-  err = json.Unmarshal(body, &indexRes)
+  index, err := transport.DelegateCallVoucher(voucher)
   if err != nil {
-    return 0,fmt.Errorf("SendVoucher: Error unmarshaling body: %s", err)
+    return 0,fmt.Errorf("SendDelegateCallVoucher: error sending voucher: %w", err)
   }
-  if h.LogLevel >= Debug {DebugLogger.Println("Received voucher status", strconv.Itoa(res.StatusCode), "body", string(body), "index", strconv.FormatUint(indexRes.Index,10))}
+  h.Logger().Debug("Sent delegate call voucher", "index", index)
 
-  return indexRes.Index,nil
+  return index,nil
+}
+
+// Gio issues a generic I/O request for the data identified by idHex in domain.
+// A response with a non ok code is returned along with a *rollups.GioError.
+func (h *Handler) Gio(domain uint16, idHex string) (*rollups.GioResponse,error) {
+  request := &rollups.GioRequest{Domain: domain, Id: idHex}
+  h.trace("Sending gio request", "request", request)
+  transport, err := h.getTransport()
+  if err != nil {
+    return nil,fmt.Errorf("Gio: %s", err)
+  }
+  response, err := transport.Gio(request)
+  if err != nil {
+    return nil,fmt.Errorf("Gio: error sending request: %w", err)
+  }
+  h.Logger().Debug("Received gio response", "code", response.Code)
+  if response.Code != rollups.GioResponseOk {
+    return response,&rollups.GioError{Domain: domain, Id: idHex, Code: response.Code, Data: response.Data}
+  }
+
+  return response,nil
 }
 
 func (h *Handler) SendReport(payloadHex string) error {
   report := &rollups.Report{Payload:payloadHex}
-  if h.LogLevel >= Trace {TraceLogger.Println("Sending report status",report)}
-  res, err := rollups.SendReport(report)
+  h.trace("Sending report", "report", report)
+  transport, err := h.getTransport()
   if err != nil {
-    return fmt.Errorf("SendReport: error making http request: %s", err)
+    return fmt.Errorf("SendReport: %s", err)
   }
-
-  body, err := io.ReadAll(res.Body)
+  err = transport.Report(report)
   if err != nil {
-    return fmt.Errorf("SendReport: could not read response body: %s", err)
+    return fmt.Errorf("SendReport: error sending report: %w", err)
   }
-  if h.LogLevel >= Debug {DebugLogger.Println("Received report status", strconv.Itoa(res.StatusCode), "body", string(body))}
+  h.Logger().Debug("Sent report")
 
   return nil
 }
 
 func (h *Handler) SendException(payloadHex string) error {
   exception := &rollups.Exception{Payload:payloadHex}
-  if h.LogLevel >= Trace {TraceLogger.Println("Sending exception status",exception)}
-  res, err := rollups.SendException(exception)
+  h.trace("Sending exception", "exception", exception)
+  transport, err := h.getTransport()
   if err != nil {
-    return fmt.Errorf("SendException: error making http request: %s", err)
+    return fmt.Errorf("SendException: %s", err)
   }
-
-  body, err := io.ReadAll(res.Body)
+  err = transport.Exception(exception)
   if err != nil {
-    return fmt.Errorf("SendException: could not read response body: %s", err)
+    return fmt.Errorf("SendException: error sending exception: %w", err)
   }
-  if h.LogLevel >= Debug {DebugLogger.Println("Received exception status", strconv.Itoa(res.StatusCode), "body", string(body))}
+  h.Logger().Debug("Sent exception")
 
   return nil
 }
@@ -281,15 +370,18 @@ func (h *Handler) SendException(payloadHex string) error {
 
 
 func NewSimpleHandler() *Handler {
-  TraceLogger = log.New(os.Stderr, "[ trace ] ", log.Lshortfile)
-  DebugLogger = log.New(os.Stderr, "[ debug ] ", log.Lshortfile)
-  ErrorLogger = log.New(os.Stderr, "[ error ] ", log.Lshortfile)
-
   h := Handler{}
   h.LogLevel = Error
+  h.RollupsVersion = rollups.V1
   return &h
 }
 
+func NewHandler(transport rollups.Transport) *Handler {
+  h := NewSimpleHandler()
+  h.SetTransport(transport)
+  return h
+}
+
 
 func RunDebug() error {
   LocalHandler.SetDebug()
@@ -311,8 +403,8 @@ func RunContext(ctx context.Context) error {
   return LocalHandler.RunContext(ctx)
 }
 
-type sendFinishRetType struct {
-  Response *http.Response; 
+type finishRetType struct {
+  Response *rollups.FinishResponse
   Error error
 }
 
@@ -321,58 +413,50 @@ func (h *Handler) Run() error {
 }
 
 func (h *Handler) RunContext(ctx context.Context) error {
-  if rollups.GetRollupServer() == "" {
-    rollups.SetRollupServer(os.Getenv("ROLLUP_HTTP_SERVER_URL"))
-  }
-  if rollups.GetRollupServer() == "" {
-    return fmt.Errorf("rollup server not defined")
+  transport, err := h.getTransport()
+  if err != nil {
+    return err
   }
 
   finish := rollups.Finish{Status:"accept"}
-  sendFinishRetCh := make(chan sendFinishRetType)
+  finishRetCh := make(chan finishRetType, 1)
 
   for {
-    if h.LogLevel >= Trace {TraceLogger.Println("Sending finish")}
+    h.trace("Sending finish", "status", finish.Status)
     go func() {
-      fRes,fErr := rollups.SendFinish(&finish)
-      sendFinishRetCh <- sendFinishRetType{fRes,fErr}
+      fRes,fErr := transport.Finish(&finish)
+      finishRetCh <- finishRetType{fRes,fErr}
     }()
-    var sendFinishRet sendFinishRetType
-    
-		select {
-		case <-ctx.Done():
+    var finishRet finishRetType
+
+    select {
+    case <-ctx.Done():
       errMsg := fmt.Errorf("context done: %s", ctx.Err())
-      ErrorLogger.Println(errMsg)
+      h.Logger().Error("Stopping", "error", errMsg)
       return errMsg
-    case sendFinishRet = <-sendFinishRetCh:
-      res := sendFinishRet.Response
-      err := sendFinishRet.Error
-      // res, err := rollups.SendFinish(&finish)
+    case finishRet = <-finishRetCh:
+      response := finishRet.Response
+      err := finishRet.Error
       if err != nil {
-        return fmt.Errorf("error making http request: %s", err)
+        return fmt.Errorf("error sending finish: %w", err)
       }
-      if h.LogLevel >= Trace {TraceLogger.Println("Received finish status", strconv.Itoa(res.StatusCode))}
-      
-      if (res.StatusCode == 202){
-        if h.LogLevel >= Trace {TraceLogger.Println("No pending rollup request, trying again")}
-      } else {
 
-        resBody, err := io.ReadAll(res.Body)
-        if err != nil {
-          return fmt.Errorf("error: could not read response body: %s", err)
-        }
-        if h.LogLevel >= Debug {DebugLogger.Println("Received request",string(resBody))}
-        
-        var response rollups.FinishResponse
-        err = json.Unmarshal(resBody, &response)
-        if err != nil {
-          return fmt.Errorf("error: unmarshaling body: %s", err)
-        }
+      if response == nil {
+        h.trace("No pending rollup request, trying again")
+      } else {
+        h.Logger().Debug("Received request", "type", response.Type, "data", string(response.Data))
 
         finish.Status = "accept"
-        err = h.internalHandleFinish(&response)
+        if h.journal != nil {
+          var entry *JournalEntry
+          entry, err = h.processRecording(ctx, transport, response)
+          if jErr := h.writeJournal(entry); jErr != nil {
+            h.Logger().Error("Error writing journal", "error", jErr)
+          }
+        } else {
+          err = h.ProcessRequestContext(ctx, response)
+        }
         if err != nil {
-          if h.LogLevel >= Error {ErrorLogger.Println("Error:", err)}
           finish.Status = "reject"
         }
       }
@@ -380,24 +464,95 @@ func (h *Handler) RunContext(ctx context.Context) error {
   }
 }
 
-func (h *Handler) internalHandleFinish(response *rollups.FinishResponse) error {
-  var err error
+// ProcessRequest dispatches a request received from the rollup server to the
+// registered handlers, a returned error means the input should be rejected.
+// Panics of the handlers are recovered according to the PanicPolicy and the
+// registered states are restored when an advance is rejected.
+func (h *Handler) ProcessRequest(response *rollups.FinishResponse) error {
+  return h.ProcessRequestContext(context.Background(), response)
+}
 
+// ProcessRequestContext is ProcessRequest with ctx available to the context
+// handlers through the request
+func (h *Handler) ProcessRequestContext(ctx context.Context, response *rollups.FinishResponse) (err error) {
+  var advance *rollups.AdvanceResponse
+  var inspect *rollups.InspectResponse
+  var req *Request
   switch response.Type {
   case "advance_state":
-    data := new(rollups.AdvanceResponse)
-    if err = json.Unmarshal(response.Data, data); err != nil {
-      return fmt.Errorf("Handler: Error unmarshaling advance: %s", err)
+    advance = new(rollups.AdvanceResponse)
+    if err = json.Unmarshal(response.Data, advance); err != nil {
+      err = fmt.Errorf("Handler: Error unmarshaling advance: %s", err)
+      h.Logger().Error("Rejecting request", "error", err)
+      return err
     }
-    err = h.internalHandleAdvance(data)
+    req = &Request{Type: response.Type, Metadata: &advance.Metadata, Payload: advance.Payload}
+    req.input = h.baseLogger().With("input_index", advance.Metadata.InputIndex,
+      "epoch", advance.Metadata.EpochIndex, "sender", advance.Metadata.MsgSender)
   case "inspect_state":
-    data := new(rollups.InspectResponse)
-    if err = json.Unmarshal(response.Data, data); err != nil {
-      return fmt.Errorf("Handler: Error unmarshaling inspect: %s", err)
+    inspect = new(rollups.InspectResponse)
+    if err = json.Unmarshal(response.Data, inspect); err != nil {
+      err = fmt.Errorf("Handler: Error unmarshaling inspect: %s", err)
+      h.Logger().Error("Rejecting request", "error", err)
+      return err
+    }
+    req = &Request{Type: response.Type, Payload: inspect.Payload}
+    req.input = h.baseLogger().With("inspect", true)
+  default:
+    return nil
+  }
+  req.ctx = ctx
+  req.handler = h
+  req.logger = req.input
+
+  if advance != nil && len(h.states) > 0 {
+    snapshots, sErr := h.snapshotStates()
+    if sErr != nil {
+      err = fmt.Errorf("Handler: %s", sErr)
+      req.logger.Error("Rejecting request", "error", err)
+      return err
     }
-    err = h.internalHandleInspect(data)
+    defer func() {
+      if err == nil {
+        h.commitStates()
+        return
+      }
+      if rErr := h.restoreStates(snapshots); rErr != nil {
+        err = fmt.Errorf("%w (Handler: %s)", err, rErr)
+      }
+    }()
+  }
+
+  previous := h.request
+  h.request = req
+  defer func() {
+    if err != nil {
+      req.logger.Error("Rejecting request", "error", err)
+    }
+    h.request = previous
+  }()
+  defer h.recoverPanic(&err)
+
+  if advance != nil {
+    return h.internalHandleAdvance(advance)
+  }
+  return h.internalHandleInspect(inspect)
+}
+
+func (h *Handler) ProcessAdvance(data *rollups.AdvanceResponse) error {
+  dataJson, err := json.Marshal(data)
+  if err != nil {
+    return fmt.Errorf("Handler: Error marshaling advance: %s", err)
   }
-  return err
+  return h.ProcessRequest(&rollups.FinishResponse{Type: "advance_state", Data: dataJson})
+}
+
+func (h *Handler) ProcessInspect(data *rollups.InspectResponse) error {
+  dataJson, err := json.Marshal(data)
+  if err != nil {
+    return fmt.Errorf("Handler: Error marshaling inspect: %s", err)
+  }
+  return h.ProcessRequest(&rollups.FinishResponse{Type: "inspect_state", Data: dataJson})
 }
 
 func (h *Handler) internalHandleAdvance(data *rollups.AdvanceResponse) error {
@@ -408,8 +563,10 @@ func (h *Handler) internalHandleAdvance(data *rollups.AdvanceResponse) error {
       }
     }
   }
-  if h.RollupsFixedAddressHandler != nil && KnownRollupsAddresses[strings.ToLower(data.Metadata.MsgSender)] {
-    return h.RollupsFixedAddressHandler.Handler.handle(&data.Metadata,data.Payload)
+  if h.RollupsFixedAddressHandler != nil && h.IsRollupsAddress(data.Metadata.MsgSender) {
+    return h.Dispatch("rollups", nil, func(req *Request) error {
+      return h.RollupsFixedAddressHandler.Handler.handle(req.Metadata,req.Payload)
+    })
   }
   if h.RoutesAdvanceHandlers != nil {
     for _, routeHandler := range h.RoutesAdvanceHandlers {
@@ -419,12 +576,16 @@ func (h *Handler) internalHandleAdvance(data *rollups.AdvanceResponse) error {
     }
   }
   if h.AdvanceHandler != nil {
-    return h.AdvanceHandler.Handler.handle(&data.Metadata,data.Payload)
+    return h.Dispatch("advance", nil, func(req *Request) error {
+      return h.AdvanceHandler.Handler.handle(req.Metadata,req.Payload)
+    })
   }
-  if h.DefaultHandler != nil {
-    return h.DefaultHandler.Handler.handle(data.Payload)
+  if h.DefaultHandler != nil && !h.nested() {
+    return h.Dispatch("default", nil, func(req *Request) error {
+      return h.DefaultHandler.Handler.handle(req.Payload)
+    })
   }
-  return nil
+  return h.dispatchUnmatched()
 }
 
 func (h *Handler) internalHandleInspect(data *rollups.InspectResponse) error {
@@ -436,47 +597,77 @@ func (h *Handler) internalHandleInspect(data *rollups.InspectResponse) error {
     }
   }
   if h.InspectHandler != nil {
-    return h.InspectHandler.Handler.handle(data.Payload)
+    return h.Dispatch("inspect", nil, func(req *Request) error {
+      return h.InspectHandler.Handler.handle(req.Payload)
+    })
   }
-  if h.DefaultHandler != nil {
-    return h.DefaultHandler.Handler.handle(data.Payload)
+  if h.DefaultHandler != nil && !h.nested() {
+    return h.Dispatch("default", nil, func(req *Request) error {
+      return h.DefaultHandler.Handler.handle(req.Payload)
+    })
   }
-  return nil
+  return h.dispatchUnmatched()
 }
 
 
 
+// RollupsAddresses and KnownRollupsAddresses are set by the package level
+// InitializeRollupsAddresses, handlers without a network configuration of
+// their own fall back to them
 var RollupsAddresses NetworkAddresses
 var KnownRollupsAddresses map[string]bool
 
+// InitializeRollupsAddresses configures the handler with the addresses of a
+// known network for its rollups version
 func (h *Handler) InitializeRollupsAddresses(currentNetwork string) error {
-  return InitializeRollupsAddresses(currentNetwork)
+  addresses, err := LookupNetwork(currentNetwork, h.RollupsVersion)
+  if err != nil {
+    return err
+  }
+  h.RollupsAddresses = addresses
+  return nil
 }
-func InitializeRollupsAddresses(currentNetwork string) error {
-  if KnownRollupsAddresses != nil {
-    return nil
+
+func (h *Handler) SetRollupsAddresses(addresses NetworkAddresses) error {
+  if err := addresses.Validate(); err != nil {
+    return fmt.Errorf("SetRollupsAddresses: %s", err)
   }
-  var result map[string]interface{}
-  json.Unmarshal([]byte(networks), &result)
+  h.RollupsAddresses = addresses
+  return nil
+}
 
-  if result[currentNetwork] == nil {
-    panic("InitializeRollupsAddresses: Unknown network")
+// Addresses returns the network configuration of the handler, or the package
+// level one if the handler has none
+func (h *Handler) Addresses() NetworkAddresses {
+  if h.RollupsAddresses == (NetworkAddresses{}) {
+    return RollupsAddresses
   }
+  return h.RollupsAddresses
+}
 
-  jsonNetwork, _ := json.Marshal(result[currentNetwork])
+func (h *Handler) IsRollupsAddress(address string) bool {
+  return h.Addresses().Contains(address)
+}
 
-  err := json.Unmarshal(jsonNetwork, &RollupsAddresses)
+func InitializeRollupsAddresses(currentNetwork string) error {
+  return InitializeRollupsAddressesVersion(currentNetwork, rollups.V1)
+}
+
+// InitializeRollupsAddressesVersion sets the package level RollupsAddresses
+// used by the handlers without a configuration of their own
+func InitializeRollupsAddressesVersion(currentNetwork string, version rollups.Version) error {
+  addresses, err := LookupNetwork(currentNetwork, version)
   if err != nil {
-    panic(fmt.Sprint("InitializeRollupsAddresses: error unmarshaling network: ", err))
+    return err
   }
+
+  RollupsAddresses = addresses
   KnownRollupsAddresses = make(map[string]bool)
-  KnownRollupsAddresses[strings.ToLower(RollupsAddresses.DappAddressRelay)] = true
-  KnownRollupsAddresses[strings.ToLower(RollupsAddresses.EtherPortalAddress)] = true
-  KnownRollupsAddresses[strings.ToLower(RollupsAddresses.Erc20PortalAddress)] = true
-  KnownRollupsAddresses[strings.ToLower(RollupsAddresses.Erc721PortalAddress)] = true
-  KnownRollupsAddresses[strings.ToLower(RollupsAddresses.Erc1155SinglePortalAddress)] = true
-  KnownRollupsAddresses[strings.ToLower(RollupsAddresses.Erc1155BatchPortalAddress)] = true
+  for _, address := range addresses.list() {
+    if address != "" {
+      KnownRollupsAddresses[strings.ToLower(address)] = true
+    }
+  }
 
   return nil
 }
-
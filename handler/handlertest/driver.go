@@ -0,0 +1,60 @@
+// Package handlertest feeds inputs straight into a handler dispatch, without
+// a rollup server, and returns the outputs generated for each input.
+//
+//  h := jsonhandler.NewJsonHandler("route")
+//  h.HandleAdvanceRoute("deposit", deposit)
+//  d := handlertest.NewDriver(h.Handler)
+//  result := d.Advance(sender, rollups.Str2Hex(`{"route":"deposit"}`))
+//  if !result.Accepted() { ... }
+package handlertest
+
+import (
+	"time"
+
+	hdl "github.com/prototyp3-dev/go-rollups/handler"
+	"github.com/prototyp3-dev/go-rollups/rollups"
+	"github.com/prototyp3-dev/go-rollups/rollups/rolluptest"
+)
+
+type Driver struct {
+  Handler *hdl.Handler
+  Transport *rolluptest.Transport
+  inputIndex uint64
+}
+
+// NewDriver replaces the handler transport by an in-memory one.
+func NewDriver(handler *hdl.Handler) *Driver {
+  transport := rolluptest.NewTransport()
+  handler.SetTransport(transport)
+  return &Driver{Handler: handler, Transport: transport}
+}
+
+// Advance processes an advance input from sender with generated metadata.
+func (d *Driver) Advance(sender string, payloadHex string) *rolluptest.Result {
+  metadata := &rollups.Metadata{
+    MsgSender: sender,
+    InputIndex: d.inputIndex,
+    BlockNumber: d.inputIndex + 1,
+    Timestamp: uint64(time.Now().Unix()),
+  }
+  return d.AdvanceMetadata(metadata, payloadHex)
+}
+
+// AdvanceMetadata processes an advance input with the given metadata.
+func (d *Driver) AdvanceMetadata(metadata *rollups.Metadata, payloadHex string) *rolluptest.Result {
+  d.inputIndex = metadata.InputIndex + 1
+  d.Transport.Begin(&rolluptest.Input{Type: rolluptest.AdvanceState, Metadata: metadata, Payload: payloadHex})
+  err := d.Handler.ProcessAdvance(&rollups.AdvanceResponse{Metadata: *metadata, Payload: payloadHex})
+  return d.Transport.End(err)
+}
+
+func (d *Driver) Inspect(payloadHex string) *rolluptest.Result {
+  d.Transport.Begin(&rolluptest.Input{Type: rolluptest.InspectState, Payload: payloadHex})
+  err := d.Handler.ProcessInspect(&rollups.InspectResponse{Payload: payloadHex})
+  return d.Transport.End(err)
+}
+
+// Results returns the results of all the inputs processed so far.
+func (d *Driver) Results() []*rolluptest.Result {
+  return d.Transport.Results()
+}
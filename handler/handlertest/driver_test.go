@@ -0,0 +1,92 @@
+package handlertest_test
+
+import (
+  "fmt"
+  "reflect"
+  "testing"
+
+  hdl "github.com/prototyp3-dev/go-rollups/handler"
+  "github.com/prototyp3-dev/go-rollups/handler/handlertest"
+  "github.com/prototyp3-dev/go-rollups/rollups"
+)
+
+const (
+  sender = "0xf39fd6e51aad88f6f4ce6ab8827279cfffb92266"
+  destination = "0x70997970c51812dc3a010c7d01b50e0d17dc79c8"
+  gioDomain uint16 = 0x2a
+)
+
+func TestDriver(t *testing.T) {
+  h := hdl.NewSimpleHandler()
+  h.HandleAdvance(func(metadata *rollups.Metadata, payloadHex string) error {
+    payload, err := rollups.Hex2Str(payloadHex)
+    if err != nil {
+      return err
+    }
+    switch payload {
+    case "outputs":
+      if _, err := h.SendNotice(rollups.Str2Hex("notice")); err != nil {
+        return err
+      }
+      if _, err := h.SendVoucher(destination, rollups.Str2Hex("voucher")); err != nil {
+        return err
+      }
+      return h.SendReport(rollups.Str2Hex("report"))
+    case "gio":
+      response, err := h.Gio(gioDomain, "0x0102")
+      if err != nil {
+        return err
+      }
+      _, err = h.SendNotice(response.Data)
+      return err
+    }
+    // the outputs of a rejected input are still recorded
+    h.SendReport(rollups.Str2Hex("failed"))
+    return fmt.Errorf("fail")
+  })
+  h.HandleInspect(func(payloadHex string) error {
+    _, err := h.SendNotice(payloadHex)
+    return err
+  })
+  driver := handlertest.NewDriver(h)
+  driver.Transport.HandleGioData(gioDomain, map[string][]byte{"0x0102": []byte("gio data")})
+
+  outputs := driver.Advance(sender, rollups.Str2Hex("outputs"))
+  if !outputs.Accepted() || outputs.Err != nil {
+    t.Errorf("outputs: status %s: %v", outputs.Status, outputs.Err)
+  }
+  if !reflect.DeepEqual(outputs.Notices, []rollups.Notice{{Payload: rollups.Str2Hex("notice")}}) ||
+      !reflect.DeepEqual(outputs.Vouchers, []rollups.Voucher{{Destination: destination, Payload: rollups.Str2Hex("voucher")}}) ||
+      !reflect.DeepEqual(outputs.Reports, []rollups.Report{{Payload: rollups.Str2Hex("report")}}) {
+    t.Errorf("outputs: notices %v, vouchers %v, reports %v", outputs.Notices, outputs.Vouchers, outputs.Reports)
+  }
+
+  fail := driver.Advance(sender, rollups.Str2Hex("fail"))
+  if fail.Status != "reject" || fail.Err == nil {
+    t.Errorf("fail: status %s: %v", fail.Status, fail.Err)
+  }
+  if !reflect.DeepEqual(fail.Reports, []rollups.Report{{Payload: rollups.Str2Hex("failed")}}) {
+    t.Errorf("fail: reports %v", fail.Reports)
+  }
+
+  gio := driver.Advance(sender, rollups.Str2Hex("gio"))
+  if !gio.Accepted() || !reflect.DeepEqual(gio.Notices, []rollups.Notice{{Payload: rollups.Str2Hex("gio data")}}) {
+    t.Errorf("gio: status %s, notices %v: %v", gio.Status, gio.Notices, gio.Err)
+  }
+
+  // notices aren't allowed on inspects
+  inspect := driver.Inspect(rollups.Str2Hex("status"))
+  if inspect.Status != "reject" || len(inspect.Notices) != 0 {
+    t.Errorf("inspect: status %s, notices %v", inspect.Status, inspect.Notices)
+  }
+
+  results := driver.Results()
+  if len(results) != 4 {
+    t.Fatalf("%d results, expected 4", len(results))
+  }
+  for i, result := range results[:3] {
+    if result.Input.Type != "advance_state" || result.Input.Metadata.InputIndex != uint64(i) || result.Input.Metadata.MsgSender != sender {
+      t.Errorf("input %d: type %s, metadata %+v", i, result.Input.Type, result.Input.Metadata)
+    }
+  }
+}
@@ -0,0 +1,247 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+
+	"github.com/prototyp3-dev/go-rollups/rollups"
+)
+
+// JournalEntry is a line of the journal: a request received from the rollup
+// server, the outputs generated while processing it and the final status.
+type JournalEntry struct {
+  Request *rollups.FinishResponse                       `json:"request"`
+  Status string                                         `json:"status"`
+  Error string                                          `json:"error,omitempty"`
+  Notices []rollups.Notice                              `json:"notices,omitempty"`
+  Vouchers []rollups.Voucher                            `json:"vouchers,omitempty"`
+  DelegateCallVouchers []rollups.DelegateCallVoucher    `json:"delegate_call_vouchers,omitempty"`
+  Reports []rollups.Report                              `json:"reports,omitempty"`
+  Exception *rollups.Exception                          `json:"exception,omitempty"`
+  Gio []JournalGio                                      `json:"gio,omitempty"`
+}
+
+// JournalGio keeps gio responses so they can be answered on replay
+type JournalGio struct {
+  Request rollups.GioRequest    `json:"request"`
+  Response rollups.GioResponse  `json:"response"`
+}
+
+// recordingTransport collects the outputs of the current input in entry
+type recordingTransport struct {
+  rollups.Transport
+  entry *JournalEntry
+}
+
+func (t *recordingTransport) Notice(notice *rollups.Notice) (uint64, error) {
+  index, err := t.Transport.Notice(notice)
+  if err == nil {
+    t.entry.Notices = append(t.entry.Notices, *notice)
+  }
+  return index, err
+}
+
+func (t *recordingTransport) Voucher(voucher *rollups.Voucher) (uint64, error) {
+  index, err := t.Transport.Voucher(voucher)
+  if err == nil {
+    t.entry.Vouchers = append(t.entry.Vouchers, *voucher)
+  }
+  return index, err
+}
+
+func (t *recordingTransport) DelegateCallVoucher(voucher *rollups.DelegateCallVoucher) (uint64, error) {
+  index, err := t.Transport.DelegateCallVoucher(voucher)
+  if err == nil {
+    t.entry.DelegateCallVouchers = append(t.entry.DelegateCallVouchers, *voucher)
+  }
+  return index, err
+}
+
+func (t *recordingTransport) Report(report *rollups.Report) error {
+  err := t.Transport.Report(report)
+  if err == nil {
+    t.entry.Reports = append(t.entry.Reports, *report)
+  }
+  return err
+}
+
+func (t *recordingTransport) Exception(exception *rollups.Exception) error {
+  err := t.Transport.Exception(exception)
+  if err == nil {
+    t.entry.Exception = exception
+  }
+  return err
+}
+
+func (t *recordingTransport) Gio(request *rollups.GioRequest) (*rollups.GioResponse, error) {
+  response, err := t.Transport.Gio(request)
+  if err == nil {
+    t.entry.Gio = append(t.entry.Gio, JournalGio{Request: *request, Response: *response})
+  }
+  return response, err
+}
+
+// replayTransport accepts all outputs and answers gio requests with the
+// recorded responses
+type replayTransport struct {
+  recorded *JournalEntry
+  outputs uint64
+  gio int
+}
+
+func (t *replayTransport) Finish(finish *rollups.Finish) (*rollups.FinishResponse, error) {
+  return nil, fmt.Errorf("finish not available on replay")
+}
+
+func (t *replayTransport) output() (uint64, error) {
+  t.outputs++
+  return t.outputs - 1, nil
+}
+
+func (t *replayTransport) Notice(notice *rollups.Notice) (uint64, error) {
+  return t.output()
+}
+
+func (t *replayTransport) Voucher(voucher *rollups.Voucher) (uint64, error) {
+  return t.output()
+}
+
+func (t *replayTransport) DelegateCallVoucher(voucher *rollups.DelegateCallVoucher) (uint64, error) {
+  return t.output()
+}
+
+func (t *replayTransport) Report(report *rollups.Report) error {
+  return nil
+}
+
+func (t *replayTransport) Exception(exception *rollups.Exception) error {
+  return nil
+}
+
+func (t *replayTransport) Gio(request *rollups.GioRequest) (*rollups.GioResponse, error) {
+  if t.gio >= len(t.recorded.Gio) {
+    return nil, fmt.Errorf("no recorded gio response for domain %d id %s", request.Domain, request.Id)
+  }
+  recorded := t.recorded.Gio[t.gio]
+  t.gio++
+  if recorded.Request != *request {
+    return nil, fmt.Errorf("gio request domain %d id %s differs from recorded domain %d id %s",
+      request.Domain, request.Id, recorded.Request.Domain, recorded.Request.Id)
+  }
+  response := recorded.Response
+  return &response, nil
+}
+
+// SetJournal makes RunContext append every processed input, its outputs and
+// status as a json line to journal
+func (h *Handler) SetJournal(journal io.Writer) {
+  h.journal = journal
+}
+
+// processRecording processes the request collecting its outputs in a new entry
+func (h *Handler) processRecording(ctx context.Context, transport rollups.Transport, response *rollups.FinishResponse) (*JournalEntry, error) {
+  entry := &JournalEntry{Request: response}
+  h.recording = &recordingTransport{Transport: transport, entry: entry}
+  defer func() { h.recording = nil }()
+
+  err := h.ProcessRequestContext(ctx, response)
+  switch {
+  case entry.Exception != nil:
+    entry.Status = "exception"
+  case err != nil:
+    entry.Status = "reject"
+  default:
+    entry.Status = "accept"
+  }
+  if err != nil {
+    entry.Error = err.Error()
+  }
+  return entry, err
+}
+
+func (h *Handler) writeJournal(entry *JournalEntry) error {
+  line, err := json.Marshal(entry)
+  if err != nil {
+    return fmt.Errorf("error encoding journal entry: %s", err)
+  }
+  _, err = h.journal.Write(append(line, '\n'))
+  if err != nil {
+    return fmt.Errorf("error writing journal: %s", err)
+  }
+  return nil
+}
+
+// ReplayResult compares the recorded and replayed processing of an input
+type ReplayResult struct {
+  Line int
+  Recorded *JournalEntry
+  Replayed *JournalEntry
+  Diffs []string
+}
+
+func (r *ReplayResult) Matches() bool {
+  return len(r.Diffs) == 0
+}
+
+// Replay feeds the requests of a journal through the handler, without a rollup
+// server, and compares the new outputs and status with the recorded ones
+func (h *Handler) Replay(journal io.Reader) ([]*ReplayResult, error) {
+  results := make([]*ReplayResult, 0)
+  scanner := bufio.NewScanner(journal)
+  scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+  line := 0
+  for scanner.Scan() {
+    line++
+    if len(scanner.Bytes()) == 0 {
+      continue
+    }
+    var recorded JournalEntry
+    if err := json.Unmarshal(scanner.Bytes(), &recorded); err != nil {
+      return results, fmt.Errorf("Replay: error decoding journal line %d: %s", line, err)
+    }
+    if recorded.Request == nil {
+      return results, fmt.Errorf("Replay: journal line %d has no request", line)
+    }
+
+    replayed, _ := h.processRecording(context.Background(), &replayTransport{recorded: &recorded}, recorded.Request)
+    results = append(results, &ReplayResult{Line: line, Recorded: &recorded, Replayed: replayed, Diffs: diffEntries(&recorded, replayed)})
+  }
+  if err := scanner.Err(); err != nil {
+    return results, fmt.Errorf("Replay: error reading journal: %s", err)
+  }
+  return results, nil
+}
+
+func diffOutputs(name string, recorded interface{}, replayed interface{}) []string {
+  diffs := make([]string, 0)
+  rec := reflect.ValueOf(recorded)
+  rep := reflect.ValueOf(replayed)
+  if rec.Len() != rep.Len() {
+    diffs = append(diffs, fmt.Sprintf("%s: recorded %d, replayed %d", name, rec.Len(), rep.Len()))
+  }
+  for i := 0; i < rec.Len() && i < rep.Len(); i++ {
+    if !reflect.DeepEqual(rec.Index(i).Interface(), rep.Index(i).Interface()) {
+      diffs = append(diffs, fmt.Sprintf("%s %d: recorded %+v, replayed %+v", name, i, rec.Index(i).Interface(), rep.Index(i).Interface()))
+    }
+  }
+  return diffs
+}
+
+func diffEntries(recorded *JournalEntry, replayed *JournalEntry) []string {
+  diffs := make([]string, 0)
+  if recorded.Status != replayed.Status {
+    diffs = append(diffs, fmt.Sprintf("status: recorded %s, replayed %s", recorded.Status, replayed.Status))
+  }
+  diffs = append(diffs, diffOutputs("notices", recorded.Notices, replayed.Notices)...)
+  diffs = append(diffs, diffOutputs("vouchers", recorded.Vouchers, replayed.Vouchers)...)
+  diffs = append(diffs, diffOutputs("delegate call vouchers", recorded.DelegateCallVouchers, replayed.DelegateCallVouchers)...)
+  diffs = append(diffs, diffOutputs("reports", recorded.Reports, replayed.Reports)...)
+  if !reflect.DeepEqual(recorded.Exception, replayed.Exception) {
+    diffs = append(diffs, fmt.Sprintf("exception: recorded %+v, replayed %+v", recorded.Exception, replayed.Exception))
+  }
+  return diffs
+}
@@ -0,0 +1,112 @@
+package handler_test
+
+import (
+  "bytes"
+  "fmt"
+  "strings"
+  "testing"
+
+  hdl "github.com/prototyp3-dev/go-rollups/handler"
+  "github.com/prototyp3-dev/go-rollups/rollups"
+  "github.com/prototyp3-dev/go-rollups/rollups/rolluptest"
+)
+
+const sender = "0xf39fd6e51aad88f6f4ce6ab8827279cfffb92266"
+
+// journalDapp sends a notice with the gio data of id 0x01 and one with the
+// payload, rejecting "fail". The changed dapp upper cases the payload notice
+// and accepts "fail" with a notice.
+func journalDapp(changed bool) *hdl.Handler {
+  h := hdl.NewSimpleHandler()
+  h.HandleAdvance(func(metadata *rollups.Metadata, payloadHex string) error {
+    payload, err := rollups.Hex2Str(payloadHex)
+    if err != nil {
+      return err
+    }
+    if payload == "fail" {
+      if !changed {
+        return fmt.Errorf("fail")
+      }
+      _, err = h.SendNotice(rollups.Str2Hex("FAIL"))
+      return err
+    }
+    response, err := h.Gio(1, "0x01")
+    if err != nil {
+      return err
+    }
+    if _, err = h.SendNotice(response.Data); err != nil {
+      return err
+    }
+    if changed {
+      payload = strings.ToUpper(payload)
+    }
+    _, err = h.SendNotice(rollups.Str2Hex(payload))
+    return err
+  })
+  h.HandleInspect(func(payloadHex string) error {
+    return h.SendReport(payloadHex)
+  })
+  return h
+}
+
+func TestJournalReplay(t *testing.T) {
+  srv := rolluptest.NewServer()
+  srv.HandleGioData(1, map[string][]byte{"0x01": []byte("gio data")})
+  srv.AddAdvance(sender, rollups.Str2Hex("a"))
+  srv.AddAdvance(sender, rollups.Str2Hex("fail"))
+  srv.AddInspect(rollups.Str2Hex("status"))
+  var journal bytes.Buffer
+  h := journalDapp(false)
+  h.SetTransport(rollups.NewHttpTransport(srv.URL))
+  h.SetJournal(&journal)
+  _, err := srv.Run(h.RunContext)
+  srv.Close()
+  if err != nil {
+    t.Fatal(err)
+  }
+  if lines := strings.Count(journal.String(), "\n"); lines != 3 {
+    t.Fatalf("%d journal lines, expected 3", lines)
+  }
+
+  // the replay needs no server, the gio requests get the recorded responses
+  results, err := journalDapp(false).Replay(bytes.NewReader(journal.Bytes()))
+  if err != nil {
+    t.Fatal(err)
+  }
+  statuses := []string{"accept", "reject", "accept"}
+  if len(results) != len(statuses) {
+    t.Fatalf("%d replayed inputs, expected %d", len(results), len(statuses))
+  }
+  for i, result := range results {
+    if !result.Matches() || result.Replayed.Status != statuses[i] {
+      t.Errorf("line %d: status %s, diffs %v", result.Line, result.Replayed.Status, result.Diffs)
+    }
+  }
+  if notices := results[0].Replayed.Notices; len(notices) != 2 || notices[0].Payload != rollups.Str2Hex("gio data") {
+    t.Errorf("replayed notices %v", notices)
+  }
+
+  results, err = journalDapp(true).Replay(bytes.NewReader(journal.Bytes()))
+  if err != nil {
+    t.Fatal(err)
+  }
+  expected := [][]string{
+    {fmt.Sprintf("notices 1: recorded {Payload:%s}, replayed {Payload:%s}", rollups.Str2Hex("a"), rollups.Str2Hex("A"))},
+    {"status: recorded reject, replayed accept", "notices: recorded 0, replayed 1"},
+    {},
+  }
+  for i, result := range results {
+    if strings.Join(result.Diffs, "\n") != strings.Join(expected[i], "\n") {
+      t.Errorf("line %d: diffs %q, expected %q", result.Line, result.Diffs, expected[i])
+    }
+  }
+}
+
+func TestReplayInvalidJournal(t *testing.T) {
+  if _, err := journalDapp(false).Replay(strings.NewReader("{}\n")); err == nil {
+    t.Errorf("replayed a journal line without request")
+  }
+  if _, err := journalDapp(false).Replay(strings.NewReader("x\n")); err == nil {
+    t.Errorf("replayed an invalid journal line")
+  }
+}
@@ -1,6 +1,7 @@
 package jsonhandler
 
 import (
+  "context"
   "encoding/json"
 
   hdl "github.com/prototyp3-dev/go-rollups/handler"
@@ -60,7 +61,7 @@ func (h *JsonHandler) HandleAdvanceRoute(route string, fnHandle AdvanceMapHandle
 	}
   fnHandler := AdvanceMapHandler{fnHandle}
   h.RouteAdvanceHandlers[route] = &fnHandler
-  if h.Handler.LogLevel >= hdl.Trace {hdl.TraceLogger.Println("Created JSON Advance route for",route) }
+  h.Handler.Logger().Log(context.Background(), hdl.LevelTrace, "Created JSON Advance route", "route", route)
 }
 
 
@@ -79,7 +80,7 @@ func (h *JsonHandler) HandleInspectRoute(route string, fnHandle InspectMapHandle
 	}
   fnHandler := InspectMapHandler{fnHandle}
   h.RouteInspectHandlers[route] = &fnHandler
-  if h.Handler.LogLevel >= hdl.Debug {hdl.DebugLogger.Println("Created JSON Inspect route for",route) }
+  h.Handler.Logger().Debug("Created JSON Inspect route", "route", route)
 }
 
 func (h *JsonHandler) getRoute(payloadHex string) (string,map[string]interface{},bool) {
@@ -100,8 +101,10 @@ func (h *JsonHandler) getRoute(payloadHex string) (string,map[string]interface{}
 func (h *JsonHandler) jsonAdvanceHandler(metadata *rollups.Metadata, payloadHex string) (error,bool) {
   if route,result, ok := h.getRoute(payloadHex); ok {
     if h.RouteAdvanceHandlers[route] != nil {
-      if h.Handler.LogLevel >= hdl.Trace {hdl.TraceLogger.Println("Received JSON route",route,"Advance Request:",result) }
-      return h.RouteAdvanceHandlers[route].Handler.Handle(metadata,result),true
+      h.Handler.Logger().Log(context.Background(), hdl.LevelTrace, "Received JSON Advance request", "route", route, "params", result)
+      return h.Handler.Dispatch(route, result, func(req *hdl.Request) error {
+        return h.RouteAdvanceHandlers[route].Handler.Handle(req.Metadata,req.Params)
+      }),true
     }
   }
   return nil,false
@@ -110,19 +113,54 @@ func (h *JsonHandler) jsonAdvanceHandler(metadata *rollups.Metadata, payloadHex
 func (h *JsonHandler) jsonInspectHandler(payloadHex string) (error,bool) {
   if route,result, ok := h.getRoute(payloadHex); ok {
     if h.RouteInspectHandlers[route] != nil {
-      if h.Handler.LogLevel >= hdl.Trace {hdl.TraceLogger.Println("Received JSON route",route,"Inspect Request:",result) }
-      return h.RouteInspectHandlers[route].Handler.Handle(result),true
+      h.Handler.Logger().Log(context.Background(), hdl.LevelTrace, "Received JSON Inspect request", "route", route, "params", result)
+      return h.Handler.Dispatch(route, result, func(req *hdl.Request) error {
+        return h.RouteInspectHandlers[route].Handler.Handle(req.Params)
+      }),true
     }
   }
   return nil,false
 }
 
 
+// ContextAdvance adapts fnHandle to the AdvanceMapHandlerFunc signature, the
+// decoded params are in the request Params
+func (h *JsonHandler) ContextAdvance(fnHandle hdl.ContextHandlerFunc) AdvanceMapHandlerFunc {
+	if fnHandle == nil {
+		panic("json handler: nil handler")
+	}
+  return func(metadata *rollups.Metadata, payloadMap map[string]interface{}) error {
+    req := h.Handler.Request()
+    return fnHandle(req.Context(), req)
+  }
+}
+
+func (h *JsonHandler) ContextInspect(fnHandle hdl.ContextHandlerFunc) InspectMapHandlerFunc {
+	if fnHandle == nil {
+		panic("json handler: nil handler")
+	}
+  return func(payloadMap map[string]interface{}) error {
+    req := h.Handler.Request()
+    return fnHandle(req.Context(), req)
+  }
+}
+
+func (h *JsonHandler) HandleAdvanceRouteContext(route string, fnHandle hdl.ContextHandlerFunc) {
+  h.HandleAdvanceRoute(route, h.ContextAdvance(fnHandle))
+}
+
+func (h *JsonHandler) HandleInspectRouteContext(route string, fnHandle hdl.ContextHandlerFunc) {
+  h.HandleInspectRoute(route, h.ContextInspect(fnHandle))
+}
+
 func (h *JsonHandler) SetDebug() {h.Handler.SetDebug()}
 func (h *JsonHandler) SetLogLevel(logLevel hdl.LogLevel) {h.Handler.SetLogLevel(logLevel)}
+func (h *JsonHandler) Use(middlewares ...hdl.Middleware) {h.Handler.Use(middlewares...)}
 func (h *JsonHandler) HandleDefault(fnHandle hdl.InspectHandlerFunc) {h.Handler.HandleDefault(fnHandle)}
 func (h *JsonHandler) HandleInspect(fnHandle hdl.InspectHandlerFunc) {h.Handler.HandleInspect(fnHandle)}
 func (h *JsonHandler) HandleAdvance(fnHandle hdl.AdvanceHandlerFunc) {h.Handler.HandleAdvance(fnHandle)}
+func (h *JsonHandler) HandleAdvanceContext(fnHandle hdl.ContextHandlerFunc) {h.Handler.HandleAdvanceContext(fnHandle)}
+func (h *JsonHandler) HandleInspectContext(fnHandle hdl.ContextHandlerFunc) {h.Handler.HandleInspectContext(fnHandle)}
 func (h *JsonHandler) HandleRollupsFixedAddresses(fnHandle hdl.AdvanceHandlerFunc) {h.Handler.HandleRollupsFixedAddresses(fnHandle)}
 func (h *JsonHandler) HandleFixedAddress(address string, fnHandle hdl.AdvanceHandlerFunc) {h.Handler.HandleFixedAddress(address,fnHandle)}
 func (h *JsonHandler) SendNotice(payloadHex string) (uint64,error) {return h.Handler.SendNotice(payloadHex)}
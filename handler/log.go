@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"os"
+)
+
+// slog levels of the Trace and Critical log levels
+const (
+  LevelTrace = slog.LevelDebug - 4
+  LevelCritical = slog.LevelError + 4
+)
+
+// SlogLevel returns the minimum slog level logged at the log level
+func (l LogLevel) SlogLevel() slog.Level {
+  switch l {
+  case None:
+    return LevelCritical + 4
+  case Critical:
+    return LevelCritical
+  case Error:
+    return slog.LevelError
+  case Warning:
+    return slog.LevelWarn
+  case Info:
+    return slog.LevelInfo
+  case Debug:
+    return slog.LevelDebug
+  }
+  return LevelTrace
+}
+
+type logLevelVar struct {
+  h *Handler
+}
+
+func (v logLevelVar) Level() slog.Level {
+  return v.h.LogLevel.SlogLevel()
+}
+
+// Leveler follows the handler LogLevel, use it as the level of an injected
+// logger to keep SetLogLevel and SetDebug working
+func (h *Handler) Leveler() slog.Leveler {
+  return logLevelVar{h}
+}
+
+func replaceLevel(groups []string, a slog.Attr) slog.Attr {
+  if a.Key != slog.LevelKey || len(groups) > 0 {
+    return a
+  }
+  switch a.Value.Any().(slog.Level) {
+  case LevelTrace:
+    a.Value = slog.StringValue("TRACE")
+  case LevelCritical:
+    a.Value = slog.StringValue("CRITICAL")
+  }
+  return a
+}
+
+// SetLogger replaces the default text logger to stderr. Inputs are logged
+// with the input_index, epoch, sender and route attributes.
+func (h *Handler) SetLogger(logger *slog.Logger) {
+  if logger == nil {
+    panic("rollups handler: nil logger")
+  }
+  h.logger = logger
+}
+
+// Logger returns the logger of the input being processed, or the handler
+// logger between inputs
+func (h *Handler) Logger() *slog.Logger {
+  if h.request != nil && h.request.logger != nil {
+    return h.request.logger
+  }
+  return h.baseLogger()
+}
+
+func (h *Handler) baseLogger() *slog.Logger {
+  if h.logger == nil {
+    h.logger = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: h.Leveler(), ReplaceAttr: replaceLevel}))
+  }
+  return h.logger
+}
+
+func (h *Handler) trace(msg string, args ...any) {
+  h.Logger().Log(context.Background(), LevelTrace, msg, args...)
+}
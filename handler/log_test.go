@@ -0,0 +1,94 @@
+package handler_test
+
+import (
+  "bytes"
+  "context"
+  "encoding/json"
+  "log/slog"
+  "strings"
+  "testing"
+
+  hdl "github.com/prototyp3-dev/go-rollups/handler"
+  "github.com/prototyp3-dev/go-rollups/handler/handlertest"
+  "github.com/prototyp3-dev/go-rollups/rollups"
+)
+
+// logLines decodes the json lines of a slog.JSONHandler
+func logLines(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+  lines := make([]map[string]interface{}, 0)
+  for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+    if line == "" {
+      continue
+    }
+    var fields map[string]interface{}
+    if err := json.Unmarshal([]byte(line), &fields); err != nil {
+      t.Fatalf("invalid log line %q: %s", line, err)
+    }
+    lines = append(lines, fields)
+  }
+  buf.Reset()
+  return lines
+}
+
+func TestLogger(t *testing.T) {
+  var buf bytes.Buffer
+  h := hdl.NewSimpleHandler()
+  h.SetLogger(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: h.Leveler()})))
+  h.HandleAdvanceContext(func(ctx context.Context, req *hdl.Request) error {
+    req.Logger().Info("advance")
+    _, err := req.Notice(req.Payload)
+    return err
+  })
+  h.HandleInspect(func(payloadHex string) error {
+    h.Logger().Info("inspect")
+    return nil
+  })
+  driver := handlertest.NewDriver(h)
+
+  // Info isn't logged at the default Error level
+  driver.Advance(sender, rollups.Str2Hex("x"))
+  if lines := logLines(t, &buf); len(lines) != 0 {
+    t.Errorf("logged %v at the error level", lines)
+  }
+
+  h.SetLogLevel(hdl.Info)
+  metadata := &rollups.Metadata{MsgSender: sender, EpochIndex: 2, InputIndex: 7}
+  driver.AdvanceMetadata(metadata, rollups.Str2Hex("x"))
+  lines := logLines(t, &buf)
+  if len(lines) != 1 {
+    t.Fatalf("logged %v, expected the advance line", lines)
+  }
+  expected := map[string]interface{}{"msg": "advance", "level": "INFO", "input_index": 7.0, "epoch": 2.0, "sender": sender, "route": "advance"}
+  for key, value := range expected {
+    if lines[0][key] != value {
+      t.Errorf("advance log %s: %v, expected %v", key, lines[0][key], value)
+    }
+  }
+
+  driver.Inspect(rollups.Str2Hex("x"))
+  lines = logLines(t, &buf)
+  if len(lines) != 1 || lines[0]["msg"] != "inspect" || lines[0]["inspect"] != true || lines[0]["route"] != "inspect" {
+    t.Errorf("logged %v, expected the inspect line with the inspect attributes", lines)
+  }
+  if _, ok := lines[0]["sender"]; ok {
+    t.Errorf("inspect logged with a sender")
+  }
+
+  // the input attributes aren't kept between inputs
+  h.Logger().Info("idle")
+  lines = logLines(t, &buf)
+  if len(lines) != 1 || lines[0]["route"] != nil || lines[0]["input_index"] != nil {
+    t.Errorf("logged %v, expected no input attributes", lines)
+  }
+
+  // the handler traces the outputs at LevelTrace
+  h.SetLogLevel(hdl.Trace)
+  driver.Advance(sender, rollups.Str2Hex("x"))
+  traced := false
+  for _, line := range logLines(t, &buf) {
+    traced = traced || line["level"] == hdl.LevelTrace.String()
+  }
+  if !traced {
+    t.Errorf("nothing logged at the trace level")
+  }
+}
@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"fmt"
+)
+
+type RouteFunc func(*Request) error
+
+// Middleware wraps the dispatch of every advance and inspect, it should call
+// next to continue the chain and return the route error.
+type Middleware func(next RouteFunc) RouteFunc
+
+// Use adds middlewares to the handler, the first one is the outermost
+func (h *Handler) Use(middlewares ...Middleware) {
+  for _, middleware := range middlewares {
+    if middleware == nil {
+      panic("rollups handler: nil middleware")
+    }
+    h.middlewares = append(h.middlewares, middleware)
+  }
+}
+
+// Dispatch runs fn for the current input through the middleware chain. Routes
+// handlers (e.g. json, uri and abi handlers) call it once they resolve the
+// route of the input.
+func (h *Handler) Dispatch(route string, params map[string]interface{}, fn RouteFunc) error {
+  req := h.request
+  if req == nil {
+    return fmt.Errorf("Dispatch: no input being processed")
+  }
+  req.Route = route
+  req.Params = params
+  req.dispatched = true
+  req.logger = req.input.With("route", route)
+
+  chain := fn
+  for i := len(h.middlewares) - 1; i >= 0; i-- {
+    chain = h.middlewares[i](chain)
+  }
+  return chain(req)
+}
+
+// dispatchUnmatched lets the middlewares see inputs no route handled
+func (h *Handler) dispatchUnmatched() error {
+  if h.request == nil || h.request.dispatched {
+    return nil
+  }
+  return h.Dispatch("", nil, func(*Request) error { return nil })
+}
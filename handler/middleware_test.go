@@ -0,0 +1,77 @@
+package handler_test
+
+import (
+  "fmt"
+  "reflect"
+  "testing"
+
+  hdl "github.com/prototyp3-dev/go-rollups/handler"
+  "github.com/prototyp3-dev/go-rollups/handler/handlertest"
+  "github.com/prototyp3-dev/go-rollups/rollups"
+  "github.com/prototyp3-dev/go-rollups/rollups/rolluptest"
+)
+
+func TestMiddleware(t *testing.T) {
+  var calls []string
+  trace := func(name string) hdl.Middleware {
+    return func(next hdl.RouteFunc) hdl.RouteFunc {
+      return func(req *hdl.Request) error {
+        calls = append(calls, name + " " + req.Route)
+        err := next(req)
+        calls = append(calls, "/" + name)
+        return err
+      }
+    }
+  }
+  // block rejects the "blocked" inputs without calling the route
+  block := func(next hdl.RouteFunc) hdl.RouteFunc {
+    return func(req *hdl.Request) error {
+      if payload, _ := rollups.Hex2Str(req.Payload); payload == "blocked" {
+        calls = append(calls, "blocked")
+        return fmt.Errorf("blocked")
+      }
+      return next(req)
+    }
+  }
+  h := hdl.NewSimpleHandler()
+  h.Use(trace("a"), trace("b"))
+  h.Use(block)
+  h.HandleAdvance(func(metadata *rollups.Metadata, payloadHex string) error {
+    calls = append(calls, "route")
+    return nil
+  })
+  driver := handlertest.NewDriver(h)
+
+  tests := []struct {
+    name string
+    run func() *rolluptest.Result
+    accepted bool
+    calls []string
+  }{
+    {"advance", func() *rolluptest.Result { return driver.Advance(sender, rollups.Str2Hex("x")) }, true,
+      []string{"a advance", "b advance", "route", "/b", "/a"}},
+    {"short-circuit", func() *rolluptest.Result { return driver.Advance(sender, rollups.Str2Hex("blocked")) }, false,
+      []string{"a advance", "b advance", "blocked", "/b", "/a"}},
+    // the middlewares also see the inputs no route handles
+    {"unmatched", func() *rolluptest.Result { return driver.Inspect(rollups.Str2Hex("x")) }, true,
+      []string{"a ", "b ", "/b", "/a"}},
+  }
+  for _, tt := range tests {
+    calls = nil
+    if result := tt.run(); result.Accepted() != tt.accepted {
+      t.Errorf("%s: status %s, expected accepted %t", tt.name, result.Status, tt.accepted)
+    }
+    if !reflect.DeepEqual(calls, tt.calls) {
+      t.Errorf("%s: calls %q, expected %q", tt.name, calls, tt.calls)
+    }
+  }
+}
+
+func TestNilMiddleware(t *testing.T) {
+  defer func() {
+    if recover() == nil {
+      t.Errorf("nil middleware accepted")
+    }
+  }()
+  hdl.NewSimpleHandler().Use(nil)
+}
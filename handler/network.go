@@ -0,0 +1,230 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/prototyp3-dev/go-rollups/rollups"
+	"github.com/umbracle/ethgo"
+	"gopkg.in/yaml.v3"
+)
+
+// registeredNetworks holds the networks registered at runtime by version,
+// they take precedence over the built-in ones
+var registeredNetworks = make(map[rollups.Version]map[string]NetworkAddresses)
+var networksMu sync.RWMutex
+
+var addressRegex = regexp.MustCompile("^0x[0-9a-fA-F]{40}$")
+
+// ValidateAddress checks address is an hex address and, if it has mixed case,
+// that it has a valid EIP-55 checksum
+func ValidateAddress(address string) error {
+  if !addressRegex.MatchString(address) {
+    return fmt.Errorf("invalid address %s", address)
+  }
+  hexPart := address[2:]
+  if hexPart == strings.ToLower(hexPart) || hexPart == strings.ToUpper(hexPart) {
+    return nil
+  }
+  if ethgo.HexToAddress(address).String() != address {
+    return fmt.Errorf("invalid address checksum %s", address)
+  }
+  return nil
+}
+
+func (a NetworkAddresses) list() []string {
+  return []string{a.DappAddressRelay,a.EtherPortalAddress,a.Erc20PortalAddress,a.Erc721PortalAddress,
+    a.Erc1155SinglePortalAddress,a.Erc1155BatchPortalAddress}
+}
+
+// Contains checks if address is one of the rollups addresses
+func (a NetworkAddresses) Contains(address string) bool {
+  for _, known := range a.list() {
+    if known != "" && strings.EqualFold(known, address) {
+      return true
+    }
+  }
+  return false
+}
+
+// Validate checks all the non empty addresses
+func (a NetworkAddresses) Validate() error {
+  for _, address := range a.list() {
+    if address == "" {
+      continue
+    }
+    if err := ValidateAddress(address); err != nil {
+      return err
+    }
+  }
+  return nil
+}
+
+// Merge returns a copy of a with the non empty addresses of overrides
+func (a NetworkAddresses) Merge(overrides NetworkAddresses) NetworkAddresses {
+  merged := a
+  if overrides.DappAddressRelay != "" {
+    merged.DappAddressRelay = overrides.DappAddressRelay
+  }
+  if overrides.EtherPortalAddress != "" {
+    merged.EtherPortalAddress = overrides.EtherPortalAddress
+  }
+  if overrides.Erc20PortalAddress != "" {
+    merged.Erc20PortalAddress = overrides.Erc20PortalAddress
+  }
+  if overrides.Erc721PortalAddress != "" {
+    merged.Erc721PortalAddress = overrides.Erc721PortalAddress
+  }
+  if overrides.Erc1155SinglePortalAddress != "" {
+    merged.Erc1155SinglePortalAddress = overrides.Erc1155SinglePortalAddress
+  }
+  if overrides.Erc1155BatchPortalAddress != "" {
+    merged.Erc1155BatchPortalAddress = overrides.Erc1155BatchPortalAddress
+  }
+  return merged
+}
+
+func builtinNetwork(network string, version rollups.Version) (NetworkAddresses,bool,error) {
+  var result map[string]json.RawMessage
+  var err error
+  if version == rollups.V2 {
+    err = json.Unmarshal([]byte(networksV2), &result)
+  } else {
+    err = json.Unmarshal([]byte(networks), &result)
+  }
+  if err != nil {
+    return NetworkAddresses{}, false, fmt.Errorf("error unmarshaling networks: %s", err)
+  }
+  if result[network] == nil {
+    return NetworkAddresses{}, false, nil
+  }
+
+  var addresses NetworkAddresses
+  err = json.Unmarshal(result[network], &addresses)
+  if err != nil {
+    return NetworkAddresses{}, false, fmt.Errorf("error unmarshaling network: %s", err)
+  }
+  return addresses, true, nil
+}
+
+// LookupNetwork returns the rollups addresses of a registered or built-in network
+func LookupNetwork(network string, version rollups.Version) (NetworkAddresses,error) {
+  networksMu.RLock()
+  defer networksMu.RUnlock()
+  return lookupNetwork(network, version)
+}
+
+func lookupNetwork(network string, version rollups.Version) (NetworkAddresses,error) {
+  if addresses, ok := registeredNetworks[version][network]; ok {
+    return addresses, nil
+  }
+  addresses, ok, err := builtinNetwork(network, version)
+  if err != nil {
+    return NetworkAddresses{}, fmt.Errorf("LookupNetwork: %s", err)
+  }
+  if !ok {
+    return NetworkAddresses{}, fmt.Errorf("LookupNetwork: unknown network %s", network)
+  }
+  return addresses, nil
+}
+
+// RegisterNetwork adds or overrides a network. The empty addresses are taken
+// from the network with the same name, if it is already known.
+func RegisterNetwork(network string, version rollups.Version, addresses NetworkAddresses) error {
+  if err := validateNetwork(network, version, addresses); err != nil {
+    return fmt.Errorf("RegisterNetwork: %s", err)
+  }
+  networksMu.Lock()
+  defer networksMu.Unlock()
+  registerNetwork(network, version, addresses)
+  return nil
+}
+
+func validateNetwork(network string, version rollups.Version, addresses NetworkAddresses) error {
+  if network == "" {
+    return fmt.Errorf("empty network name")
+  }
+  if version != rollups.V1 && version != rollups.V2 {
+    return fmt.Errorf("invalid rollups version")
+  }
+  if err := addresses.Validate(); err != nil {
+    return fmt.Errorf("network %s: %s", network, err)
+  }
+  return nil
+}
+
+// registerNetwork adds a validated network, networksMu must be locked
+func registerNetwork(network string, version rollups.Version, addresses NetworkAddresses) {
+  if current, err := lookupNetwork(network, version); err == nil {
+    addresses = current.Merge(addresses)
+  }
+  if registeredNetworks[version] == nil {
+    registeredNetworks[version] = make(map[string]NetworkAddresses)
+  }
+  registeredNetworks[version][network] = addresses
+}
+
+// LoadNetworks registers the networks of a json or yaml (.yaml or .yml) file
+// with the same format of the built-in networks:
+//
+//  devnet:
+//    ETHER_PORTAL_ADDRESS: "0x..."
+//    ERC20_PORTAL_ADDRESS: "0x..."
+func LoadNetworks(path string, version rollups.Version) error {
+  data, err := os.ReadFile(path)
+  if err != nil {
+    return fmt.Errorf("LoadNetworks: %s", err)
+  }
+  var networks map[string]NetworkAddresses
+  switch strings.ToLower(filepath.Ext(path)) {
+  case ".yaml", ".yml":
+    err = yaml.Unmarshal(data, &networks)
+  default:
+    err = json.Unmarshal(data, &networks)
+  }
+  if err != nil {
+    return fmt.Errorf("LoadNetworks: error decoding %s: %s", path, err)
+  }
+  // no network is registered if one of them is invalid
+  names := make([]string, 0, len(networks))
+  for network, addresses := range networks {
+    if err = validateNetwork(network, version, addresses); err != nil {
+      return fmt.Errorf("LoadNetworks: %s", err)
+    }
+    names = append(names, network)
+  }
+  sort.Strings(names)
+  networksMu.Lock()
+  defer networksMu.Unlock()
+  for _, network := range names {
+    registerNetwork(network, version, networks[network])
+  }
+  return nil
+}
+
+// LoadNetworkEnv registers network with the addresses set in the environment
+// as ROLLUPS_ plus the network key, e.g. ROLLUPS_ETHER_PORTAL_ADDRESS. It fails
+// if none of them is set.
+func LoadNetworkEnv(network string, version rollups.Version) error {
+  addresses := NetworkAddresses{
+    DappAddressRelay: os.Getenv("ROLLUPS_DAPP_RELAY_ADDRESS"),
+    EtherPortalAddress: os.Getenv("ROLLUPS_ETHER_PORTAL_ADDRESS"),
+    Erc20PortalAddress: os.Getenv("ROLLUPS_ERC20_PORTAL_ADDRESS"),
+    Erc721PortalAddress: os.Getenv("ROLLUPS_ERC721_PORTAL_ADDRESS"),
+    Erc1155SinglePortalAddress: os.Getenv("ROLLUPS_ERC1155_SINGLE_PORTAL_ADDRESS"),
+    Erc1155BatchPortalAddress: os.Getenv("ROLLUPS_ERC1155_BATCH_PORTAL_ADDRESS"),
+  }
+  if addresses == (NetworkAddresses{}) {
+    return fmt.Errorf("LoadNetworkEnv: no ROLLUPS_ address set for network %s", network)
+  }
+  if err := RegisterNetwork(network, version, addresses); err != nil {
+    return fmt.Errorf("LoadNetworkEnv: %s", err)
+  }
+  return nil
+}
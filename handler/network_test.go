@@ -0,0 +1,150 @@
+package handler_test
+
+import (
+  "os"
+  "path/filepath"
+  "testing"
+
+  hdl "github.com/prototyp3-dev/go-rollups/handler"
+  "github.com/prototyp3-dev/go-rollups/rollups"
+)
+
+const (
+  checksummed = "0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266"
+  // checksummed with the case of the 4th char changed
+  badChecksum = "0xf39fd6e51aad88F6F4ce6aB8827279cffFb92266"
+  portal = "0x70997970c51812dc3a010c7d01b50e0d17dc79c8"
+)
+
+var envKeys = []string{"ROLLUPS_DAPP_RELAY_ADDRESS", "ROLLUPS_ETHER_PORTAL_ADDRESS", "ROLLUPS_ERC20_PORTAL_ADDRESS",
+  "ROLLUPS_ERC721_PORTAL_ADDRESS", "ROLLUPS_ERC1155_SINGLE_PORTAL_ADDRESS", "ROLLUPS_ERC1155_BATCH_PORTAL_ADDRESS"}
+
+func TestValidateAddress(t *testing.T) {
+  tests := []struct {
+    address string
+    valid bool
+  }{
+    {sender, true},
+    {checksummed, true},
+    {"0xF39FD6E51AAD88F6F4CE6AB8827279CFFFB92266", true},
+    {badChecksum, false},
+    {"0xf39fd6e51aad88f6f4ce6ab8827279cfffb9226", false},
+    {"f39fd6e51aad88f6f4ce6ab8827279cfffb92266", false},
+    {"0xg39fd6e51aad88f6f4ce6ab8827279cfffb92266", false},
+  }
+  for _, tt := range tests {
+    if err := hdl.ValidateAddress(tt.address); (err == nil) != tt.valid {
+      t.Errorf("%s: error %v, expected valid %t", tt.address, err, tt.valid)
+    }
+  }
+}
+
+func TestRegisterNetwork(t *testing.T) {
+  builtin, err := hdl.LookupNetwork("localhost", rollups.V2)
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  tests := []struct {
+    name string
+    network string
+    version rollups.Version
+    addresses hdl.NetworkAddresses
+    valid bool
+  }{
+    {"new network", "test-struct", rollups.V2, hdl.NetworkAddresses{EtherPortalAddress: checksummed}, true},
+    {"empty name", "", rollups.V2, hdl.NetworkAddresses{EtherPortalAddress: portal}, false},
+    {"invalid version", "test-struct", rollups.Version(3), hdl.NetworkAddresses{EtherPortalAddress: portal}, false},
+    {"invalid checksum", "test-struct", rollups.V2, hdl.NetworkAddresses{Erc20PortalAddress: badChecksum}, false},
+  }
+  for _, tt := range tests {
+    if err := hdl.RegisterNetwork(tt.network, tt.version, tt.addresses); (err == nil) != tt.valid {
+      t.Errorf("%s: error %v, expected valid %t", tt.name, err, tt.valid)
+    }
+  }
+  // the invalid network didn't override the registered one
+  if addresses, err := hdl.LookupNetwork("test-struct", rollups.V2); err != nil || addresses != (hdl.NetworkAddresses{EtherPortalAddress: checksummed}) {
+    t.Errorf("test-struct: %v %v", addresses, err)
+  }
+  if _, err := hdl.LookupNetwork("test-struct", rollups.V1); err == nil {
+    t.Errorf("test-struct registered for v1")
+  }
+
+  // a known network is merged with the given addresses
+  if err := hdl.RegisterNetwork("localhost", rollups.V2, hdl.NetworkAddresses{EtherPortalAddress: portal}); err != nil {
+    t.Fatal(err)
+  }
+  expected := builtin
+  expected.EtherPortalAddress = portal
+  if addresses, _ := hdl.LookupNetwork("localhost", rollups.V2); addresses != expected {
+    t.Errorf("localhost: %v, expected %v", addresses, expected)
+  }
+  if err := hdl.RegisterNetwork("localhost", rollups.V2, builtin); err != nil {
+    t.Fatal(err)
+  }
+}
+
+func TestLoadNetworks(t *testing.T) {
+  dir := t.TempDir()
+  write := func(name string, content string) string {
+    path := filepath.Join(dir, name)
+    if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+      t.Fatal(err)
+    }
+    return path
+  }
+
+  yamlPath := write("networks.yaml", "test-yaml-a:\n  ETHER_PORTAL_ADDRESS: \""+portal+"\"\n"+
+    "test-yaml-b:\n  ERC20_PORTAL_ADDRESS: \""+checksummed+"\"\n")
+  if err := hdl.LoadNetworks(yamlPath, rollups.V1); err != nil {
+    t.Fatal(err)
+  }
+  expected := map[string]hdl.NetworkAddresses{
+    "test-yaml-a": {EtherPortalAddress: portal},
+    "test-yaml-b": {Erc20PortalAddress: checksummed},
+  }
+  for network, addresses := range expected {
+    if got, err := hdl.LookupNetwork(network, rollups.V1); err != nil || got != addresses {
+      t.Errorf("%s: %v %v, expected %v", network, got, err, addresses)
+    }
+  }
+
+  // no network is registered if one of them is invalid
+  jsonPath := write("networks.json", `{"test-json-a":{"ETHER_PORTAL_ADDRESS":"`+portal+`"},`+
+    `"test-json-b":{"ETHER_PORTAL_ADDRESS":"`+badChecksum+`"}}`)
+  if err := hdl.LoadNetworks(jsonPath, rollups.V1); err == nil {
+    t.Errorf("loaded a network with an invalid checksum")
+  }
+  if _, err := hdl.LookupNetwork("test-json-a", rollups.V1); err == nil {
+    t.Errorf("test-json-a registered from an invalid file")
+  }
+
+  for _, path := range []string{filepath.Join(dir, "missing.json"), write("invalid.yml", "test: [")} {
+    if err := hdl.LoadNetworks(path, rollups.V1); err == nil {
+      t.Errorf("%s: loaded", path)
+    }
+  }
+}
+
+func TestLoadNetworkEnv(t *testing.T) {
+  for _, key := range envKeys {
+    t.Setenv(key, "")
+  }
+  if err := hdl.LoadNetworkEnv("test-env", rollups.V2); err == nil {
+    t.Errorf("loaded a network without addresses")
+  }
+
+  t.Setenv("ROLLUPS_ERC20_PORTAL_ADDRESS", badChecksum)
+  if err := hdl.LoadNetworkEnv("test-env", rollups.V2); err == nil {
+    t.Errorf("loaded a network with an invalid checksum")
+  }
+
+  t.Setenv("ROLLUPS_ERC20_PORTAL_ADDRESS", "")
+  t.Setenv("ROLLUPS_ETHER_PORTAL_ADDRESS", portal)
+  if err := hdl.LoadNetworkEnv("test-env", rollups.V2); err != nil {
+    t.Fatal(err)
+  }
+  if addresses, err := hdl.LookupNetwork("test-env", rollups.V2); err != nil || addresses != (hdl.NetworkAddresses{EtherPortalAddress: portal}) {
+    t.Errorf("test-env: %v %v", addresses, err)
+  }
+}
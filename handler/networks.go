@@ -60,4 +60,73 @@ var networks = `
         "ERC1155_BATCH_PORTAL_ADDRESS":"0xedB53860A6B52bbb7561Ad596416ee9965B055Aa"
     }
 }
-`
\ No newline at end of file
+`
+// v2 portals are deployed at the same addresses on every network and there is
+// no dapp address relay, the app address comes in the input metadata
+var networksV2 = `
+{
+    "localhost":{
+        "ETHER_PORTAL_ADDRESS":"0xc70076a466789B595b50959cdc261227F0D70051",
+        "ERC20_PORTAL_ADDRESS":"0xc700D6aDd016eECd59d989C028214Eaa0fCC0051",
+        "ERC721_PORTAL_ADDRESS":"0xc700d52F5290e978e9CAe7D1E092935263b60051",
+        "ERC1155_SINGLE_PORTAL_ADDRESS":"0xc700A261279aFC6F755A3a67D86ae43E2eBD0051",
+        "ERC1155_BATCH_PORTAL_ADDRESS":"0xc700A2e5531E720a2434433b6ccf4c0eA2400051"
+    },
+    "sepolia":{
+        "ETHER_PORTAL_ADDRESS":"0xc70076a466789B595b50959cdc261227F0D70051",
+        "ERC20_PORTAL_ADDRESS":"0xc700D6aDd016eECd59d989C028214Eaa0fCC0051",
+        "ERC721_PORTAL_ADDRESS":"0xc700d52F5290e978e9CAe7D1E092935263b60051",
+        "ERC1155_SINGLE_PORTAL_ADDRESS":"0xc700A261279aFC6F755A3a67D86ae43E2eBD0051",
+        "ERC1155_BATCH_PORTAL_ADDRESS":"0xc700A2e5531E720a2434433b6ccf4c0eA2400051"
+    },
+    "arbitrum-sepolia":{
+        "ETHER_PORTAL_ADDRESS":"0xc70076a466789B595b50959cdc261227F0D70051",
+        "ERC20_PORTAL_ADDRESS":"0xc700D6aDd016eECd59d989C028214Eaa0fCC0051",
+        "ERC721_PORTAL_ADDRESS":"0xc700d52F5290e978e9CAe7D1E092935263b60051",
+        "ERC1155_SINGLE_PORTAL_ADDRESS":"0xc700A261279aFC6F755A3a67D86ae43E2eBD0051",
+        "ERC1155_BATCH_PORTAL_ADDRESS":"0xc700A2e5531E720a2434433b6ccf4c0eA2400051"
+    },
+    "optimism-sepolia":{
+        "ETHER_PORTAL_ADDRESS":"0xc70076a466789B595b50959cdc261227F0D70051",
+        "ERC20_PORTAL_ADDRESS":"0xc700D6aDd016eECd59d989C028214Eaa0fCC0051",
+        "ERC721_PORTAL_ADDRESS":"0xc700d52F5290e978e9CAe7D1E092935263b60051",
+        "ERC1155_SINGLE_PORTAL_ADDRESS":"0xc700A261279aFC6F755A3a67D86ae43E2eBD0051",
+        "ERC1155_BATCH_PORTAL_ADDRESS":"0xc700A2e5531E720a2434433b6ccf4c0eA2400051"
+    },
+    "base-sepolia":{
+        "ETHER_PORTAL_ADDRESS":"0xc70076a466789B595b50959cdc261227F0D70051",
+        "ERC20_PORTAL_ADDRESS":"0xc700D6aDd016eECd59d989C028214Eaa0fCC0051",
+        "ERC721_PORTAL_ADDRESS":"0xc700d52F5290e978e9CAe7D1E092935263b60051",
+        "ERC1155_SINGLE_PORTAL_ADDRESS":"0xc700A261279aFC6F755A3a67D86ae43E2eBD0051",
+        "ERC1155_BATCH_PORTAL_ADDRESS":"0xc700A2e5531E720a2434433b6ccf4c0eA2400051"
+    },
+    "arbitrum":{
+        "ETHER_PORTAL_ADDRESS":"0xc70076a466789B595b50959cdc261227F0D70051",
+        "ERC20_PORTAL_ADDRESS":"0xc700D6aDd016eECd59d989C028214Eaa0fCC0051",
+        "ERC721_PORTAL_ADDRESS":"0xc700d52F5290e978e9CAe7D1E092935263b60051",
+        "ERC1155_SINGLE_PORTAL_ADDRESS":"0xc700A261279aFC6F755A3a67D86ae43E2eBD0051",
+        "ERC1155_BATCH_PORTAL_ADDRESS":"0xc700A2e5531E720a2434433b6ccf4c0eA2400051"
+    },
+    "optimism":{
+        "ETHER_PORTAL_ADDRESS":"0xc70076a466789B595b50959cdc261227F0D70051",
+        "ERC20_PORTAL_ADDRESS":"0xc700D6aDd016eECd59d989C028214Eaa0fCC0051",
+        "ERC721_PORTAL_ADDRESS":"0xc700d52F5290e978e9CAe7D1E092935263b60051",
+        "ERC1155_SINGLE_PORTAL_ADDRESS":"0xc700A261279aFC6F755A3a67D86ae43E2eBD0051",
+        "ERC1155_BATCH_PORTAL_ADDRESS":"0xc700A2e5531E720a2434433b6ccf4c0eA2400051"
+    },
+    "base":{
+        "ETHER_PORTAL_ADDRESS":"0xc70076a466789B595b50959cdc261227F0D70051",
+        "ERC20_PORTAL_ADDRESS":"0xc700D6aDd016eECd59d989C028214Eaa0fCC0051",
+        "ERC721_PORTAL_ADDRESS":"0xc700d52F5290e978e9CAe7D1E092935263b60051",
+        "ERC1155_SINGLE_PORTAL_ADDRESS":"0xc700A261279aFC6F755A3a67D86ae43E2eBD0051",
+        "ERC1155_BATCH_PORTAL_ADDRESS":"0xc700A2e5531E720a2434433b6ccf4c0eA2400051"
+    },
+    "mainnet":{
+        "ETHER_PORTAL_ADDRESS":"0xc70076a466789B595b50959cdc261227F0D70051",
+        "ERC20_PORTAL_ADDRESS":"0xc700D6aDd016eECd59d989C028214Eaa0fCC0051",
+        "ERC721_PORTAL_ADDRESS":"0xc700d52F5290e978e9CAe7D1E092935263b60051",
+        "ERC1155_SINGLE_PORTAL_ADDRESS":"0xc700A261279aFC6F755A3a67D86ae43E2eBD0051",
+        "ERC1155_BATCH_PORTAL_ADDRESS":"0xc700A2e5531E720a2434433b6ccf4c0eA2400051"
+    }
+}
+`
@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"fmt"
+	"runtime/debug"
+
+	"github.com/prototyp3-dev/go-rollups/rollups"
+)
+
+// PanicPolicy defines what happens when a handler panics while processing an input
+type PanicPolicy uint8
+const (
+  // PanicReject rejects the input and sends a report with the stack
+  PanicReject PanicPolicy = iota
+  // PanicException sends an exception with the stack, halting the dapp
+  PanicException
+  // PanicRepanic doesn't recover, the panic crashes the process
+  PanicRepanic
+)
+
+// PanicError is the error returned for inputs whose handler panicked
+type PanicError struct {
+  Value interface{}
+  Stack []byte
+}
+
+func (e *PanicError) Error() string {
+  return fmt.Sprintf("panic: %v", e.Value)
+}
+
+func (h *Handler) SetPanicPolicy(policy PanicPolicy) {
+  if policy > PanicRepanic {
+    panic("rollups handler: invalid panic policy")
+  }
+  h.PanicPolicy = policy
+}
+
+// recoverPanic turns a panic of the current input into a *PanicError in err,
+// according to the handler PanicPolicy. It must be deferred.
+func (h *Handler) recoverPanic(err *error) {
+  if h.PanicPolicy == PanicRepanic {
+    return
+  }
+  value := recover()
+  if value == nil {
+    return
+  }
+  panicErr := &PanicError{Value: value, Stack: debug.Stack()}
+  *err = panicErr
+
+  diagnostic := rollups.Str2Hex(fmt.Sprintf("%s\n%s", panicErr, panicErr.Stack))
+  if h.PanicPolicy == PanicException {
+    if eErr := h.SendException(diagnostic); eErr != nil {
+      h.Logger().Error("Error sending panic exception", "error", eErr)
+    }
+    return
+  }
+  if rErr := h.SendReport(diagnostic); rErr != nil {
+    h.Logger().Error("Error sending panic report", "error", rErr)
+  }
+}
@@ -0,0 +1,69 @@
+package handler_test
+
+import (
+  "errors"
+  "strings"
+  "testing"
+
+  hdl "github.com/prototyp3-dev/go-rollups/handler"
+  "github.com/prototyp3-dev/go-rollups/handler/handlertest"
+  "github.com/prototyp3-dev/go-rollups/rollups"
+)
+
+// panicDapp panics on every advance
+func panicDapp(policy hdl.PanicPolicy) *handlertest.Driver {
+  h := hdl.NewSimpleHandler()
+  h.SetPanicPolicy(policy)
+  h.HandleAdvance(func(metadata *rollups.Metadata, payloadHex string) error {
+    panic("boom")
+  })
+  return handlertest.NewDriver(h)
+}
+
+func TestPanicPolicy(t *testing.T) {
+  diagnostic := func(payloadHex string) bool {
+    text, err := rollups.Hex2Str(payloadHex)
+    return err == nil && strings.HasPrefix(text, "panic: boom\n") && strings.Contains(text, "goroutine")
+  }
+
+  result := panicDapp(hdl.PanicReject).Advance(sender, rollups.Str2Hex("x"))
+  var panicErr *hdl.PanicError
+  if result.Status != "reject" || !errors.As(result.Err, &panicErr) || panicErr.Value != "boom" {
+    t.Errorf("reject: status %s: %v", result.Status, result.Err)
+  }
+  if len(result.Reports) != 1 || !diagnostic(result.Reports[0].Payload) || result.Exception != nil {
+    t.Errorf("reject: reports %v, exception %v, expected a report with the stack", result.Reports, result.Exception)
+  }
+
+  result = panicDapp(hdl.PanicException).Advance(sender, rollups.Str2Hex("x"))
+  if result.Status != "exception" || result.Exception == nil || !diagnostic(result.Exception.Payload) {
+    t.Errorf("exception: status %s, exception %v, expected an exception with the stack", result.Status, result.Exception)
+  }
+  if len(result.Reports) != 0 || !errors.As(result.Err, &panicErr) {
+    t.Errorf("exception: reports %v: %v", result.Reports, result.Err)
+  }
+
+  driver := panicDapp(hdl.PanicRepanic)
+  func() {
+    defer func() {
+      if value := recover(); value != "boom" {
+        t.Errorf("repanic: recovered %v, expected boom", value)
+      }
+    }()
+    driver.Advance(sender, rollups.Str2Hex("x"))
+    t.Errorf("repanic: the panic was recovered")
+  }()
+  // the handler isn't left processing the input that panicked
+  if req := driver.Handler.Request(); req != nil {
+    t.Errorf("repanic: request %v still being processed", req)
+  }
+}
+
+func TestInvalidPanicPolicy(t *testing.T) {
+  defer func() {
+    if recover() == nil {
+      t.Errorf("invalid panic policy accepted")
+    }
+  }()
+  hdl.NewSimpleHandler().SetPanicPolicy(hdl.PanicRepanic + 1)
+}
@@ -0,0 +1,254 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/big"
+
+	"github.com/prototyp3-dev/go-rollups/rollups"
+)
+
+// Request is the input being dispatched to a route
+type Request struct {
+  // Type is advance_state or inspect_state
+  Type string
+  // Metadata is nil on inspect
+  Metadata *rollups.Metadata
+  Payload string
+  // Route identifies the handler selected for the input, e.g. the json route
+  // key, the uri pattern or the abi codec header. Empty if no route matched.
+  Route string
+  // Params are the values decoded from the payload by the route
+  Params map[string]interface{}
+  ctx context.Context
+  handler *Handler
+  parent *Request
+  // derived requests send outputs on behalf of origin (see DeriveRequest)
+  derived bool
+  origin *Request
+  dispatched bool
+  input *slog.Logger
+  logger *slog.Logger
+}
+
+// ContextHandlerFunc handles an input with the request state, ctx is canceled
+// when the handler loop (RunContext) is stopped
+type ContextHandlerFunc func(ctx context.Context, req *Request) error
+
+func (r *Request) IsAdvance() bool {
+  return r.Type == "advance_state"
+}
+
+func (r *Request) Context() context.Context {
+  if r.ctx == nil {
+    return context.Background()
+  }
+  return r.ctx
+}
+
+// Parent returns the input a nested request is part of (see ProcessNested),
+// nil for the inputs received from the rollup server
+func (r *Request) Parent() *Request {
+  return r.parent
+}
+
+// Bytes returns the decoded payload
+func (r *Request) Bytes() ([]byte, error) {
+  return rollups.Hex2Bin(r.Payload)
+}
+
+// Logger returns the handler logger with the input and route attributes
+func (r *Request) Logger() *slog.Logger {
+  return r.logger
+}
+
+// current checks the request is the input being processed, so outputs aren't
+// sent on behalf of another input
+func (r *Request) current() error {
+  processing := r
+  if r.derived {
+    processing = r.origin
+  }
+  if r.handler == nil || r.handler.request != processing {
+    return fmt.Errorf("request is not being processed")
+  }
+  return nil
+}
+
+func (r *Request) Notice(payloadHex string) (uint64, error) {
+  if err := r.current(); err != nil {
+    return 0, fmt.Errorf("Notice: %s", err)
+  }
+  return r.handler.SendNotice(payloadHex)
+}
+
+func (r *Request) Voucher(destination string, payloadHex string) (uint64, error) {
+  if err := r.current(); err != nil {
+    return 0, fmt.Errorf("Voucher: %s", err)
+  }
+  return r.handler.SendVoucher(destination, payloadHex)
+}
+
+func (r *Request) PayableVoucher(destination string, value *big.Int, payloadHex string) (uint64, error) {
+  if err := r.current(); err != nil {
+    return 0, fmt.Errorf("PayableVoucher: %s", err)
+  }
+  return r.handler.SendPayableVoucher(destination, value, payloadHex)
+}
+
+func (r *Request) DelegateCallVoucher(destination string, payloadHex string) (uint64, error) {
+  if err := r.current(); err != nil {
+    return 0, fmt.Errorf("DelegateCallVoucher: %s", err)
+  }
+  return r.handler.SendDelegateCallVoucher(destination, payloadHex)
+}
+
+func (r *Request) Report(payloadHex string) error {
+  if err := r.current(); err != nil {
+    return fmt.Errorf("Report: %s", err)
+  }
+  return r.handler.SendReport(payloadHex)
+}
+
+func (r *Request) Exception(payloadHex string) error {
+  if err := r.current(); err != nil {
+    return fmt.Errorf("Exception: %s", err)
+  }
+  return r.handler.SendException(payloadHex)
+}
+
+func (r *Request) Gio(domain uint16, idHex string) (*rollups.GioResponse, error) {
+  if err := r.current(); err != nil {
+    return nil, fmt.Errorf("Gio: %s", err)
+  }
+  return r.handler.Gio(domain, idHex)
+}
+
+// ProcessNested routes payloadHex as an input nested in the one being
+// processed, e.g. the action of a signed or batched input, with metadata as
+// its metadata on advance (nil keeps the current one). The outputs belong to
+// the current input and a nested error, or a payload no route handles, should
+// reject it: the default handler doesn't handle nested inputs.
+func (h *Handler) ProcessNested(metadata *rollups.Metadata, payloadHex string) error {
+  parent := h.request
+  if parent == nil {
+    return fmt.Errorf("ProcessNested: no input being processed")
+  }
+  req := &Request{Type: parent.Type, Payload: payloadHex, ctx: parent.ctx, handler: h, parent: parent}
+  if parent.IsAdvance() {
+    if metadata == nil {
+      metadata = parent.Metadata
+    }
+    req.Metadata = metadata
+    req.input = parent.input.With("nested_sender", metadata.MsgSender)
+  } else {
+    req.input = parent.input.With("nested", true)
+  }
+  req.logger = req.input
+
+  h.request = req
+  defer func() {
+    h.request = parent
+  }()
+  var err error
+  if req.IsAdvance() {
+    err = h.internalHandleAdvance(&rollups.AdvanceResponse{Metadata: *metadata, Payload: payloadHex})
+  } else {
+    err = h.internalHandleInspect(&rollups.InspectResponse{Payload: payloadHex})
+  }
+  if err != nil {
+    return err
+  }
+  if req.Route == "" {
+    return fmt.Errorf("ProcessNested: no route for the nested input")
+  }
+  return nil
+}
+
+// nested checks if the input being processed is nested in another one
+func (h *Handler) nested() bool {
+  return h.request != nil && h.request.parent != nil
+}
+
+// DeriveRequest returns a copy of the input being processed with metadata (nil
+// keeps the current one) and params, e.g. to run a context route from a map
+// handler with the metadata and params it got. The input being processed isn't
+// changed and the outputs of the copy belong to it. Between inputs, the request
+// is an advance if metadata isn't nil and can send outputs until an input is
+// processed.
+func (h *Handler) DeriveRequest(metadata *rollups.Metadata, params map[string]interface{}) *Request {
+  origin := h.request
+  var req Request
+  if origin != nil {
+    req = *origin
+    if metadata == nil {
+      metadata = origin.Metadata
+    }
+  } else {
+    req = Request{Type: "inspect_state", handler: h, input: h.baseLogger()}
+    req.logger = req.input
+    if metadata != nil {
+      req.Type = "advance_state"
+    }
+  }
+  req.Metadata = metadata
+  req.Params = params
+  req.derived = true
+  req.origin = origin
+  return &req
+}
+
+// Request returns the input being processed, nil between inputs
+func (h *Handler) Request() *Request {
+  return h.request
+}
+
+// ContextAdvance adapts fnHandle to the AdvanceHandlerFunc signature, it must
+// only be called by the handler of h
+func (h *Handler) ContextAdvance(fnHandle ContextHandlerFunc) AdvanceHandlerFunc {
+	if fnHandle == nil {
+		panic("rollups handler: nil handler")
+	}
+  return func(metadata *rollups.Metadata, payloadHex string) error {
+    req := h.request
+    if req == nil {
+      return fmt.Errorf("ContextAdvance: no input being processed")
+    }
+    return fnHandle(req.Context(), req)
+  }
+}
+
+// ContextInspect adapts fnHandle to the InspectHandlerFunc signature
+func (h *Handler) ContextInspect(fnHandle ContextHandlerFunc) InspectHandlerFunc {
+	if fnHandle == nil {
+		panic("rollups handler: nil handler")
+	}
+  return func(payloadHex string) error {
+    req := h.request
+    if req == nil {
+      return fmt.Errorf("ContextInspect: no input being processed")
+    }
+    return fnHandle(req.Context(), req)
+  }
+}
+
+func (h *Handler) HandleDefaultContext(fnHandle ContextHandlerFunc) {
+  h.HandleDefault(h.ContextInspect(fnHandle))
+}
+
+func (h *Handler) HandleInspectContext(fnHandle ContextHandlerFunc) {
+  h.HandleInspect(h.ContextInspect(fnHandle))
+}
+
+func (h *Handler) HandleAdvanceContext(fnHandle ContextHandlerFunc) {
+  h.HandleAdvance(h.ContextAdvance(fnHandle))
+}
+
+func (h *Handler) HandleRollupsFixedAddressesContext(fnHandle ContextHandlerFunc) {
+  h.HandleRollupsFixedAddresses(h.ContextAdvance(fnHandle))
+}
+
+func (h *Handler) HandleFixedAddressContext(address string, fnHandle ContextHandlerFunc) {
+  h.HandleFixedAddress(address, h.ContextAdvance(fnHandle))
+}
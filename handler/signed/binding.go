@@ -0,0 +1,47 @@
+package signedhandler
+
+import (
+  "fmt"
+
+  "github.com/prototyp3-dev/go-rollups/rollups"
+  "github.com/prototyp3-dev/go-rollups/handler/abi"
+)
+
+// binding is the chain and the application the signatures are bound to, so
+// they can't be replayed on other dapps or chains
+type binding struct {
+  // chain id and app address when the metadata doesn't have them (rollups
+  // v1 and inspects)
+  chainId uint64
+  appAddress abihandler.Address
+}
+
+// SetChainId sets the chain id of the signatures when the metadata has none
+func (b *binding) SetChainId(chainId uint64) {
+  b.chainId = chainId
+}
+
+// SetAppAddress sets the app address of the signatures when the metadata has
+// no app contract
+func (b *binding) SetAppAddress(address abihandler.Address) {
+  b.appAddress = address
+}
+
+// resolve returns the chain id and app address of the inputs with metadata
+func (b *binding) resolve(metadata *rollups.Metadata) (uint64,abihandler.Address,error) {
+  chainId, appAddress := b.chainId, b.appAddress
+  if metadata != nil && metadata.ChainId != 0 {
+    chainId = metadata.ChainId
+  }
+  if metadata != nil && metadata.AppContract != "" {
+    address, err := abihandler.Hex2Address(metadata.AppContract)
+    if err != nil {
+      return chainId, appAddress, err
+    }
+    appAddress = address
+  }
+  if chainId == 0 || appAddress == (abihandler.Address{}) {
+    return chainId, appAddress, fmt.Errorf("no chain id or app address configured")
+  }
+  return chainId, appAddress, nil
+}
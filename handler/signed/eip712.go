@@ -0,0 +1,75 @@
+package signedhandler
+
+import (
+  "fmt"
+  "math/big"
+
+  "github.com/prototyp3-dev/go-rollups/handler/abi"
+
+  "github.com/lynoferraz/abigo"
+  "github.com/umbracle/ethgo"
+)
+
+const (
+  DomainType = "EIP712Domain(string name,string version,uint256 chainId,address verifyingContract)"
+  // SignedInputType is the EIP-712 type of the signed inputs, the payload is
+  // an input of the application routes
+  SignedInputType = "SignedInput(bytes payload,uint256 nonce)"
+)
+
+var domainEncoding = abi.MustNewType("tuple(bytes32,bytes32,bytes32,uint256,address)")
+var signedInputEncoding = abi.MustNewType("tuple(bytes32,bytes32,uint256)")
+
+// Domain is the EIP-712 domain of the signed inputs, bound to the chain and
+// the application contract so signatures can't be replayed on other dapps
+type Domain struct {
+  Name string
+  Version string
+  ChainId uint64
+  VerifyingContract abihandler.Address
+}
+
+// Separator returns the hash of the domain
+func (d Domain) Separator() ethgo.Hash {
+  encoded, err := domainEncoding.Encode([]interface{}{
+    keccak256([]byte(DomainType)),
+    keccak256([]byte(d.Name)),
+    keccak256([]byte(d.Version)),
+    new(big.Int).SetUint64(d.ChainId),
+    d.VerifyingContract,
+  })
+  if err != nil {
+    panic(fmt.Sprintf("signed handler: domain encoding: %s", err))
+  }
+  return keccak256(encoded)
+}
+
+// SignedInputHash returns the EIP-712 hash of a signed input, the hash signed
+// by eth_signTypedData_v4
+func SignedInputHash(domain Domain, payload []byte, nonce *big.Int) (ethgo.Hash,error) {
+  if nonce == nil || nonce.Sign() < 0 {
+    return ethgo.Hash{}, fmt.Errorf("SignedInputHash: invalid nonce")
+  }
+  encoded, err := signedInputEncoding.Encode([]interface{}{
+    keccak256([]byte(SignedInputType)),
+    keccak256(payload),
+    nonce,
+  })
+  if err != nil {
+    return ethgo.Hash{}, fmt.Errorf("SignedInputHash: %s", err)
+  }
+  return domain.Hash(keccak256(encoded)), nil
+}
+
+// Hash returns the EIP-712 hash of a struct in the domain:
+// keccak256("\x19\x01" || separator || structHash)
+func (d Domain) Hash(structHash ethgo.Hash) ethgo.Hash {
+  separator := d.Separator()
+  return keccak256(append(append([]byte{0x19, 0x01}, separator[:]...), structHash[:]...))
+}
+
+func keccak256(data []byte) ethgo.Hash {
+  var hash ethgo.Hash
+  copy(hash[:], ethgo.Keccak256(data))
+  return hash
+}
@@ -0,0 +1,113 @@
+package signedhandler_test
+
+import (
+  "math/big"
+  "testing"
+
+  "github.com/prototyp3-dev/go-rollups/rollups"
+  "github.com/prototyp3-dev/go-rollups/handler/abi"
+  "github.com/prototyp3-dev/go-rollups/handler/signed"
+
+  "github.com/umbracle/ethgo"
+)
+
+// mailDomain is the domain of the example of the EIP-712 specification
+var mailDomain = signedhandler.Domain{
+  Name: "Ether Mail",
+  Version: "1",
+  ChainId: 1,
+  VerifyingContract: mustAddress("0xCcCCccccCCCCcCCCCCCcCcCccCcCCCcCcccccccC"),
+}
+
+func TestDomainSeparator(t *testing.T) {
+  tests := []struct {
+    name string
+    domain signedhandler.Domain
+    separator string
+  }{
+    {"eip-712 mail example", mailDomain, "0xf2cee375fa42b42143804025fc449deafd50cc031ca257e0b194a650a912090f"},
+  }
+  for _, tt := range tests {
+    separator := tt.domain.Separator()
+    if got := rollups.Bin2Hex(separator[:]); got != tt.separator {
+      t.Errorf("%s: separator %s, expected %s", tt.name, got, tt.separator)
+    }
+  }
+}
+
+// TestDomainHash checks the hash and the signer of the example of the EIP-712
+// specification, signed with eth_signTypedData_v4 by the key keccak256("cow")
+func TestDomainHash(t *testing.T) {
+  structHash := mustHash("0xc52c0ee5d84264471806290a3f2c4cecfc5490626bf912d01f240d7a274b371e")
+  hash := mailDomain.Hash(structHash)
+  if got, expected := rollups.Bin2Hex(hash[:]), "0xbe609aee343fb3c4b28e1df9e632fca64fcfaede20f02e86244efddf30957bd2"; got != expected {
+    t.Fatalf("hash %s, expected %s", got, expected)
+  }
+  signature := mustBin("0x4355c47d63924e8a72e509b65029052eb6c299d53a04e167c5775fd466751c9d" +
+    "07299936d304c153f6443dfa05f40ff007d72911b6f72307f996231605b91562" + "1c")
+  signer, err := abihandler.RecoverSigner(hash, signature)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if expected := mustAddress("0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826"); signer != expected {
+    t.Errorf("signer %s, expected %s", signer, expected)
+  }
+}
+
+func TestSignedInputHash(t *testing.T) {
+  tests := []struct {
+    name string
+    payload []byte
+    nonce *big.Int
+    err bool
+  }{
+    {"empty payload", []byte{}, big.NewInt(0), false},
+    {"payload", mustBin("0xdeadbeef"), big.NewInt(1), false},
+    {"large nonce", mustBin("0x00"), new(big.Int).Lsh(big.NewInt(1), 200), false},
+    {"nil nonce", []byte{}, nil, true},
+    {"negative nonce", []byte{}, big.NewInt(-1), true},
+  }
+  for _, tt := range tests {
+    hash, err := signedhandler.SignedInputHash(mailDomain, tt.payload, tt.nonce)
+    if tt.err {
+      if err == nil {
+        t.Errorf("%s: expected error", tt.name)
+      }
+      continue
+    }
+    if err != nil {
+      t.Errorf("%s: %s", tt.name, err)
+      continue
+    }
+    // hashStruct = keccak256(typeHash || keccak256(payload) || uint256(nonce))
+    encoded := ethgo.Keccak256([]byte("SignedInput(bytes payload,uint256 nonce)"))
+    encoded = append(encoded, ethgo.Keccak256(tt.payload)...)
+    encoded = append(encoded, tt.nonce.FillBytes(make([]byte, 32))...)
+    expected := mailDomain.Hash(mustHash(rollups.Bin2Hex(ethgo.Keccak256(encoded))))
+    if hash != expected {
+      t.Errorf("%s: hash %s, expected %s", tt.name, hash, expected)
+    }
+  }
+}
+
+func mustBin(hex string) []byte {
+  bin, err := rollups.Hex2Bin(hex)
+  if err != nil {
+    panic(err)
+  }
+  return bin
+}
+
+func mustHash(hex string) ethgo.Hash {
+  var hash ethgo.Hash
+  copy(hash[:], mustBin(hex))
+  return hash
+}
+
+func mustAddress(hex string) abihandler.Address {
+  address, err := abihandler.Hex2Address(hex)
+  if err != nil {
+    panic(err)
+  }
+  return address
+}
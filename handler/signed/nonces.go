@@ -0,0 +1,50 @@
+package signedhandler
+
+import (
+  "fmt"
+  "math/big"
+
+  "github.com/prototyp3-dev/go-rollups/handler/abi"
+)
+
+// Nonces are the next nonces of the signers. A signed input must carry the
+// next nonce of its signer, so it is only processed once.
+type Nonces struct {
+  next map[abihandler.Address]uint64
+}
+
+func NewNonces() *Nonces {
+  return &Nonces{next: make(map[abihandler.Address]uint64)}
+}
+
+// Nonce returns the nonce of the next input signed by signer
+func (n *Nonces) Nonce(signer abihandler.Address) uint64 {
+  return n.next[signer]
+}
+
+// Use checks nonce is the next nonce of signer and increments it
+func (n *Nonces) Use(signer abihandler.Address, nonce *big.Int) error {
+  expected := n.next[signer]
+  if nonce == nil || !nonce.IsUint64() || nonce.Uint64() != expected {
+    return fmt.Errorf("invalid nonce %s for %s, expected %d", nonce, signer, expected)
+  }
+  n.next[signer] = expected + 1
+  return nil
+}
+
+func (n *Nonces) Snapshot() (interface{}, error) {
+  snapshot := make(map[abihandler.Address]uint64, len(n.next))
+  for signer, nonce := range n.next {
+    snapshot[signer] = nonce
+  }
+  return snapshot, nil
+}
+
+func (n *Nonces) Restore(snapshot interface{}) error {
+  next, ok := snapshot.(map[abihandler.Address]uint64)
+  if !ok {
+    return fmt.Errorf("invalid nonces snapshot")
+  }
+  n.next = next
+  return nil
+}
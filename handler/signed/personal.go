@@ -0,0 +1,197 @@
+package signedhandler
+
+import (
+  "bytes"
+  "context"
+  "encoding/json"
+  "fmt"
+  "math/big"
+  "strings"
+
+  "github.com/prototyp3-dev/go-rollups/rollups"
+  hdl "github.com/prototyp3-dev/go-rollups/handler"
+  "github.com/prototyp3-dev/go-rollups/handler/abi"
+)
+
+// PersonalHandler verifies the inputs of json and uri routes signed with
+// personal_sign. The signed message is bound to the app and the chain:
+//
+//  app: <app address, 0x lowercase hex>
+//  chain: <chain id, decimal>
+//  <the json or the uri>
+//
+// The app address and the chain id come from the v2 metadata (or SetChainId
+// and SetAppAddress, for rollups v1 and the inspects).
+type PersonalHandler struct {
+  Nonces *Nonces
+  binding
+}
+
+// AddPersonalHandler returns a personal handler with a nonce store rolled back
+// with the rejected inputs of handler
+func AddPersonalHandler(handler *hdl.Handler) *PersonalHandler {
+  nonces := NewNonces()
+  handler.RegisterState(nonces)
+  return NewPersonalHandler(nonces)
+}
+
+// NewPersonalHandler returns a personal handler using nonces, e.g. the Nonces
+// of a signed handler, which must be registered on the handler
+func NewPersonalHandler(nonces *Nonces) *PersonalHandler {
+  if nonces == nil {
+    panic("signed handler: nil nonces")
+  }
+  return &PersonalHandler{Nonces: nonces}
+}
+
+// SignedJson wraps the handler of a json route whose inputs are signed. The
+// json in the signed message is the input without the signature field, with
+// sorted keys and no spaces, e.g. {"amount":10,"nonce":0,"route":"transfer"}.
+// The signer is added to the params as "signer" and, on advance, the "nonce"
+// field must be its next nonce, an integer.
+func (h *PersonalHandler) SignedJson(fnHandle hdl.ContextHandlerFunc) hdl.ContextHandlerFunc {
+  if fnHandle == nil {
+    panic("signed handler: nil handler")
+  }
+  return func(ctx context.Context, req *hdl.Request) error {
+    message, fields, signature, err := jsonMessage(req.Payload)
+    if err != nil {
+      return fmt.Errorf("SignedJson: %s", err)
+    }
+    if err = h.verify(req, message, signature, fields["nonce"]); err != nil {
+      return fmt.Errorf("SignedJson: %s", err)
+    }
+    return fnHandle(ctx, req)
+  }
+}
+
+// SignedUri wraps the handler of a uri route whose inputs are signed. The
+// signature is the last query param and the uri in the signed message is the
+// uri before it, e.g. /transfer/0x...?amount=10&nonce=0 for
+// /transfer/0x...?amount=10&nonce=0&signature=0x... The signer is added to
+// the params as "signer" and, on advance, the "nonce" param must be its next
+// nonce.
+func (h *PersonalHandler) SignedUri(fnHandle hdl.ContextHandlerFunc) hdl.ContextHandlerFunc {
+  if fnHandle == nil {
+    panic("signed handler: nil handler")
+  }
+  return func(ctx context.Context, req *hdl.Request) error {
+    message, signature, err := uriMessage(req.Payload)
+    if err != nil {
+      return fmt.Errorf("SignedUri: %s", err)
+    }
+    if err = h.verify(req, message, signature, req.Params["nonce"]); err != nil {
+      return fmt.Errorf("SignedUri: %s", err)
+    }
+    return fnHandle(ctx, req)
+  }
+}
+
+// Message returns the message signed for the json or uri of an input with
+// metadata
+func (h *PersonalHandler) Message(metadata *rollups.Metadata, message []byte) ([]byte,error) {
+  chainId, appAddress, err := h.resolve(metadata)
+  if err != nil {
+    return nil, fmt.Errorf("Message: %s", err)
+  }
+  prefix := fmt.Sprintf("app: %s\nchain: %d\n", abihandler.Address2Hex(appAddress), chainId)
+  return append([]byte(prefix), message...), nil
+}
+
+// jsonMessage returns the canonical json of the payload without the
+// signature, and its fields
+func jsonMessage(payloadHex string) ([]byte,map[string]interface{},[]byte,error) {
+  payload, err := rollups.Hex2Bin(payloadHex)
+  if err != nil {
+    return nil, nil, nil, err
+  }
+  var fields map[string]interface{}
+  decoder := json.NewDecoder(bytes.NewReader(payload))
+  // numbers keep the text the client signed
+  decoder.UseNumber()
+  if err = decoder.Decode(&fields); err != nil {
+    return nil, nil, nil, err
+  }
+  signatureHex, ok := fields["signature"].(string)
+  if !ok {
+    return nil, nil, nil, fmt.Errorf("no signature")
+  }
+  delete(fields, "signature")
+  signature, err := rollups.Hex2Bin(signatureHex)
+  if err != nil {
+    return nil, nil, nil, fmt.Errorf("invalid signature: %s", err)
+  }
+
+  var message bytes.Buffer
+  encoder := json.NewEncoder(&message)
+  encoder.SetEscapeHTML(false)
+  if err = encoder.Encode(fields); err != nil {
+    return nil, nil, nil, err
+  }
+  return bytes.TrimSuffix(message.Bytes(), []byte("\n")), fields, signature, nil
+}
+
+// uriMessage returns the uri of the payload before the signature query param
+func uriMessage(payloadHex string) ([]byte,[]byte,error) {
+  uri, err := rollups.Hex2Str(payloadHex)
+  if err != nil {
+    return nil, nil, err
+  }
+  i := strings.LastIndex(uri, "signature=")
+  if i < 1 || (uri[i-1] != '?' && uri[i-1] != '&') || strings.IndexByte(uri[i:], '&') >= 0 {
+    return nil, nil, fmt.Errorf("no signature, it must be the last query param")
+  }
+  signature, err := rollups.Hex2Bin(uri[i+len("signature="):])
+  if err != nil {
+    return nil, nil, fmt.Errorf("invalid signature: %s", err)
+  }
+  return []byte(uri[:i-1]), signature, nil
+}
+
+// parseNonce returns the nonce of a json field or uri param, it must be a
+// decimal integer
+func parseNonce(value interface{}) (*big.Int,error) {
+  var text string
+  switch v := value.(type) {
+  case json.Number:
+    text = v.String()
+  case string:
+    text = v
+  case int:
+    return big.NewInt(int64(v)), nil
+  case *big.Int:
+    return v, nil
+  default:
+    return nil, fmt.Errorf("invalid nonce")
+  }
+  nonce, ok := new(big.Int).SetString(text, 10)
+  if !ok {
+    return nil, fmt.Errorf("invalid nonce %q", text)
+  }
+  return nonce, nil
+}
+
+func (h *PersonalHandler) verify(req *hdl.Request, message []byte, signature []byte, nonceValue interface{}) error {
+  message, err := h.Message(req.Metadata, message)
+  if err != nil {
+    return err
+  }
+  signer, err := abihandler.RecoverPersonalSign(message, signature)
+  if err != nil {
+    return err
+  }
+  if req.IsAdvance() {
+    nonce, err := parseNonce(nonceValue)
+    if err != nil {
+      return err
+    }
+    if err = h.Nonces.Use(signer, nonce); err != nil {
+      return err
+    }
+  }
+  if req.Params == nil {
+    req.Params = make(map[string]interface{})
+  }
+  req.Params["signer"] = signer
+  return nil
+}
@@ -0,0 +1,181 @@
+package signedhandler_test
+
+import (
+  "context"
+  "fmt"
+  "strings"
+  "testing"
+
+  "github.com/prototyp3-dev/go-rollups/rollups"
+  hdl "github.com/prototyp3-dev/go-rollups/handler"
+  "github.com/prototyp3-dev/go-rollups/handler/abi"
+  "github.com/prototyp3-dev/go-rollups/handler/handlertest"
+  "github.com/prototyp3-dev/go-rollups/handler/json"
+  "github.com/prototyp3-dev/go-rollups/handler/signed"
+  "github.com/prototyp3-dev/go-rollups/handler/uri"
+)
+
+// personalApp has json and uri routes signed with personal_sign, that record
+// the signer of each accepted input
+type personalApp struct {
+  driver *handlertest.Driver
+  personal *signedhandler.PersonalHandler
+  signers []abihandler.Address
+  index uint64
+}
+
+func newPersonalApp() *personalApp {
+  app := &personalApp{}
+  handler := hdl.NewSimpleHandler()
+  jsonHandler := jsonhandler.AddJsonHandler("route", handler)
+  uriHandler := urihandler.AddUriHandler(handler)
+  app.personal = signedhandler.AddPersonalHandler(handler)
+  record := func(ctx context.Context, req *hdl.Request) error {
+    app.signers = append(app.signers, req.Params["signer"].(abihandler.Address))
+    return nil
+  }
+  jsonHandler.HandleAdvanceRouteContext("transfer", app.personal.SignedJson(record))
+  jsonHandler.HandleAdvanceRouteContext("fail", app.personal.SignedJson(func(ctx context.Context, req *hdl.Request) error {
+    return fmt.Errorf("fail")
+  }))
+  jsonHandler.HandleInspectRouteContext("whoami", app.personal.SignedJson(record))
+  uriHandler.HandleAdvanceRouteContext("/transfer/:amount<int>", app.personal.SignedUri(record))
+  app.driver = handlertest.NewDriver(handler)
+  return app
+}
+
+func (app *personalApp) advance(payload string) bool {
+  metadata := &rollups.Metadata{MsgSender: relayer, ChainId: chainId, AppContract: appContract, InputIndex: app.index}
+  app.index++
+  signers := len(app.signers)
+  result := app.driver.AdvanceMetadata(metadata, rollups.Str2Hex(payload))
+  if !result.Accepted() {
+    app.signers = app.signers[:signers]
+  }
+  return result.Accepted()
+}
+
+// personalSign returns the signature of message bound to app and chain
+func personalSign(app string, chain uint64, message string) string {
+  signed := fmt.Sprintf("app: %s\nchain: %d\n%s", app, chain, message)
+  return rollups.Bin2Hex(sign(signerKey, abihandler.PersonalSignHash([]byte(signed))))
+}
+
+// signedJson returns the json input with the signature of the canonical json,
+// the input fields are in another order and with spaces
+func signedJson(app string, chain uint64, canonical string, input string) string {
+  return strings.Replace(input, "}", fmt.Sprintf(`, "signature": "%s"}`, personalSign(app, chain, canonical)), 1)
+}
+
+func TestSignedJson(t *testing.T) {
+  app := newPersonalApp()
+  transfer := func(nonce string) (string, string) {
+    return `{"amount":10,"nonce":` + nonce + `,"route":"transfer"}`, `{"route": "transfer", "nonce": ` + nonce + `, "amount": 10}`
+  }
+  valid := func(nonce string) string {
+    canonical, input := transfer(nonce)
+    return signedJson(appContract, chainId, canonical, input)
+  }
+  canonical1, input1 := transfer("1")
+  unbound := strings.Replace(input1, "}", `, "signature": "` +
+    rollups.Bin2Hex(sign(signerKey, abihandler.PersonalSignHash([]byte(canonical1)))) + `"}`, 1)
+  fail := signedJson(appContract, chainId, `{"nonce":1,"route":"fail"}`, `{"route": "fail", "nonce": 1}`)
+
+  // the steps run in order on the same app. A signature of another message
+  // recovers another address, so the signer nonce is invalid for it.
+  steps := []struct {
+    name string
+    payload string
+    accepted bool
+  }{
+    {"first nonce", valid("0"), true},
+    {"replayed", valid("0"), false},
+    {"skipped nonce", valid("2"), false},
+    {"other app", signedJson("0x0000000000000000000000000000000000000001", chainId, canonical1, input1), false},
+    {"other chain", signedJson(appContract, 1, canonical1, input1), false},
+    {"not bound to the app", unbound, false},
+    {"no signature", input1, false},
+    {"exponent nonce", valid("1e0"), false},
+    {"fraction nonce", valid("1.5"), false},
+    {"string nonce", valid(`"1x"`), false},
+    {"rejected route", fail, false},
+    {"nonce of the rejected input", valid("1"), true},
+    {"string decimal nonce", valid(`"2"`), true},
+  }
+  for _, step := range steps {
+    if accepted := app.advance(step.payload); accepted != step.accepted {
+      t.Errorf("%s: accepted %t, expected %t", step.name, accepted, step.accepted)
+    }
+  }
+  if len(app.signers) != 3 {
+    t.Fatalf("%d accepted inputs, expected 3", len(app.signers))
+  }
+  for _, signer := range app.signers {
+    if signer != signerKey.Address() {
+      t.Errorf("signer %s, expected %s", signer, signerKey.Address())
+    }
+  }
+}
+
+func TestSignedJsonLargeNonce(t *testing.T) {
+  app := newPersonalApp()
+  // the json numbers of large nonces are kept as integers, e.g. not 1e+06
+  nonces := map[abihandler.Address]uint64{signerKey.Address(): 1000000}
+  if err := app.personal.Nonces.Restore(nonces); err != nil {
+    t.Fatal(err)
+  }
+  canonical := `{"amount":10,"nonce":1000000,"route":"transfer"}`
+  if !app.advance(signedJson(appContract, chainId, canonical, canonical)) {
+    t.Errorf("nonce 1000000 rejected")
+  }
+  if nonce := app.personal.Nonces.Nonce(signerKey.Address()); nonce != 1000001 {
+    t.Errorf("next nonce %d, expected 1000001", nonce)
+  }
+}
+
+func TestSignedJsonInspect(t *testing.T) {
+  canonical := `{"route":"whoami"}`
+  payload := rollups.Str2Hex(signedJson(appContract, chainId, canonical, canonical))
+
+  app := newPersonalApp()
+  // inspects have no metadata
+  if result := app.driver.Inspect(payload); result.Accepted() {
+    t.Errorf("inspect accepted with no app address and chain id")
+  }
+  app.personal.SetAppAddress(mustAddress(appContract))
+  app.personal.SetChainId(chainId)
+  if result := app.driver.Inspect(payload); !result.Accepted() {
+    t.Errorf("inspect rejected: %s", result.Err)
+  }
+  if len(app.signers) != 1 || app.signers[0] != signerKey.Address() {
+    t.Errorf("signers %v, expected %s", app.signers, signerKey.Address())
+  }
+}
+
+func TestSignedUri(t *testing.T) {
+  app := newPersonalApp()
+  signedUri := func(uri string) string {
+    return uri + "&signature=" + personalSign(appContract, chainId, uri)
+  }
+  steps := []struct {
+    name string
+    payload string
+    accepted bool
+  }{
+    {"first nonce", signedUri("/transfer/10?nonce=0"), true},
+    {"replayed", signedUri("/transfer/10?nonce=0"), false},
+    {"other amount", strings.Replace(signedUri("/transfer/10?nonce=1"), "/10?", "/11?", 1), false},
+    {"signature not last", strings.Replace(signedUri("/transfer/10?nonce=1"), "?nonce=1&", "?", 1) + "&nonce=1", false},
+    {"repeated nonce", signedUri("/transfer/10?nonce=1&nonce=1"), false},
+    {"other chain", "/transfer/10?nonce=1&signature=" + personalSign(appContract, 1, "/transfer/10?nonce=1"), false},
+    {"next nonce", signedUri("/transfer/10?nonce=1"), true},
+  }
+  for _, step := range steps {
+    if accepted := app.advance(step.payload); accepted != step.accepted {
+      t.Errorf("%s: accepted %t, expected %t", step.name, accepted, step.accepted)
+    }
+  }
+  if len(app.signers) != 2 || app.signers[0] != signerKey.Address() || app.signers[1] != signerKey.Address() {
+    t.Errorf("signers %v, expected %s twice", app.signers, signerKey.Address())
+  }
+}
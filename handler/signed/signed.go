@@ -0,0 +1,122 @@
+package signedhandler
+
+import (
+  "context"
+  "fmt"
+  "math/big"
+
+  "github.com/prototyp3-dev/go-rollups/rollups"
+  hdl "github.com/prototyp3-dev/go-rollups/handler"
+  "github.com/prototyp3-dev/go-rollups/handler/abi"
+)
+
+// SignedInput is an input of the application routes signed by its sender
+// with EIP-712, so a relayer can submit it
+type SignedInput struct {
+  Payload []byte `abi:"bytes payload"`
+  Nonce *big.Int `abi:"uint256 nonce"`
+  Signature []byte `abi:"bytes signature"`
+}
+
+var SignedInputCodec = abihandler.NewHeaderStructCodec("rollups", "SignedInput", SignedInput{})
+// SignedInputsCodec is a batch of signed inputs, processed in order
+var SignedInputsCodec = abihandler.NewHeaderCodec("rollups", "SignedInputs", []string{"(bytes payload,uint256 nonce,bytes signature)[] inputs"})
+// NonceCodec is the inspect route that reports the next nonce of a signer
+var NonceCodec = abihandler.NewHeaderCodec("rollups", "Nonce", []string{"address signer"})
+
+var nonceReportCodec = abihandler.NewCodec([]string{"uint256"})
+
+// SignedHandler verifies the signed inputs and routes their payloads with the
+// signer as the msg sender, so the application routes authorize the signer
+// instead of the relayer. The outputs of the signed payloads are outputs of
+// the relayed input, and any invalid signed input rejects it.
+type SignedHandler struct {
+  Handler *hdl.Handler
+  Name string
+  Version string
+  Nonces *Nonces
+  binding
+}
+
+// AddSignedHandler adds the signed input routes to abiHdl, the domain of the
+// signatures has name and version
+func AddSignedHandler(abiHdl *abihandler.AbiHandler, name string, version string) *SignedHandler {
+  h := SignedHandler{Handler: abiHdl.Handler, Name: name, Version: version, Nonces: NewNonces()}
+  h.Handler.RegisterState(h.Nonces)
+  abiHdl.HandleAdvanceRouteContext(SignedInputCodec, h.HandleSignedInput)
+  abiHdl.HandleAdvanceRouteContext(SignedInputsCodec, h.HandleSignedInputs)
+  abiHdl.HandleInspectRouteContext(NonceCodec, h.HandleNonce)
+  return &h
+}
+
+// Domain returns the domain of the signatures of the inputs with metadata
+func (h *SignedHandler) Domain(metadata *rollups.Metadata) (Domain,error) {
+  chainId, appAddress, err := h.resolve(metadata)
+  domain := Domain{Name: h.Name, Version: h.Version, ChainId: chainId, VerifyingContract: appAddress}
+  if err != nil {
+    return domain, fmt.Errorf("Domain: %s", err)
+  }
+  return domain, nil
+}
+
+func (h *SignedHandler) HandleSignedInput(ctx context.Context, req *hdl.Request) error {
+  var input SignedInput
+  if err := abihandler.DecodeParams(req.Params, &input); err != nil {
+    return fmt.Errorf("HandleSignedInput: parameters error: %s", err)
+  }
+  return h.process(req, input)
+}
+
+func (h *SignedHandler) HandleSignedInputs(ctx context.Context, req *hdl.Request) error {
+  inputs, ok := req.Params["inputs"].([]map[string]interface{})
+  if !ok {
+    return fmt.Errorf("HandleSignedInputs: parameters error")
+  }
+  for i, params := range inputs {
+    var input SignedInput
+    if err := abihandler.DecodeParams(params, &input); err != nil {
+      return fmt.Errorf("HandleSignedInputs: input %d: parameters error: %s", i, err)
+    }
+    if err := h.process(req, input); err != nil {
+      return fmt.Errorf("HandleSignedInputs: input %d: %w", i, err)
+    }
+  }
+  return nil
+}
+
+// process verifies the input and routes its payload with the signer as sender
+func (h *SignedHandler) process(req *hdl.Request, input SignedInput) error {
+  domain, err := h.Domain(req.Metadata)
+  if err != nil {
+    return err
+  }
+  hash, err := SignedInputHash(domain, input.Payload, input.Nonce)
+  if err != nil {
+    return err
+  }
+  signer, err := abihandler.RecoverSigner(hash, input.Signature)
+  if err != nil {
+    return err
+  }
+  if err = h.Nonces.Use(signer, input.Nonce); err != nil {
+    return err
+  }
+  req.Logger().Debug("Signed input", "signer", signer, "nonce", input.Nonce)
+
+  metadata := *req.Metadata
+  metadata.MsgSender = abihandler.Address2Hex(signer)
+  return h.Handler.ProcessNested(&metadata, rollups.Bin2Hex(input.Payload))
+}
+
+// HandleNonce reports the next nonce of the signer
+func (h *SignedHandler) HandleNonce(ctx context.Context, req *hdl.Request) error {
+  signer, ok := req.Params["signer"].(abihandler.Address)
+  if !ok {
+    return fmt.Errorf("HandleNonce: parameters error")
+  }
+  report, err := nonceReportCodec.Encode([]interface{}{new(big.Int).SetUint64(h.Nonces.Nonce(signer))})
+  if err != nil {
+    return fmt.Errorf("HandleNonce: %s", err)
+  }
+  return req.Report(report)
+}
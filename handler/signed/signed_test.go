@@ -0,0 +1,203 @@
+package signedhandler_test
+
+import (
+  "context"
+  "fmt"
+  "math/big"
+  "testing"
+
+  "github.com/prototyp3-dev/go-rollups/rollups"
+  hdl "github.com/prototyp3-dev/go-rollups/handler"
+  "github.com/prototyp3-dev/go-rollups/handler/abi"
+  "github.com/prototyp3-dev/go-rollups/handler/handlertest"
+  "github.com/prototyp3-dev/go-rollups/handler/signed"
+
+  "github.com/umbracle/ethgo"
+  "github.com/umbracle/ethgo/wallet"
+)
+
+const (
+  chainId = 31337
+  appContract = "0xab7528bb862fb57e8a2bcd567a2e929a0be56a5e"
+  relayer = "0x70997970c51812dc3a010c7d01b50e0d17dc79c8"
+)
+
+// signerKey is the account of the web3.js accounts.sign example
+var signerKey = mustKey("0x4c0883a69102937d6231471b5dbb6204fe5129617082792ae468d01a3f362318")
+
+var echoCodec = abihandler.NewHeaderCodec("test", "Echo", []string{"string text"})
+var failCodec = abihandler.NewHeaderCodec("test", "Fail", []string{})
+
+// signedApp routes the signed inputs to an echo route, that records the msg
+// sender of each accepted input, and a route that always fails
+type signedApp struct {
+  driver *handlertest.Driver
+  signed *signedhandler.SignedHandler
+  senders []string
+  index uint64
+}
+
+func newSignedApp() *signedApp {
+  app := &signedApp{}
+  abiHandler := abihandler.AddAbiHandler(hdl.NewSimpleHandler())
+  app.signed = signedhandler.AddSignedHandler(abiHandler, "Test", "1")
+  abiHandler.HandleAdvanceRouteContext(echoCodec, func(ctx context.Context, req *hdl.Request) error {
+    app.senders = append(app.senders, req.Metadata.MsgSender)
+    return nil
+  })
+  abiHandler.HandleAdvanceRouteContext(failCodec, func(ctx context.Context, req *hdl.Request) error {
+    return fmt.Errorf("fail")
+  })
+  app.driver = handlertest.NewDriver(abiHandler.Handler)
+  return app
+}
+
+func (app *signedApp) advance(payloadHex string) bool {
+  metadata := &rollups.Metadata{MsgSender: relayer, ChainId: chainId, AppContract: appContract, InputIndex: app.index}
+  app.index++
+  senders := len(app.senders)
+  result := app.driver.AdvanceMetadata(metadata, payloadHex)
+  if !result.Accepted() {
+    app.senders = app.senders[:senders]
+  }
+  return result.Accepted()
+}
+
+func appDomain() signedhandler.Domain {
+  return signedhandler.Domain{Name: "Test", Version: "1", ChainId: chainId, VerifyingContract: mustAddress(appContract)}
+}
+
+// signedInput returns the signed input of payload signed in domain
+func signedInput(domain signedhandler.Domain, payloadHex string, nonce int64) signedhandler.SignedInput {
+  payload := mustBin(payloadHex)
+  hash, err := signedhandler.SignedInputHash(domain, payload, big.NewInt(nonce))
+  if err != nil {
+    panic(err)
+  }
+  return signedhandler.SignedInput{Payload: payload, Nonce: big.NewInt(nonce), Signature: sign(signerKey, hash)}
+}
+
+func encode(codec *abihandler.Codec, values interface{}) string {
+  payload, err := codec.Encode(values)
+  if err != nil {
+    panic(err)
+  }
+  return payload
+}
+
+func TestSignedInput(t *testing.T) {
+  app := newSignedApp()
+  echo := encode(echoCodec, []interface{}{"hi"})
+  fail := encode(failCodec, []interface{}{})
+  otherApp := appDomain()
+  otherApp.VerifyingContract = mustAddress("0x0000000000000000000000000000000000000001")
+  otherChain := appDomain()
+  otherChain.ChainId = 1
+  otherName := appDomain()
+  otherName.Name = "Other"
+  short := signedInput(appDomain(), echo, 1)
+  short.Signature = short.Signature[:64]
+
+  // the steps run in order on the same app. A signature of another domain
+  // recovers another address, so the signer nonce is invalid for it and the
+  // input is never routed as sent by the signer.
+  steps := []struct {
+    name string
+    input signedhandler.SignedInput
+    accepted bool
+  }{
+    {"first nonce", signedInput(appDomain(), echo, 0), true},
+    {"replayed", signedInput(appDomain(), echo, 0), false},
+    {"skipped nonce", signedInput(appDomain(), echo, 2), false},
+    {"other app", signedInput(otherApp, echo, 1), false},
+    {"other chain", signedInput(otherChain, echo, 1), false},
+    {"other domain name", signedInput(otherName, echo, 1), false},
+    {"short signature", short, false},
+    {"rejected payload", signedInput(appDomain(), fail, 1), false},
+    {"nonce of the rejected input", signedInput(appDomain(), echo, 1), true},
+    {"unknown payload", signedInput(appDomain(), "0x00", 2), false},
+  }
+  for _, step := range steps {
+    if accepted := app.advance(encode(signedhandler.SignedInputCodec, step.input)); accepted != step.accepted {
+      t.Errorf("%s: accepted %t, expected %t", step.name, accepted, step.accepted)
+    }
+  }
+  signer := abihandler.Address2Hex(signerKey.Address())
+  if len(app.senders) != 2 || app.senders[0] != signer || app.senders[1] != signer {
+    t.Errorf("senders %v, expected the signer %s twice", app.senders, signer)
+  }
+  if nonce := app.signed.Nonces.Nonce(signerKey.Address()); nonce != 2 {
+    t.Errorf("next nonce %d, expected 2", nonce)
+  }
+}
+
+func TestSignedInputs(t *testing.T) {
+  echo := encode(echoCodec, []interface{}{"hi"})
+  batch := func(inputs ...signedhandler.SignedInput) string {
+    values := make([]map[string]interface{}, len(inputs))
+    for i, input := range inputs {
+      values[i] = map[string]interface{}{"payload": input.Payload, "nonce": input.Nonce, "signature": input.Signature}
+    }
+    return encode(signedhandler.SignedInputsCodec, []interface{}{values})
+  }
+
+  tests := []struct {
+    name string
+    payload string
+    accepted bool
+    senders int
+    nonce uint64
+  }{
+    {"batch", batch(signedInput(appDomain(), echo, 0), signedInput(appDomain(), echo, 1)), true, 2, 2},
+    {"replay in batch", batch(signedInput(appDomain(), echo, 0), signedInput(appDomain(), echo, 0)), false, 0, 0},
+    {"invalid last input", batch(signedInput(appDomain(), echo, 0), signedInput(appDomain(), echo, 5)), false, 0, 0},
+  }
+  for _, tt := range tests {
+    app := newSignedApp()
+    if accepted := app.advance(tt.payload); accepted != tt.accepted {
+      t.Errorf("%s: accepted %t, expected %t", tt.name, accepted, tt.accepted)
+    }
+    if len(app.senders) != tt.senders {
+      t.Errorf("%s: %d routed inputs, expected %d", tt.name, len(app.senders), tt.senders)
+    }
+    if nonce := app.signed.Nonces.Nonce(signerKey.Address()); nonce != tt.nonce {
+      t.Errorf("%s: next nonce %d, expected %d", tt.name, nonce, tt.nonce)
+    }
+  }
+}
+
+func TestNonceInspect(t *testing.T) {
+  app := newSignedApp()
+  if !app.advance(encode(signedhandler.SignedInputCodec, signedInput(appDomain(), encode(echoCodec, []interface{}{"hi"}), 0))) {
+    t.Fatal("signed input rejected")
+  }
+  result := app.driver.Inspect(encode(signedhandler.NonceCodec, []interface{}{signerKey.Address()}))
+  if !result.Accepted() || len(result.Reports) != 1 {
+    t.Fatalf("inspect status %s with %d reports", result.Status, len(result.Reports))
+  }
+  report, err := abihandler.NewCodec([]string{"uint256"}).Decode(result.Reports[0].Payload)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if nonce, ok := report["0"].(*big.Int); !ok || nonce.Uint64() != 1 {
+    t.Errorf("reported nonce %v, expected 1", report["0"])
+  }
+}
+
+// sign signs hash as eth_sign does, with v 27 or 28
+func sign(key *wallet.Key, hash ethgo.Hash) []byte {
+  signature, err := key.Sign(hash[:])
+  if err != nil {
+    panic(err)
+  }
+  signature[64] += 27
+  return signature
+}
+
+func mustKey(hex string) *wallet.Key {
+  key, err := wallet.NewWalletFromPrivKey(mustBin(hex))
+  if err != nil {
+    panic(err)
+  }
+  return key
+}
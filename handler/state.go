@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Stateful is application state that is rolled back when an advance is
+// rejected. Snapshot must return a copy that later changes to the state don't
+// modify, Restore sets the state back to a snapshot.
+type Stateful interface {
+  Snapshot() (interface{}, error)
+  Restore(snapshot interface{}) error
+}
+
+// Committer is optionally implemented by the states that keep changes to
+// restore their last snapshot (e.g. copy on write), Commit is called when the
+// advance is accepted so they can drop them.
+type Committer interface {
+  Commit()
+}
+
+// RegisterState checkpoints state before each advance and restores it when
+// the advance is rejected, so it only keeps the changes of accepted inputs.
+// Snapshot is called on every advance, so its cost adds to each input.
+// Registering the same state again does nothing.
+func (h *Handler) RegisterState(state Stateful) {
+  if state == nil {
+    panic("rollups handler: nil state")
+  }
+  if reflect.TypeOf(state).Comparable() {
+    for _, registered := range h.states {
+      if registered == state {
+        return
+      }
+    }
+  }
+  h.states = append(h.states, state)
+}
+
+func (h *Handler) snapshotStates() ([]interface{}, error) {
+  snapshots := make([]interface{}, len(h.states))
+  for i, state := range h.states {
+    snapshot, err := state.Snapshot()
+    if err != nil {
+      return nil, fmt.Errorf("error taking state snapshot: %s", err)
+    }
+    snapshots[i] = snapshot
+  }
+  return snapshots, nil
+}
+
+func (h *Handler) restoreStates(snapshots []interface{}) error {
+  for i, state := range h.states {
+    if err := state.Restore(snapshots[i]); err != nil {
+      return fmt.Errorf("error restoring state: %s", err)
+    }
+  }
+  return nil
+}
+
+func (h *Handler) commitStates() {
+  for _, state := range h.states {
+    if committer, ok := state.(Committer); ok {
+      committer.Commit()
+    }
+  }
+}
@@ -0,0 +1,84 @@
+package handler_test
+
+import (
+  "fmt"
+  "testing"
+
+  hdl "github.com/prototyp3-dev/go-rollups/handler"
+  "github.com/prototyp3-dev/go-rollups/handler/handlertest"
+  "github.com/prototyp3-dev/go-rollups/rollups"
+)
+
+// counter counts the inputs and the calls of the state methods
+type counter struct {
+  value int
+  snapshots int
+  commits int
+}
+
+func (c *counter) Snapshot() (interface{}, error) {
+  c.snapshots++
+  return c.value, nil
+}
+
+func (c *counter) Restore(snapshot interface{}) error {
+  c.value = snapshot.(int)
+  return nil
+}
+
+func (c *counter) Commit() {
+  c.commits++
+}
+
+func TestRegisterState(t *testing.T) {
+  state := &counter{}
+  h := hdl.NewSimpleHandler()
+  h.RegisterState(state)
+  h.RegisterState(state)
+  h.HandleAdvance(func(metadata *rollups.Metadata, payloadHex string) error {
+    state.value++
+    switch payload, _ := rollups.Hex2Str(payloadHex); payload {
+    case "fail":
+      return fmt.Errorf("fail")
+    case "panic":
+      panic("boom")
+    }
+    return nil
+  })
+  h.HandleInspect(func(payloadHex string) error {
+    state.value++
+    return nil
+  })
+  driver := handlertest.NewDriver(h)
+
+  steps := []struct {
+    payload string
+    inspect bool
+    accepted bool
+    value int
+    snapshots int
+    commits int
+  }{
+    {"ok", false, true, 1, 1, 1},
+    {"fail", false, false, 1, 2, 1},
+    {"panic", false, false, 1, 3, 1},
+    // inspects aren't snapshot, nor rolled back
+    {"ok", true, true, 2, 3, 1},
+    {"ok", false, true, 3, 4, 2},
+  }
+  for i, step := range steps {
+    var accepted bool
+    if step.inspect {
+      accepted = driver.Inspect(rollups.Str2Hex(step.payload)).Accepted()
+    } else {
+      accepted = driver.Advance(sender, rollups.Str2Hex(step.payload)).Accepted()
+    }
+    if accepted != step.accepted {
+      t.Errorf("step %d %s: accepted %t, expected %t", i, step.payload, accepted, step.accepted)
+    }
+    if state.value != step.value || state.snapshots != step.snapshots || state.commits != step.commits {
+      t.Errorf("step %d %s: value %d, snapshots %d, commits %d, expected %d, %d, %d", i, step.payload,
+        state.value, state.snapshots, state.commits, step.value, step.snapshots, step.commits)
+    }
+  }
+}
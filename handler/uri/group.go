@@ -0,0 +1,137 @@
+package urihandler
+
+import (
+  "strings"
+
+  hdl "github.com/prototyp3-dev/go-rollups/handler"
+)
+
+// UriGroup adds routes to a UriHandler under a prefix. The group middlewares
+// only wrap the routes of the group (and of its subgroups), inside the
+// handler middlewares.
+type UriGroup struct {
+  uri *UriHandler
+  parent *UriGroup
+  prefix string
+  middlewares []hdl.Middleware
+}
+
+// Group returns a router for the routes under prefix (e.g. "/market")
+func (h *UriHandler) Group(prefix string) *UriGroup {
+  return &UriGroup{uri: h, prefix: cleanPrefix(prefix)}
+}
+
+// Mount adds the routes of sub under prefix, the routes added to sub later
+// are mounted too. sub isn't changed and may be mounted under other prefixes
+// or handlers. The mounted routes run on this handler (the context routes of
+// sub get the request it's processing), the middlewares of the sub handler
+// itself are not used: add them to the returned group.
+func (h *UriHandler) Mount(prefix string, sub *UriHandler) *UriGroup {
+  return h.Group(prefix).mount(sub)
+}
+
+func cleanPrefix(prefix string) string {
+  prefix = strings.TrimRight(prefix, "/")
+  if prefix != "" && prefix[0] != '/' {
+    panic("uri handler: invalid prefix")
+  }
+  return prefix
+}
+
+func (g *UriGroup) Group(prefix string) *UriGroup {
+  return &UriGroup{uri: g.uri, parent: g, prefix: g.prefix + cleanPrefix(prefix)}
+}
+
+func (g *UriGroup) Mount(prefix string, sub *UriHandler) *UriGroup {
+  return g.Group(prefix).mount(sub)
+}
+
+// Use adds middlewares to the group, the first one is the outermost
+func (g *UriGroup) Use(middlewares ...hdl.Middleware) {
+  for _, middleware := range middlewares {
+    if middleware == nil {
+      panic("uri handler: nil middleware")
+    }
+    g.middlewares = append(g.middlewares, middleware)
+  }
+}
+
+func (g *UriGroup) HandleAdvanceRoute(route string, fnHandle AdvanceMapHandlerFunc) {
+  g.uri.addAdvanceRoute(g.join(route), fnHandle, g.chain())
+}
+
+func (g *UriGroup) HandleInspectRoute(route string, fnHandle InspectMapHandlerFunc) {
+  g.uri.addInspectRoute(g.join(route), fnHandle, g.chain())
+}
+
+func (g *UriGroup) HandleAdvanceRouteContext(route string, fnHandle hdl.ContextHandlerFunc) {
+  g.HandleAdvanceRoute(route, g.uri.ContextAdvance(fnHandle))
+}
+
+func (g *UriGroup) HandleInspectRouteContext(route string, fnHandle hdl.ContextHandlerFunc) {
+  g.HandleInspectRoute(route, g.uri.ContextInspect(fnHandle))
+}
+
+// join adds the group prefix to route, "/" is the prefix itself
+func (g *UriGroup) join(route string) string {
+  if g.prefix != "" && route == "/" {
+    return g.prefix
+  }
+  return g.prefix + route
+}
+
+func (g *UriGroup) joinAll(routes []string) []string {
+  joined := make([]string, len(routes))
+  for i, route := range routes {
+    joined[i] = g.join(route)
+  }
+  return joined
+}
+
+// chain returns the group and its parents, the outermost first
+func (g *UriGroup) chain() []*UriGroup {
+  var groups []*UriGroup
+  for group := g; group != nil; group = group.parent {
+    groups = append([]*UriGroup{group}, groups...)
+  }
+  return groups
+}
+
+func (g *UriGroup) mount(sub *UriHandler) *UriGroup {
+  if sub == nil {
+    panic("uri handler: nil handler")
+  }
+  if sub == g.uri || sub.Handler == g.uri.Handler || g.uri.mountedOn(sub) {
+    panic("uri handler: can't mount a uri handler on itself")
+  }
+  // nothing is mounted if a route conflicts
+  g.uri.checkAdvanceRoutes(g.joinAll(patterns(sub.advanceRoutes)))
+  g.uri.checkInspectRoutes(g.joinAll(patterns(sub.inspectRoutes)))
+  sub.mounts = append(sub.mounts, g)
+  for _, r := range sub.advanceRoutes {
+    g.uri.addAdvanceRoute(g.join(r.pattern), sub.RouteAdvanceHandlers[r.pattern].Handler, append(g.chain(), r.groups...))
+  }
+  for _, r := range sub.inspectRoutes {
+    g.uri.addInspectRoute(g.join(r.pattern), sub.RouteInspectHandlers[r.pattern].Handler, append(g.chain(), r.groups...))
+  }
+  return g
+}
+
+func patterns(routes []*uriRoute) []string {
+  result := make([]string, len(routes))
+  for i, r := range routes {
+    result[i] = r.pattern
+  }
+  return result
+}
+
+// mountedOn checks if h is mounted on target, directly or through the
+// handlers it's mounted on
+func (h *UriHandler) mountedOn(target *UriHandler) bool {
+  for _, mount := range h.mounts {
+    if mount.uri == target || mount.uri.mountedOn(target) {
+      return true
+    }
+  }
+  return false
+}
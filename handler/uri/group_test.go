@@ -0,0 +1,156 @@
+package urihandler
+
+import (
+  "context"
+  "fmt"
+  "reflect"
+  "testing"
+
+  hdl "github.com/prototyp3-dev/go-rollups/handler"
+  "github.com/prototyp3-dev/go-rollups/handler/handlertest"
+  "github.com/prototyp3-dev/go-rollups/rollups"
+)
+
+// record returns a middleware that adds name to calls
+func record(calls *[]string, name string) hdl.Middleware {
+  return func(next hdl.RouteFunc) hdl.RouteFunc {
+    return func(req *hdl.Request) error {
+      *calls = append(*calls, name)
+      return next(req)
+    }
+  }
+}
+
+func mustPanic(t *testing.T, name string, fn func()) {
+  t.Helper()
+  defer func() {
+    if recover() == nil {
+      t.Errorf("%s: didn't panic", name)
+    }
+  }()
+  fn()
+}
+
+func TestGroupMiddlewareOrder(t *testing.T) {
+  var calls []string
+  uriHandler := NewUriHandler()
+  uriHandler.Use(record(&calls, "handler"))
+  group := uriHandler.Group("/g")
+  group.Use(record(&calls, "group1"), record(&calls, "group2"))
+  subgroup := group.Group("/s")
+  subgroup.Use(record(&calls, "subgroup"))
+  subgroup.HandleInspectRoute("/x", func(params map[string]interface{}) error {
+    calls = append(calls, "route")
+    return nil
+  })
+  uriHandler.HandleInspectRoute("/x", func(params map[string]interface{}) error {
+    calls = append(calls, "route")
+    return nil
+  })
+  driver := handlertest.NewDriver(uriHandler.Handler)
+
+  tests := []struct {
+    path string
+    calls []string
+  }{
+    {"/g/s/x", []string{"handler", "group1", "group2", "subgroup", "route"}},
+    // the group middlewares only wrap the group routes
+    {"/x", []string{"handler", "route"}},
+  }
+  for _, tt := range tests {
+    calls = nil
+    if result := driver.Inspect(rollups.Str2Hex(tt.path)); !result.Accepted() {
+      t.Errorf("%s: rejected: %v", tt.path, result.Err)
+    }
+    if !reflect.DeepEqual(calls, tt.calls) {
+      t.Errorf("%s: calls %v, expected %v", tt.path, calls, tt.calls)
+    }
+  }
+}
+
+// TestMountTwice mounts a sub handler with a context route under two prefixes
+// of a handler and on another handler
+func TestMountTwice(t *testing.T) {
+  var calls []string
+  sub := NewUriHandler()
+  subHandler := sub.Handler
+  sub.HandleInspectRouteContext("/orders/:id<int>", func(ctx context.Context, req *hdl.Request) error {
+    calls = append(calls, fmt.Sprintf("%s %d", req.Route, req.Params["id"]))
+    return nil
+  })
+
+  uriHandler := NewUriHandler()
+  uriHandler.Mount("/a", sub).Use(record(&calls, "a"))
+  uriHandler.Mount("/b", sub)
+  other := NewUriHandler()
+  other.Mount("/c", sub)
+  if sub.Handler != subHandler {
+    t.Errorf("mount changed the handler of the sub handler")
+  }
+  // the routes added later are mounted under every prefix
+  sub.HandleInspectRouteContext("/list", func(ctx context.Context, req *hdl.Request) error {
+    calls = append(calls, req.Route)
+    return nil
+  })
+
+  driver, otherDriver := handlertest.NewDriver(uriHandler.Handler), handlertest.NewDriver(other.Handler)
+  tests := []struct {
+    driver *handlertest.Driver
+    path string
+    calls []string
+  }{
+    {driver, "/a/orders/1", []string{"a", "/a/orders/:id<int> 1"}},
+    {driver, "/b/orders/2", []string{"/b/orders/:id<int> 2"}},
+    {driver, "/a/list", []string{"a", "/a/list"}},
+    {driver, "/b/list", []string{"/b/list"}},
+    {otherDriver, "/c/orders/3", []string{"/c/orders/:id<int> 3"}},
+    {otherDriver, "/c/list", []string{"/c/list"}},
+  }
+  for _, tt := range tests {
+    calls = nil
+    if result := tt.driver.Inspect(rollups.Str2Hex(tt.path)); !result.Accepted() {
+      t.Errorf("%s: rejected: %v", tt.path, result.Err)
+    }
+    if !reflect.DeepEqual(calls, tt.calls) {
+      t.Errorf("%s: calls %v, expected %v", tt.path, calls, tt.calls)
+    }
+  }
+
+  // the sub handler still handles its own inputs
+  calls = nil
+  if result := handlertest.NewDriver(sub.Handler).Inspect(rollups.Str2Hex("/orders/4")); !result.Accepted() {
+    t.Errorf("sub handler: rejected: %v", result.Err)
+  }
+  if !reflect.DeepEqual(calls, []string{"/orders/:id<int> 4"}) {
+    t.Errorf("sub handler: calls %v", calls)
+  }
+}
+
+func TestMountConflict(t *testing.T) {
+  noop := func(params map[string]interface{}) error { return nil }
+  uriHandler := NewUriHandler()
+  uriHandler.HandleInspectRoute("/a/orders/:x<int>", noop)
+  sub := NewUriHandler()
+  sub.HandleInspectRoute("/list", noop)
+  sub.HandleInspectRoute("/orders/:id<int>", noop)
+
+  // a conflicting mount doesn't add any route
+  mustPanic(t, "conflicting mount", func() { uriHandler.Mount("/a", sub) })
+  if uriHandler.RouteInspectHandlers["/a/list"] != nil || len(uriHandler.inspectRoutes) != 1 || len(sub.mounts) != 0 {
+    t.Errorf("conflicting mount added routes")
+  }
+  mustPanic(t, "same prefix twice", func() {
+    uriHandler.Mount("/b", sub)
+    uriHandler.Mount("/b", sub)
+  })
+
+  // a route of the sub handler that conflicts with a mounted one isn't added
+  uriHandler.HandleInspectRoute("/b/new", noop)
+  mustPanic(t, "conflicting route", func() { sub.HandleInspectRoute("/new", noop) })
+  if sub.RouteInspectHandlers["/new"] != nil || len(sub.inspectRoutes) != 2 {
+    t.Errorf("conflicting route added to the sub handler")
+  }
+
+  mustPanic(t, "mount on itself", func() { uriHandler.Mount("/c", uriHandler) })
+  mustPanic(t, "mount cycle", func() { sub.Mount("/c", uriHandler) })
+}
@@ -0,0 +1,323 @@
+package urihandler
+
+import (
+  "fmt"
+  "math/big"
+  "net/url"
+  "sort"
+  "strconv"
+  "strings"
+
+  hdl "github.com/prototyp3-dev/go-rollups/handler"
+  "github.com/umbracle/ethgo"
+)
+
+// segment kinds, ordered by precedence: on overlapping routes the first
+// segment that differs decides which route is tried first
+const (
+  staticSegment = iota
+  typedParamSegment
+  paramSegment
+  wildcardSegment
+)
+
+// paramTypes converts the typed params (:name<type>), a param that doesn't
+// convert doesn't match the route
+var paramTypes = map[string]func(string) (interface{},error){
+  "int": func(s string) (interface{},error) {
+    return strconv.Atoi(s)
+  },
+  "address": func(s string) (interface{},error) {
+    if err := hdl.ValidateAddress(s); err != nil {
+      return nil, err
+    }
+    return ethgo.HexToAddress(s), nil
+  },
+  "uint256": func(s string) (interface{},error) {
+    value, ok := new(big.Int), false
+    if strings.HasPrefix(s, "0x") {
+      _, ok = value.SetString(s[2:], 16)
+    } else {
+      _, ok = value.SetString(s, 10)
+    }
+    if !ok || value.Sign() < 0 || value.BitLen() > 256 {
+      return nil, fmt.Errorf("invalid uint256 %s", s)
+    }
+    return value, nil
+  },
+}
+
+// uriPart is a literal text or a param of a segment
+type uriPart struct {
+  literal string
+  name string
+  kind string
+}
+
+type uriSegment struct {
+  kind int
+  literal string
+  parts []uriPart
+  // name of the catch-all param, empty for the trailing slash
+  name string
+}
+
+type uriRoute struct {
+  pattern string
+  segments []uriSegment
+  shape string
+  // groups the route was added through, the outermost first
+  groups []*UriGroup
+}
+
+func newUriRoute(pattern string) (*uriRoute,error) {
+  if strings.IndexByte(pattern, '?') >= 0 {
+    return nil, fmt.Errorf("route %s has a query string", pattern)
+  }
+  r := uriRoute{pattern: pattern}
+  parts := strings.Split(pattern, "/")
+  // a trailing slash matches any path with the pattern as prefix
+  wildcard := len(pattern) > 1 && pattern[len(pattern)-1] == '/'
+  if wildcard {
+    parts = parts[:len(parts)-1]
+  }
+  shape := make([]string, len(parts))
+  for i, part := range parts {
+    switch {
+    case strings.HasPrefix(part, "*"):
+      if i != len(parts)-1 || wildcard || !isName(part[1:]) {
+        return nil, fmt.Errorf("route %s has an invalid catch-all", pattern)
+      }
+      r.segments = append(r.segments, uriSegment{kind: wildcardSegment, name: part[1:]})
+      shape[i] = "*"
+    case strings.IndexByte(part, ':') < 0:
+      r.segments = append(r.segments, uriSegment{kind: staticSegment, literal: part})
+      shape[i] = part
+    default:
+      segment, err := parseSegment(part)
+      if err != nil {
+        return nil, fmt.Errorf("route %s: %s", pattern, err)
+      }
+      r.segments = append(r.segments, segment)
+      shape[i] = segment.shape()
+    }
+  }
+  if wildcard {
+    r.segments = append(r.segments, uriSegment{kind: wildcardSegment})
+    shape = append(shape, "*")
+  }
+  r.shape = strings.Join(shape, "/")
+  return &r, nil
+}
+
+// parseSegment parses a segment with params, e.g. :name, :id<int> or :name.:ext
+func parseSegment(part string) (uriSegment,error) {
+  segment := uriSegment{kind: paramSegment}
+  for j := 0; j < len(part); {
+    if part[j] != ':' {
+      end := strings.IndexByte(part[j:], ':')
+      if end < 0 {
+        end = len(part) - j
+      }
+      segment.parts = append(segment.parts, uriPart{literal: part[j:j+end]})
+      j += end
+      continue
+    }
+    if n := len(segment.parts); n > 0 && segment.parts[n-1].name != "" {
+      return segment, fmt.Errorf("params must be separated by text")
+    }
+    end := j+1
+    for end < len(part) && isAlnum(part[end]) {
+      end++
+    }
+    param := uriPart{name: part[j+1:end]}
+    if param.name == "" {
+      return segment, fmt.Errorf("empty param name")
+    }
+    j = end
+    if j < len(part) && part[j] == '<' {
+      end = strings.IndexByte(part[j:], '>')
+      if end < 0 {
+        return segment, fmt.Errorf("unclosed param type")
+      }
+      param.kind = part[j+1:j+end]
+      if paramTypes[param.kind] == nil {
+        return segment, fmt.Errorf("unknown param type %s", param.kind)
+      }
+      segment.kind = typedParamSegment
+      j += end+1
+    }
+    segment.parts = append(segment.parts, param)
+  }
+  return segment, nil
+}
+
+// shape replaces the param names of the segment, so routes that only differ
+// on the names of the params have the same shape
+func (s uriSegment) shape() string {
+  var b strings.Builder
+  for _, part := range s.parts {
+    if part.name == "" {
+      b.WriteString(part.literal)
+      continue
+    }
+    b.WriteByte(':')
+    if part.kind != "" {
+      b.WriteString("<" + part.kind + ">")
+    }
+  }
+  return b.String()
+}
+
+// match matches the (percent-encoded) path with the route, returning the
+// converted params and the query string values
+func (r *uriRoute) match(path string) (map[string]interface{},bool) {
+  path, query, _ := strings.Cut(path, "?")
+  pathSegments := strings.Split(path, "/")
+  params := make(map[string]interface{})
+  for i, segment := range r.segments {
+    if i >= len(pathSegments) {
+      return nil, false
+    }
+    if segment.kind == wildcardSegment {
+      rest, err := url.PathUnescape(strings.Join(pathSegments[i:], "/"))
+      if err != nil {
+        return nil, false
+      }
+      if segment.name != "" {
+        params[segment.name] = rest
+      }
+      pathSegments = pathSegments[:i+1]
+      break
+    }
+    value, err := url.PathUnescape(pathSegments[i])
+    if err != nil {
+      return nil, false
+    }
+    if segment.kind == staticSegment {
+      if value != segment.literal {
+        return nil, false
+      }
+      continue
+    }
+    if !segment.match(value, params) {
+      return nil, false
+    }
+  }
+  if len(pathSegments) != len(r.segments) {
+    return nil, false
+  }
+  if query != "" {
+    values, err := url.ParseQuery(query)
+    if err != nil {
+      return nil, false
+    }
+    for key, value := range values {
+      if _, ok := params[key]; ok {
+        continue
+      }
+      if len(value) == 1 {
+        params[key] = value[0]
+      } else {
+        params[key] = value
+      }
+    }
+  }
+  return params, true
+}
+
+// match matches a decoded path segment, a param takes the text up to the
+// first char of the next literal
+func (s uriSegment) match(value string, params map[string]interface{}) bool {
+  i := 0
+  for k, part := range s.parts {
+    if part.name == "" {
+      if !strings.HasPrefix(value[i:], part.literal) {
+        return false
+      }
+      i += len(part.literal)
+      continue
+    }
+    end := len(value)
+    if k+1 < len(s.parts) {
+      if next := strings.IndexByte(value[i:], s.parts[k+1].literal[0]); next >= 0 {
+        end = i+next
+      }
+    }
+    if end == i {
+      return false
+    }
+    if part.kind == "" {
+      params[part.name] = value[i:end]
+    } else {
+      converted, err := paramTypes[part.kind](value[i:end])
+      if err != nil {
+        return false
+      }
+      params[part.name] = converted
+    }
+    i = end
+  }
+  return i == len(value)
+}
+
+// before reports if r is more specific than other
+func (r *uriRoute) before(other *uriRoute) bool {
+  for i := 0; i < len(r.segments) && i < len(other.segments); i++ {
+    if r.segments[i].kind != other.segments[i].kind {
+      return r.segments[i].kind < other.segments[i].kind
+    }
+  }
+  if len(r.segments) != len(other.segments) {
+    return len(r.segments) > len(other.segments)
+  }
+  return r.pattern < other.pattern
+}
+
+// insertRoute adds pattern to routes keeping them sorted by specificity. It
+// fails if an existing route has the same shape, as they would match the
+// same paths
+func insertRoute(routes []*uriRoute, pattern string, groups []*UriGroup) ([]*uriRoute,error) {
+  route, err := newUriRoute(pattern)
+  if err != nil {
+    return routes, err
+  }
+  route.groups = groups
+  for _, r := range routes {
+    if r.shape == route.shape {
+      return routes, fmt.Errorf("route %s conflicts with %s", pattern, r.pattern)
+    }
+  }
+  i := sort.Search(len(routes), func(i int) bool { return route.before(routes[i]) })
+  routes = append(routes, nil)
+  copy(routes[i+1:], routes[i:])
+  routes[i] = route
+  return routes, nil
+}
+
+// wrap chains the middlewares of the route groups around fn
+func (r *uriRoute) wrap(fn hdl.RouteFunc) hdl.RouteFunc {
+  for i := len(r.groups) - 1; i >= 0; i-- {
+    middlewares := r.groups[i].middlewares
+    for j := len(middlewares) - 1; j >= 0; j-- {
+      fn = middlewares[j](fn)
+    }
+  }
+  return fn
+}
+
+func isName(name string) bool {
+  if name == "" {
+    return false
+  }
+  for i := 0; i < len(name); i++ {
+    if !isAlnum(name[i]) {
+      return false
+    }
+  }
+  return true
+}
+
+func isAlnum(ch byte) bool {
+  return ch == '_' || ('a' <= ch && ch <= 'z') || ('A' <= ch && ch <= 'Z') || ('0' <= ch && ch <= '9')
+}
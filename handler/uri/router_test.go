@@ -0,0 +1,222 @@
+package urihandler
+
+import (
+  "math/big"
+  "reflect"
+  "strings"
+  "testing"
+
+  "github.com/prototyp3-dev/go-rollups/handler/handlertest"
+  "github.com/prototyp3-dev/go-rollups/rollups"
+
+  "github.com/umbracle/ethgo"
+)
+
+func TestRouteMatch(t *testing.T) {
+  address := "0x2c7536e3605d9c16a7a3d7b1898e529396a65c23"
+  tests := []struct {
+    pattern string
+    path string
+    // nil if the path doesn't match
+    params map[string]interface{}
+  }{
+    // static segments
+    {"/balance/total", "/balance/total", map[string]interface{}{}},
+    {"/balance/total", "/balance/other", nil},
+    {"/balance/total", "/balance/total/x", nil},
+    {"/balance/total", "/balance", nil},
+    // params take a whole segment
+    {"/balance/:address", "/balance/0xabc", map[string]interface{}{"address": "0xabc"}},
+    {"/balance/:address", "/balance/", nil},
+    {"/balance/:address", "/balance/0xabc/x", nil},
+    {"/a/:x/b/:y", "/a/1/b/2", map[string]interface{}{"x": "1", "y": "2"}},
+    // params split at the first char of the next literal
+    {"/files/:name.:ext", "/files/archive.tar.gz", map[string]interface{}{"name": "archive", "ext": "tar.gz"}},
+    {"/files/:name.:ext", "/files/noext", nil},
+    {"/files/:name.:ext", "/files/.gz", nil},
+    {"/files/:name.json", "/files/a.json", map[string]interface{}{"name": "a"}},
+    {"/files/:name.json", "/files/a.txt", nil},
+    {"/files/v:version", "/files/v2", map[string]interface{}{"version": "2"}},
+    // typed params convert or don't match
+    {"/orders/:id<int>", "/orders/12", map[string]interface{}{"id": 12}},
+    {"/orders/:id<int>", "/orders/x", nil},
+    {"/owner/:addr<address>", "/owner/" + address, map[string]interface{}{"addr": ethgo.HexToAddress(address)}},
+    {"/owner/:addr<address>", "/owner/0x2C7536E3605D9C16a7a3D7b1898e529396a65c23", nil},
+    {"/owner/:addr<address>", "/owner/0x1234", nil},
+    {"/amount/:v<uint256>", "/amount/0x10", map[string]interface{}{"v": big.NewInt(16)}},
+    {"/amount/:v<uint256>", "/amount/1000", map[string]interface{}{"v": big.NewInt(1000)}},
+    {"/amount/:v<uint256>", "/amount/-1", nil},
+    {"/amount/:v<uint256>", "/amount/0x1" + strings.Repeat("0", 64), nil},
+    // path segments are percent-decoded
+    {"/user/:name", "/user/john%20doe", map[string]interface{}{"name": "john doe"}},
+    {"/user/:name", "/user/a%2Fb", map[string]interface{}{"name": "a/b"}},
+    {"/user/:name", "/user/%zz", nil},
+    {"/user/john doe", "/user/john%20doe", map[string]interface{}{}},
+    // catch-all
+    {"/static/*rest", "/static/a/b%20c", map[string]interface{}{"rest": "a/b c"}},
+    {"/static/*rest", "/static/", map[string]interface{}{"rest": ""}},
+    {"/static/*rest", "/static", nil},
+    // a trailing slash matches the paths with the pattern as prefix
+    {"/balance/", "/balance/", map[string]interface{}{}},
+    {"/balance/", "/balance/x/y", map[string]interface{}{}},
+    {"/balance/", "/balance", nil},
+    {"/balance/", "/balancex", nil},
+    {"/balance/", "/balance/?x=1", map[string]interface{}{"x": "1"}},
+    {"/", "/", map[string]interface{}{}},
+    {"/", "/x", nil},
+    {"/", "", nil},
+    // query strings are added to the params, without overriding the path
+    {"/orders/:id<int>", "/orders/12?limit=10", map[string]interface{}{"id": 12, "limit": "10"}},
+    {"/orders/:id<int>", "/orders/12?tag=a&tag=b", map[string]interface{}{"id": 12, "tag": []string{"a", "b"}}},
+    {"/orders/:id<int>", "/orders/12?id=99", map[string]interface{}{"id": 12}},
+    {"/orders/:id<int>", "/orders/12?x=%zz", nil},
+    {"/orders", "/orders?", map[string]interface{}{}},
+  }
+  for _, tt := range tests {
+    route, err := newUriRoute(tt.pattern)
+    if err != nil {
+      t.Fatalf("%s: %s", tt.pattern, err)
+    }
+    params, ok := route.match(tt.path)
+    if tt.params == nil {
+      if ok {
+        t.Errorf("%s matched %s with %v", tt.pattern, tt.path, params)
+      }
+      continue
+    }
+    if !ok {
+      t.Errorf("%s didn't match %s", tt.pattern, tt.path)
+      continue
+    }
+    if !reflect.DeepEqual(params, tt.params) {
+      t.Errorf("%s with %s: params %#v, expected %#v", tt.pattern, tt.path, params, tt.params)
+    }
+  }
+}
+
+func TestInvalidRoute(t *testing.T) {
+  patterns := []string{
+    "/orders?limit=10",
+    "/static/*",
+    "/*rest/x",
+    "/*rest/",
+    "/:a:b",
+    "/:",
+    "/:<int>",
+    "/:a<float>",
+    "/:a<int",
+  }
+  for _, pattern := range patterns {
+    if _, err := newUriRoute(pattern); err == nil {
+      t.Errorf("%s: expected error", pattern)
+    }
+  }
+}
+
+func TestRouteOrder(t *testing.T) {
+  tests := []struct {
+    patterns []string
+    order []string
+  }{
+    {
+      []string{"/balance/", "/", "/balance/:address", "/balance/total"},
+      []string{"/balance/total", "/balance/:address", "/balance/", "/"},
+    },
+    {
+      []string{"/balance/", "/*rest", "/balance/:address", "/balance/:id<int>", "/balance/total"},
+      []string{"/balance/total", "/balance/:id<int>", "/balance/:address", "/balance/", "/*rest"},
+    },
+    {
+      // the first segment that differs decides, then the longest route
+      []string{"/:a/x", "/a/:x", "/a/:x/y", "/a"},
+      []string{"/a/:x/y", "/a/:x", "/a", "/:a/x"},
+    },
+    {
+      []string{"/b/:x", "/a/:x"},
+      []string{"/a/:x", "/b/:x"},
+    },
+  }
+  for _, tt := range tests {
+    var routes []*uriRoute
+    for _, pattern := range tt.patterns {
+      var err error
+      if routes, err = insertRoute(routes, pattern, nil); err != nil {
+        t.Fatalf("%s: %s", pattern, err)
+      }
+    }
+    order := make([]string, len(routes))
+    for i, r := range routes {
+      order[i] = r.pattern
+    }
+    if !reflect.DeepEqual(order, tt.order) {
+      t.Errorf("%v: order %v, expected %v", tt.patterns, order, tt.order)
+    }
+  }
+}
+
+func TestRouteConflict(t *testing.T) {
+  tests := []struct {
+    first string
+    second string
+    conflict bool
+  }{
+    {"/a/:x", "/a/:y", true},
+    {"/a/:x<int>", "/a/:y<int>", true},
+    {"/f/:n.:e", "/f/:a.:b", true},
+    {"/a/", "/a/*rest", true},
+    {"/a/*x", "/a/*y", true},
+    {"/a/:x<int>", "/a/:y", false},
+    {"/a/:x<int>", "/a/:y<address>", false},
+    {"/a/:x", "/a/x", false},
+    {"/a/:x", "/a/", false},
+    {"/f/:n.:e", "/f/:n", false},
+  }
+  for _, tt := range tests {
+    routes, err := insertRoute(nil, tt.first, nil)
+    if err != nil {
+      t.Fatalf("%s: %s", tt.first, err)
+    }
+    _, err = insertRoute(routes, tt.second, nil)
+    if conflict := err != nil; conflict != tt.conflict {
+      t.Errorf("%s and %s: conflict %t, expected %t", tt.first, tt.second, conflict, tt.conflict)
+    }
+  }
+}
+
+// TestDispatch checks the most specific route handles the input, whatever the
+// order the routes were added
+func TestDispatch(t *testing.T) {
+  tests := []struct {
+    path string
+    route string
+  }{
+    {"/balance/total", "/balance/total"},
+    {"/balance/12", "/balance/:id<int>"},
+    {"/balance/0xabc", "/balance/:address"},
+    {"/balance/a/b", "/balance/"},
+    {"/other", ""},
+  }
+  uriHandler := NewUriHandler()
+  var routed string
+  for _, pattern := range []string{"/balance/", "/balance/:address", "/balance/:id<int>", "/balance/total"} {
+    pattern := pattern
+    uriHandler.HandleInspectRoute(pattern, func(params map[string]interface{}) error {
+      routed = pattern
+      return nil
+    })
+  }
+  uriHandler.Handler.HandleDefault(func(payloadHex string) error {
+    routed = ""
+    return nil
+  })
+  driver := handlertest.NewDriver(uriHandler.Handler)
+  for _, tt := range tests {
+    routed = "none"
+    if result := driver.Inspect(rollups.Str2Hex(tt.path)); !result.Accepted() {
+      t.Errorf("%s: rejected: %s", tt.path, result.Err)
+    }
+    if routed != tt.route {
+      t.Errorf("%s: routed to %q, expected %q", tt.path, routed, tt.route)
+    }
+  }
+}
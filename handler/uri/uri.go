@@ -1,7 +1,8 @@
 package urihandler
 
 import (
-  "regexp"
+  "context"
+  "fmt"
   hdl "github.com/prototyp3-dev/go-rollups/handler"
   "github.com/prototyp3-dev/go-rollups/rollups"
 )
@@ -26,6 +27,9 @@ type UriHandler struct {
   Handler *hdl.Handler
   RouteAdvanceHandlers map[string]*AdvanceMapHandler
   RouteInspectHandlers map[string]*InspectMapHandler
+  advanceRoutes []*uriRoute
+  inspectRoutes []*uriRoute
+  mounts []*UriGroup
 }
 
 func NewUriHandler() *UriHandler {
@@ -40,48 +44,99 @@ func AddUriHandler(handler *hdl.Handler) *UriHandler {
 }
 
 func (h *UriHandler) HandleAdvanceRoute(route string, fnHandle AdvanceMapHandlerFunc) {
+  h.addAdvanceRoute(route, fnHandle, nil)
+}
+
+func (h *UriHandler) addAdvanceRoute(route string, fnHandle AdvanceMapHandlerFunc, groups []*UriGroup) {
 	if fnHandle == nil {
 		panic("uri handler: nil handler")
 	}
-	if route == "" {
-		panic("uri handler: invalid route")
-	}
+  h.checkAdvanceRoutes([]string{route})
   if h.RouteAdvanceHandlers == nil {
     h.RouteAdvanceHandlers = make(map[string]*AdvanceMapHandler)
   }
-	if h.RouteAdvanceHandlers[route] != nil {
-		panic("uri handler: route already added")
-	}
+  routes, err := insertRoute(h.advanceRoutes, route, groups)
+  if err != nil {
+    panic(fmt.Sprintf("uri handler: %s", err))
+  }
+  h.advanceRoutes = routes
   fnHandler := AdvanceMapHandler{fnHandle}
   h.RouteAdvanceHandlers[route] = &fnHandler
-  if h.Handler.LogLevel >= hdl.Debug {hdl.DebugLogger.Println("Created URI Advance route for",route) }
+  h.Handler.Logger().Debug("Created URI Advance route", "route", route)
+  for _, mount := range h.mounts {
+    mount.uri.addAdvanceRoute(mount.join(route), fnHandle, append(mount.chain(), groups...))
+  }
 }
 
 
 func (h *UriHandler) HandleInspectRoute(route string, fnHandle InspectMapHandlerFunc) {
+  h.addInspectRoute(route, fnHandle, nil)
+}
+
+func (h *UriHandler) addInspectRoute(route string, fnHandle InspectMapHandlerFunc, groups []*UriGroup) {
 	if fnHandle == nil {
 		panic("uri handler: nil handler")
 	}
-	if route == "" {
-		panic("uri handler: invalid route")
-	}
+  h.checkInspectRoutes([]string{route})
   if h.RouteInspectHandlers == nil {
     h.RouteInspectHandlers = make(map[string]*InspectMapHandler)
   }
-	if h.RouteInspectHandlers[route] != nil {
-		panic("uri handler: route already added")
-	}
+  routes, err := insertRoute(h.inspectRoutes, route, groups)
+  if err != nil {
+    panic(fmt.Sprintf("uri handler: %s", err))
+  }
+  h.inspectRoutes = routes
   fnHandler := InspectMapHandler{fnHandle}
   h.RouteInspectHandlers[route] = &fnHandler
-  if h.Handler.LogLevel >= hdl.Debug {hdl.DebugLogger.Println("Created URI Inspect route for",route) }
+  h.Handler.Logger().Debug("Created URI Inspect route", "route", route)
+  for _, mount := range h.mounts {
+    mount.uri.addInspectRoute(mount.join(route), fnHandle, append(mount.chain(), groups...))
+  }
+}
+
+// checkAdvanceRoutes panics if a route is invalid, already added or conflicts
+// with the routes of h or of the handlers h is mounted on, before any of them
+// is added
+func (h *UriHandler) checkAdvanceRoutes(routes []string) {
+  checkRoutes(h.advanceRoutes, routes, func(route string) bool { return h.RouteAdvanceHandlers[route] != nil })
+  for _, mount := range h.mounts {
+    mount.uri.checkAdvanceRoutes(mount.joinAll(routes))
+  }
+}
+
+func (h *UriHandler) checkInspectRoutes(routes []string) {
+  checkRoutes(h.inspectRoutes, routes, func(route string) bool { return h.RouteInspectHandlers[route] != nil })
+  for _, mount := range h.mounts {
+    mount.uri.checkInspectRoutes(mount.joinAll(routes))
+  }
+}
+
+func checkRoutes(routes []*uriRoute, added []string, exists func(string) bool) {
+  // insertRoute may reuse the array of routes
+  routes = append([]*uriRoute{}, routes...)
+  for _, route := range added {
+    if route == "" {
+      panic("uri handler: invalid route")
+    }
+    if exists(route) {
+      panic("uri handler: route already added")
+    }
+    var err error
+    if routes, err = insertRoute(routes, route, nil); err != nil {
+      panic(fmt.Sprintf("uri handler: %s", err))
+    }
+  }
 }
 
 func (h *UriHandler) uriAdvanceHandler(metadata *rollups.Metadata, payloadHex string) (error,bool) {
   if payloadStr, err := rollups.Hex2Str(payloadHex); err == nil {
-    for route, handler := range h.RouteAdvanceHandlers {
-      if result, ok := tryUri(route,payloadStr); ok {
-        if h.Handler.LogLevel >= hdl.Trace {hdl.TraceLogger.Println("Received URI route",route,"Advance Request:",result) }
-        return handler.Handler.Handle(metadata,result),true
+    for _, r := range h.advanceRoutes {
+      route, handler := r.pattern, h.RouteAdvanceHandlers[r.pattern]
+      if result, ok := r.match(payloadStr); ok {
+        h.Handler.Logger().Log(context.Background(), hdl.LevelTrace, "Received URI Advance request", "route", route, "params", result)
+        return h.Handler.Dispatch(route, result, r.wrap(func(req *hdl.Request) error {
+          return handler.Handler.Handle(req.Metadata,req.Params)
+        })),true
       }
     }
   }
@@ -90,73 +145,77 @@ func (h *UriHandler) uriAdvanceHandler(metadata *rollups.Metadata, payloadHex st
 
 func (h *UriHandler) uriInspectHandler(payloadHex string) (error,bool) {
   if payloadStr, err := rollups.Hex2Str(payloadHex); err == nil {
-    for route, handler := range h.RouteInspectHandlers {
-      if result, ok := tryUri(route,payloadStr); ok {
-        if h.Handler.LogLevel >= hdl.Trace {hdl.TraceLogger.Println("Received URI route",route,"Inspect Request:",result) }
-        return handler.Handler.Handle(result),true
+    for _, r := range h.inspectRoutes {
+      route, handler := r.pattern, h.RouteInspectHandlers[r.pattern]
+      if result, ok := r.match(payloadStr); ok {
+        h.Handler.Logger().Log(context.Background(), hdl.LevelTrace, "Received URI Inspect request", "route", route, "params", result)
+        return h.Handler.Dispatch(route, result, r.wrap(func(req *hdl.Request) error {
+          return handler.Handler.Handle(req.Params)
+        })),true
       }
     }
   }
   return nil,false
 }
 
-func tryUri(patern string,path string) (map[string]interface{}, bool) {
-	params := make(map[string]interface{})
-	var i, j int
-	for i < len(path) {
-		switch {
-		case j >= len(patern):
-			if len(patern) > 0 && patern != "/" && patern[len(patern)-1] == '/' {
-				return params, true
-			}
-			return nil, false
-		case patern[j] == ':':
-			var name, val string
-			var nextc byte
-			name, nextc, j = match(patern, isAlnum, j+1)
-			val, _, i = match(path, matchPart(nextc), i)
-			params[name] = val
-		case path[i] == patern[j]:
-			i++
-			j++
-		default:
-			return nil, false
-		}
-	}
-	if j != len(patern) {
-		return nil, false
+// ContextAdvance adapts fnHandle to the AdvanceMapHandlerFunc signature, the
+// decoded params are in the request Params
+func (h *UriHandler) ContextAdvance(fnHandle hdl.ContextHandlerFunc) AdvanceMapHandlerFunc {
+	if fnHandle == nil {
+		panic("uri handler: nil handler")
 	}
-	return params, true
+  return func(metadata *rollups.Metadata, payloadMap map[string]interface{}) error {
+    req := h.request()
+    if req == nil {
+      return fmt.Errorf("ContextAdvance: no input being processed")
+    }
+    return fnHandle(req.Context(), req)
+  }
 }
 
-
-func matchPart(b byte) func(byte) bool {
-	return func(c byte) bool {
-		return c != b && c != '/'
+func (h *UriHandler) ContextInspect(fnHandle hdl.ContextHandlerFunc) InspectMapHandlerFunc {
+	if fnHandle == nil {
+		panic("uri handler: nil handler")
 	}
+  return func(payloadMap map[string]interface{}) error {
+    req := h.request()
+    if req == nil {
+      return fmt.Errorf("ContextInspect: no input being processed")
+    }
+    return fnHandle(req.Context(), req)
+  }
 }
 
-func match(s string, f func(byte) bool, i int) (matched string, next byte, j int) {
-	j = i
-	for j < len(s) && f(s[j]) {
-		j++
-	}
-	if j < len(s) {
-		next = s[j]
-	}
-	return s[i:j], next, j
+// request returns the input being processed by the handler of h or, when h is
+// mounted, by the handlers it's mounted on
+func (h *UriHandler) request() *hdl.Request {
+  if req := h.Handler.Request(); req != nil {
+    return req
+  }
+  for _, mount := range h.mounts {
+    if req := mount.uri.request(); req != nil {
+      return req
+    }
+  }
+  return nil
+}
+
+func (h *UriHandler) HandleAdvanceRouteContext(route string, fnHandle hdl.ContextHandlerFunc) {
+  h.HandleAdvanceRoute(route, h.ContextAdvance(fnHandle))
 }
 
-func isAlnum(ch byte) bool {
-  match, _ := regexp.MatchString("[_a-zA-Z0-9]",string(ch))
-	return match
+func (h *UriHandler) HandleInspectRouteContext(route string, fnHandle hdl.ContextHandlerFunc) {
+  h.HandleInspectRoute(route, h.ContextInspect(fnHandle))
 }
 
 func (h *UriHandler) SetDebug() {h.Handler.SetDebug()}
 func (h *UriHandler) SetLogLevel(logLevel hdl.LogLevel) {h.Handler.SetLogLevel(logLevel)}
+func (h *UriHandler) Use(middlewares ...hdl.Middleware) {h.Handler.Use(middlewares...)}
 func (h *UriHandler) HandleDefault(fnHandle hdl.InspectHandlerFunc) {h.Handler.HandleDefault(fnHandle)}
 func (h *UriHandler) HandleInspect(fnHandle hdl.InspectHandlerFunc) {h.Handler.HandleInspect(fnHandle)}
 func (h *UriHandler) HandleAdvance(fnHandle hdl.AdvanceHandlerFunc) {h.Handler.HandleAdvance(fnHandle)}
+func (h *UriHandler) HandleAdvanceContext(fnHandle hdl.ContextHandlerFunc) {h.Handler.HandleAdvanceContext(fnHandle)}
+func (h *UriHandler) HandleInspectContext(fnHandle hdl.ContextHandlerFunc) {h.Handler.HandleInspectContext(fnHandle)}
 func (h *UriHandler) HandleRollupsFixedAddresses(fnHandle hdl.AdvanceHandlerFunc) {h.Handler.HandleRollupsFixedAddresses(fnHandle)}
 func (h *UriHandler) HandleFixedAddress(address string, fnHandle hdl.AdvanceHandlerFunc) {h.Handler.HandleFixedAddress(address,fnHandle)}
 func (h *UriHandler) SendNotice(payloadHex string) (uint64,error) {return h.Handler.SendNotice(payloadHex)}
@@ -2,6 +2,7 @@ package rollups
 
 import (
 	"encoding/hex"
+	"fmt"
   "math/big"
 )
 
@@ -23,6 +24,8 @@ type Erc721Deposit struct {
   TokenAddress string
   TokenId *big.Int
   Data []byte
+  BaseLayerData []byte
+  ExecLayerData []byte
 }
 
 type Erc1155SingleDeposit struct {
@@ -48,6 +51,9 @@ func DecodeEtherDeposit(payloadHex string) (EtherDeposit,error) {
 	if err != nil {
     return EtherDeposit{}, err
 	}
+  if len(bin) < 52 {
+    return EtherDeposit{}, fmt.Errorf("DecodeEtherDeposit: payload too short")
+  }
   
   amount := new(big.Int)
   amount.SetBytes(bin[20:52])
@@ -60,6 +66,9 @@ func DecodeErc20Deposit(payloadHex string) (Erc20Deposit,error) {
 	if err != nil {
     return Erc20Deposit{}, err
 	}
+  if len(bin) < 73 {
+    return Erc20Deposit{}, fmt.Errorf("DecodeErc20Deposit: payload too short")
+  }
 
   amount := new(big.Int)
   amount.SetBytes(bin[41:73])
@@ -67,11 +76,30 @@ func DecodeErc20Deposit(payloadHex string) (Erc20Deposit,error) {
   return Erc20Deposit{Depositor:Bin2Hex(bin[21:41]), TokenAddress:Bin2Hex(bin[1:21]), Amount:amount, Data:bin[73:]},nil
 }
 
+// DecodeErc20DepositV2 decodes the v2 ERC20Portal payload, which has no transfer result flag
+func DecodeErc20DepositV2(payloadHex string) (Erc20Deposit,error) {
+  bin, err := Hex2Bin(payloadHex)
+	if err != nil {
+    return Erc20Deposit{}, err
+	}
+  if len(bin) < 72 {
+    return Erc20Deposit{}, fmt.Errorf("DecodeErc20DepositV2: payload too short")
+  }
+
+  amount := new(big.Int)
+  amount.SetBytes(bin[40:72])
+
+  return Erc20Deposit{Depositor:Bin2Hex(bin[20:40]), TokenAddress:Bin2Hex(bin[:20]), Amount:amount, Data:bin[72:]},nil
+}
+
 func DecodeErc721Deposit(payloadHex string) (Erc721Deposit,error) {
   bin, err := Hex2Bin(payloadHex)
 	if err != nil {
     return Erc721Deposit{}, err
 	}
+  if len(bin) < 72 {
+    return Erc721Deposit{}, fmt.Errorf("DecodeErc721Deposit: payload too short")
+  }
 
   tokenId := new(big.Int)
   tokenId.SetBytes(bin[40:72])
@@ -79,11 +107,35 @@ func DecodeErc721Deposit(payloadHex string) (Erc721Deposit,error) {
   return Erc721Deposit{Depositor:Bin2Hex(bin[20:40]), TokenAddress:Bin2Hex(bin[:20]), TokenId:tokenId, Data:bin[72:]}, nil
 }
 
+// DecodeErc721DepositV2 decodes the v2 ERC721Portal payload, splitting the
+// base and execution layer data
+func DecodeErc721DepositV2(payloadHex string) (Erc721Deposit,error) {
+  deposit, err := DecodeErc721Deposit(payloadHex)
+  if err != nil {
+    return deposit, err
+  }
+  allData := deposit.Data
+
+  deposit.BaseLayerData, err = abiBytes(allData, 0)
+  if err != nil {
+    return deposit, fmt.Errorf("DecodeErc721DepositV2: base layer data: %s", err)
+  }
+  deposit.ExecLayerData, err = abiBytes(allData, 32)
+  if err != nil {
+    return deposit, fmt.Errorf("DecodeErc721DepositV2: exec layer data: %s", err)
+  }
+
+  return deposit, nil
+}
+
 func DecodeErc1155SingleDeposit(payloadHex string) (Erc1155SingleDeposit,error) {
   bin, err := Hex2Bin(payloadHex)
 	if err != nil {
     return Erc1155SingleDeposit{}, err
 	}
+  if len(bin) < 104 {
+    return Erc1155SingleDeposit{}, fmt.Errorf("DecodeErc1155SingleDeposit: payload too short")
+  }
 
   tokenId := new(big.Int)
   tokenId.SetBytes(bin[40:72])
@@ -93,13 +145,14 @@ func DecodeErc1155SingleDeposit(payloadHex string) (Erc1155SingleDeposit,error)
 
   allData := bin[104:]
 
-  blDataPosition := int(new(big.Int).SetBytes(allData[0:32]).Int64())
-  blDataSize := int(new(big.Int).SetBytes(allData[blDataPosition:blDataPosition+32]).Int64())
-  blData := allData[blDataPosition+32:blDataPosition+32+blDataSize]
-
-  elDataPosition := int(new(big.Int).SetBytes(allData[32:64]).Int64())
-  elDataSize := int(new(big.Int).SetBytes(allData[elDataPosition:elDataPosition+32]).Int64())
-  elData := allData[elDataPosition+32:elDataPosition+32+elDataSize]
+  blData, err := abiBytes(allData, 0)
+  if err != nil {
+    return Erc1155SingleDeposit{}, fmt.Errorf("DecodeErc1155SingleDeposit: base layer data: %s", err)
+  }
+  elData, err := abiBytes(allData, 32)
+  if err != nil {
+    return Erc1155SingleDeposit{}, fmt.Errorf("DecodeErc1155SingleDeposit: exec layer data: %s", err)
+  }
 
   return Erc1155SingleDeposit{Depositor:Bin2Hex(bin[20:40]), TokenAddress:Bin2Hex(bin[:20]), TokenId:tokenId, Amount:amount, BaseLayerData:blData, ExecLayerData:elData}, nil
 }
@@ -109,35 +162,82 @@ func DecodeErc1155BatchDeposit(payloadHex string) (Erc1155BatchDeposit,error) {
 	if err != nil {
     return Erc1155BatchDeposit{}, err
 	}
+  if len(bin) < 40 {
+    return Erc1155BatchDeposit{}, fmt.Errorf("DecodeErc1155BatchDeposit: payload too short")
+  }
 
   token := Bin2Hex(bin[:20])
   depositor := Bin2Hex(bin[20:40])
 
   allData := bin[40:]
 
-  idsPosition := int(new(big.Int).SetBytes(allData[0:32]).Int64())
-  idsSize := int(new(big.Int).SetBytes(allData[idsPosition:idsPosition+32]).Int64())
-  idList := make([]*big.Int,0)
-  for i := 0; i < idsSize ; i++ {
-    idList = append(idList,new(big.Int).SetBytes( allData[idsPosition+32*(i+1):idsPosition+32*(i+2)] ))
+  idList, err := abiUintArray(allData, 0)
+  if err != nil {
+    return Erc1155BatchDeposit{}, fmt.Errorf("DecodeErc1155BatchDeposit: token ids: %s", err)
   }
-
-  amountsPosition := int(new(big.Int).SetBytes(allData[32:64]).Int64())
-  amountsSize := int(new(big.Int).SetBytes(allData[amountsPosition:amountsPosition+32]).Int64())
-  amountList := make([]*big.Int,0)
-  for i := 0; i < amountsSize ; i++ {
-    amountList = append(amountList,new(big.Int).SetBytes( allData[amountsPosition+32*(i+1):amountsPosition+32*(i+2)] ))
+  amountList, err := abiUintArray(allData, 32)
+  if err != nil {
+    return Erc1155BatchDeposit{}, fmt.Errorf("DecodeErc1155BatchDeposit: amounts: %s", err)
+  }
+  blData, err := abiBytes(allData, 64)
+  if err != nil {
+    return Erc1155BatchDeposit{}, fmt.Errorf("DecodeErc1155BatchDeposit: base layer data: %s", err)
+  }
+  elData, err := abiBytes(allData, 96)
+  if err != nil {
+    return Erc1155BatchDeposit{}, fmt.Errorf("DecodeErc1155BatchDeposit: exec layer data: %s", err)
   }
 
-  blDataPosition := int(new(big.Int).SetBytes(allData[64:96]).Int64())
-  blDataSize := int(new(big.Int).SetBytes(allData[blDataPosition:blDataPosition+32]).Int64())
-  blData := allData[blDataPosition+32:blDataPosition+32+blDataSize]
+  return Erc1155BatchDeposit{Depositor:depositor, TokenAddress:token, TokenIds:idList, Amounts:amountList, BaseLayerData:blData, ExecLayerData:elData}, nil
+}
 
-  elDataPosition := int(new(big.Int).SetBytes(allData[96:128]).Int64())
-  elDataSize := int(new(big.Int).SetBytes(allData[elDataPosition:elDataPosition+32]).Int64())
-  elData := allData[elDataPosition+32:elDataPosition+32+elDataSize]
+// abiWord reads the 32 bytes word at position as an int, it fails if the
+// word is out of data or the value doesn't fit in data
+func abiWord(data []byte, position int) (int, error) {
+  if position < 0 || position+32 > len(data) {
+    return 0, fmt.Errorf("position %d out of range", position)
+  }
+  value := new(big.Int).SetBytes(data[position:position+32])
+  if !value.IsInt64() || value.Int64() > int64(len(data)) {
+    return 0, fmt.Errorf("value %s at position %d out of range", value, position)
+  }
+  return int(value.Int64()), nil
+}
 
-  return Erc1155BatchDeposit{Depositor:depositor, TokenAddress:token, TokenIds:idList, Amounts:amountList, BaseLayerData:blData, ExecLayerData:elData}, nil
+// abiBytes reads the abi encoded dynamic bytes whose offset is at slot
+func abiBytes(data []byte, slot int) ([]byte, error) {
+  position, err := abiWord(data, slot)
+  if err != nil {
+    return nil, err
+  }
+  size, err := abiWord(data, position)
+  if err != nil {
+    return nil, err
+  }
+  if position+32+size > len(data) {
+    return nil, fmt.Errorf("bytes of size %d out of range", size)
+  }
+  return data[position+32:position+32+size], nil
+}
+
+// abiUintArray reads the abi encoded uint256[] whose offset is at slot
+func abiUintArray(data []byte, slot int) ([]*big.Int, error) {
+  position, err := abiWord(data, slot)
+  if err != nil {
+    return nil, err
+  }
+  size, err := abiWord(data, position)
+  if err != nil {
+    return nil, err
+  }
+  if position+32*(size+1) > len(data) {
+    return nil, fmt.Errorf("array of size %d out of range", size)
+  }
+  list := make([]*big.Int,0)
+  for i := 0; i < size ; i++ {
+    list = append(list,new(big.Int).SetBytes( data[position+32*(i+1):position+32*(i+2)] ))
+  }
+  return list, nil
 }
 
 func EtherWithdralVoucher(Sender string, Receiver string, Amount *big.Int) Voucher {
@@ -145,6 +245,12 @@ func EtherWithdralVoucher(Sender string, Receiver string, Amount *big.Int) Vouch
   return Voucher{Destination: Sender, Payload: payload}
 }
 
+// EtherTransferVoucher sends ether from the application contract straight to
+// the receiver (v2 only)
+func EtherTransferVoucher(Receiver string, Amount *big.Int) Voucher {
+  return Voucher{Destination: Receiver, Value: Uint256Hex(Amount), Payload: "0x"}
+}
+
 func Erc20TransferVoucher(Receiver string, TokenAddress string, Amount *big.Int) Voucher {
   payload := "0xa9059cbb" + hex.EncodeToString(append(Address2Bin(Receiver),PadBytes(Amount.Bytes(),32)...))
   return Voucher{Destination: TokenAddress, Payload: payload}
@@ -0,0 +1,50 @@
+package rollups
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+var (
+  // ErrOutputRejected is returned when the rollup server refuses a request,
+  // e.g. a notice or voucher sent while processing an inspect
+  ErrOutputRejected = errors.New("output rejected")
+  ErrPayloadTooLarge = errors.New("payload too large")
+  // ErrServerUnavailable is returned when the rollup server can not be
+  // reached or fails to process a request
+  ErrServerUnavailable = errors.New("rollup server unavailable")
+)
+
+// StatusError is returned when the rollup server answers with an unexpected
+// http status, it wraps one of the Err* errors above.
+type StatusError struct {
+  Endpoint string
+  StatusCode int
+  Body string
+  Err error
+}
+
+func (e *StatusError) Error() string {
+  return fmt.Sprintf("%s: %s (status %d): %s", e.Endpoint, e.Err, e.StatusCode, e.Body)
+}
+
+func (e *StatusError) Unwrap() error {
+  return e.Err
+}
+
+func newStatusError(endpoint string, statusCode int, body []byte) *StatusError {
+  bodyStr := string(body)
+  var err error
+  switch {
+  case statusCode == http.StatusRequestEntityTooLarge ||
+    (statusCode == http.StatusBadRequest && strings.Contains(strings.ToLower(bodyStr), "too large")):
+    err = ErrPayloadTooLarge
+  case statusCode >= 500:
+    err = ErrServerUnavailable
+  default:
+    err = ErrOutputRejected
+  }
+  return &StatusError{Endpoint: endpoint, StatusCode: statusCode, Body: bodyStr, Err: err}
+}
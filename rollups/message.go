@@ -3,6 +3,7 @@ package rollups
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"net/http"
 )
 
@@ -20,12 +21,40 @@ type AdvanceResponse struct {
 	Payload  string   `json:"payload"`
 }
 
+// Version identifies the Cartesi Rollups (node) major version the dapp runs on
+type Version uint8
+
+const (
+	V1 Version = iota + 1 // node 1.x
+	V2                    // node 2.x
+)
+
 type Metadata struct {
 	MsgSender   string `json:"msg_sender"`
 	EpochIndex  uint64 `json:"epoch_index"`
 	InputIndex  uint64 `json:"input_index"`
 	BlockNumber uint64 `json:"block_number"`
 	Timestamp   uint64 `json:"timestamp"`
+	// v2 only fields
+	ChainId     uint64 `json:"chain_id,omitempty"`
+	AppContract string `json:"app_contract,omitempty"`
+	PrevRandao  string `json:"prev_randao,omitempty"`
+}
+
+// UnmarshalJSON accepts both v1 (timestamp) and v2 (block_timestamp) metadata
+func (m *Metadata) UnmarshalJSON(data []byte) error {
+	type metadataAlias Metadata
+	aux := struct {
+		*metadataAlias
+		BlockTimestamp *uint64 `json:"block_timestamp"`
+	}{metadataAlias: (*metadataAlias)(m)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	if aux.BlockTimestamp != nil {
+		m.Timestamp = *aux.BlockTimestamp
+	}
+	return nil
 }
 
 type Finish struct {
@@ -41,6 +70,14 @@ type Notice struct {
 }
 
 type Voucher struct {
+	Destination string `json:"destination"`
+	// Value is the hex encoded uint256 amount of ether sent with the voucher (v2 only)
+	Value       string `json:"value,omitempty"`
+	Payload     string `json:"payload"`
+}
+
+// DelegateCallVoucher is executed by the application contract with delegatecall (v2 only)
+type DelegateCallVoucher struct {
 	Destination string `json:"destination"`
 	Payload     string `json:"payload"`
 }
@@ -117,3 +154,38 @@ func SendException(exception *Exception) (*http.Response, error) {
 
 	return SendPost("exception", body)
 }
+
+func SendDelegateCallVoucher(voucher *DelegateCallVoucher) (*http.Response, error) {
+	body, err := json.Marshal(voucher)
+	if err != nil {
+		return &http.Response{}, err
+	}
+
+	return SendPost("delegate-call-voucher", body)
+}
+
+// GioRequest asks the rollup server for domain specific data (e.g. preimages)
+// identified by a hex encoded id
+type GioRequest struct {
+	Domain uint16 `json:"domain"`
+	Id     string `json:"id"`
+}
+
+type GioResponse struct {
+	Code uint16 `json:"code"`
+	Data string `json:"data"`
+}
+
+const GioResponseOk uint16 = 0
+
+// GioError is returned when the server answers a gio request with a non ok code
+type GioError struct {
+	Domain uint16
+	Id     string
+	Code   uint16
+	Data   string
+}
+
+func (e *GioError) Error() string {
+	return fmt.Sprintf("gio request for domain %d id %s failed with code %d", e.Domain, e.Id, e.Code)
+}
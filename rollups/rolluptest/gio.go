@@ -0,0 +1,52 @@
+package rolluptest
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/prototyp3-dev/go-rollups/rollups"
+)
+
+// GioFunc answers a gio request with a response code and data
+type GioFunc func(id []byte) (uint16, []byte)
+
+type gioHandlers struct {
+  mu sync.Mutex
+  handlers map[uint16]GioFunc
+}
+
+// HandleGio registers the function that answers the gio requests of domain
+func (g *gioHandlers) HandleGio(domain uint16, fn GioFunc) {
+  g.mu.Lock()
+  defer g.mu.Unlock()
+  if g.handlers == nil {
+    g.handlers = make(map[uint16]GioFunc)
+  }
+  g.handlers[domain] = fn
+}
+
+// HandleGioData answers the gio requests of domain with the data stored under
+// the hex encoded id
+func (g *gioHandlers) HandleGioData(domain uint16, data map[string][]byte) {
+  g.HandleGio(domain, func(id []byte) (uint16, []byte) {
+    if value, ok := data[rollups.Bin2Hex(id)]; ok {
+      return rollups.GioResponseOk, value
+    }
+    return 1, nil
+  })
+}
+
+func (g *gioHandlers) gio(request *rollups.GioRequest) (*rollups.GioResponse, error) {
+  g.mu.Lock()
+  fn := g.handlers[request.Domain]
+  g.mu.Unlock()
+  if fn == nil {
+    return nil, fmt.Errorf("unsupported gio domain %d", request.Domain)
+  }
+  id, err := rollups.Hex2Bin(request.Id)
+  if err != nil {
+    return nil, fmt.Errorf("invalid gio id: %s", err)
+  }
+  code, data := fn(id)
+  return &rollups.GioResponse{Code: code, Data: rollups.Bin2Hex(data)}, nil
+}
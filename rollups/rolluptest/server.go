@@ -0,0 +1,349 @@
+// Package rolluptest provides utilities to test rollups dapps without a
+// Cartesi node.
+//
+// Server emulates the rollup http server api: it serves a scripted queue of
+// advance and inspect requests and records the outputs of each one.
+//
+//  srv := rolluptest.NewServer()
+//  defer srv.Close()
+//  srv.AddAdvance("0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266", rollups.Str2Hex("hello"))
+//  srv.AddInspect(rollups.Str2Hex("status"))
+//
+//  h := handler.NewHandler(rollups.NewHttpTransport(srv.URL))
+//  h.HandleDefault(myHandler)
+//  results, err := srv.Run(h.RunContext)
+package rolluptest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"github.com/prototyp3-dev/go-rollups/rollups"
+)
+
+const (
+  AdvanceState = "advance_state"
+  InspectState = "inspect_state"
+)
+
+type Input struct {
+  Type string
+  Metadata *rollups.Metadata
+  Payload string
+}
+
+// Result holds the outputs generated by the dapp while processing an input
+// and the status it finished with (accept, reject or exception).
+type Result struct {
+  Input *Input
+  Status string
+  // Err is the error returned by the handler, only available when the input
+  // is processed directly (see Transport)
+  Err error
+  Notices []rollups.Notice
+  Vouchers []rollups.Voucher
+  DelegateCallVouchers []rollups.DelegateCallVoucher
+  Reports []rollups.Report
+  Exception *rollups.Exception
+}
+
+func (r *Result) Accepted() bool {
+  return r.Status == "accept"
+}
+
+type Server struct {
+  URL string
+  // PollTimeout is how long a finish request waits for a new input before
+  // the server answers that there is no pending request.
+  PollTimeout time.Duration
+
+  gioHandlers
+
+  server *httptest.Server
+  mu sync.Mutex
+  changed chan struct{}
+  queue []*Input
+  current *Result
+  results []*Result
+  inputIndex uint64
+}
+
+func NewServer() *Server {
+  s := &Server{PollTimeout: 100 * time.Millisecond, changed: make(chan struct{})}
+  mux := http.NewServeMux()
+  mux.HandleFunc("/finish", s.handleFinish)
+  mux.HandleFunc("/notice", s.handleNotice)
+  mux.HandleFunc("/voucher", s.handleVoucher)
+  mux.HandleFunc("/delegate-call-voucher", s.handleDelegateCallVoucher)
+  mux.HandleFunc("/report", s.handleReport)
+  mux.HandleFunc("/exception", s.handleException)
+  mux.HandleFunc("/gio", s.handleGio)
+  s.server = httptest.NewServer(mux)
+  s.URL = s.server.URL
+  return s
+}
+
+func (s *Server) Close() {
+  s.server.Close()
+}
+
+// notify wakes everyone waiting for a state change, must hold s.mu
+func (s *Server) notify() {
+  close(s.changed)
+  s.changed = make(chan struct{})
+}
+
+// AddAdvance queues an advance request from sender with generated metadata.
+func (s *Server) AddAdvance(sender string, payloadHex string) {
+  s.mu.Lock()
+  metadata := &rollups.Metadata{
+    MsgSender: sender,
+    InputIndex: s.inputIndex,
+    BlockNumber: s.inputIndex + 1,
+    Timestamp: uint64(time.Now().Unix()),
+  }
+  s.mu.Unlock()
+  s.AddAdvanceMetadata(metadata, payloadHex)
+}
+
+// AddAdvanceMetadata queues an advance request with the given metadata.
+func (s *Server) AddAdvanceMetadata(metadata *rollups.Metadata, payloadHex string) {
+  s.mu.Lock()
+  defer s.mu.Unlock()
+  s.queue = append(s.queue, &Input{Type: AdvanceState, Metadata: metadata, Payload: payloadHex})
+  s.inputIndex = metadata.InputIndex + 1
+  s.notify()
+}
+
+func (s *Server) AddInspect(payloadHex string) {
+  s.mu.Lock()
+  defer s.mu.Unlock()
+  s.queue = append(s.queue, &Input{Type: InspectState, Payload: payloadHex})
+  s.notify()
+}
+
+// Results returns the results of all the inputs processed so far.
+func (s *Server) Results() []*Result {
+  s.mu.Lock()
+  defer s.mu.Unlock()
+  return append([]*Result{}, s.results...)
+}
+
+func (s *Server) Pending() int {
+  s.mu.Lock()
+  defer s.mu.Unlock()
+  pending := len(s.queue)
+  if s.current != nil {
+    pending++
+  }
+  return pending
+}
+
+// Wait blocks until all queued inputs are processed.
+func (s *Server) Wait(ctx context.Context) error {
+  for {
+    s.mu.Lock()
+    idle := len(s.queue) == 0 && s.current == nil
+    changed := s.changed
+    s.mu.Unlock()
+    if idle {
+      return nil
+    }
+    select {
+    case <-ctx.Done():
+      return ctx.Err()
+    case <-changed:
+    }
+  }
+}
+
+// Run executes the dapp loop (e.g. handler.RunContext) until all queued
+// inputs are processed and returns the results of the inputs.
+func (s *Server) Run(run func(context.Context) error) ([]*Result, error) {
+  ctx, cancel := context.WithCancel(context.Background())
+  defer cancel()
+
+  runErrCh := make(chan error, 1)
+  go func() {
+    runErrCh <- run(ctx)
+  }()
+
+  waitErrCh := make(chan error, 1)
+  go func() {
+    waitErrCh <- s.Wait(ctx)
+  }()
+
+  select {
+  case err := <-runErrCh:
+    return s.Results(), fmt.Errorf("rolluptest: run finished before processing all inputs: %s", err)
+  case <-waitErrCh:
+  }
+  cancel()
+  // drop the pending finish long poll so it doesn't consume inputs queued later
+  s.server.CloseClientConnections()
+  <-runErrCh
+  return s.Results(), nil
+}
+
+func writeError(w http.ResponseWriter, message string) {
+  w.WriteHeader(http.StatusBadRequest)
+  w.Write([]byte(message))
+}
+
+func writeJson(w http.ResponseWriter, data interface{}) {
+  w.Header().Set("Content-Type", "application/json")
+  json.NewEncoder(w).Encode(data)
+}
+
+func (s *Server) handleFinish(w http.ResponseWriter, r *http.Request) {
+  var finish rollups.Finish
+  if err := json.NewDecoder(r.Body).Decode(&finish); err != nil {
+    writeError(w, fmt.Sprintf("invalid finish body: %s", err))
+    return
+  }
+  if finish.Status != "accept" && finish.Status != "reject" {
+    writeError(w, fmt.Sprintf("invalid finish status: %s", finish.Status))
+    return
+  }
+
+  s.mu.Lock()
+  if s.current != nil {
+    s.current.Status = finish.Status
+    s.results = append(s.results, s.current)
+    s.current = nil
+    s.notify()
+  }
+
+  timeout := time.NewTimer(s.PollTimeout)
+  defer timeout.Stop()
+  for len(s.queue) == 0 {
+    changed := s.changed
+    s.mu.Unlock()
+    select {
+    case <-r.Context().Done():
+      return
+    case <-timeout.C:
+      w.WriteHeader(http.StatusAccepted)
+      return
+    case <-changed:
+    }
+    s.mu.Lock()
+  }
+  if r.Context().Err() != nil {
+    s.mu.Unlock()
+    return
+  }
+
+  input := s.queue[0]
+  s.queue = s.queue[1:]
+  s.current = &Result{Input: input}
+  s.notify()
+  s.mu.Unlock()
+
+  var data interface{}
+  if input.Type == AdvanceState {
+    data = rollups.AdvanceResponse{Metadata: *input.Metadata, Payload: input.Payload}
+  } else {
+    data = rollups.InspectResponse{Payload: input.Payload}
+  }
+  dataJson, err := json.Marshal(data)
+  if err != nil {
+    writeError(w, fmt.Sprintf("error encoding request: %s", err))
+    return
+  }
+  writeJson(w, rollups.FinishResponse{Type: input.Type, Data: dataJson})
+}
+
+// currentOutput decodes an output body and checks an input is being processed,
+// must hold s.mu
+func (s *Server) currentOutput(w http.ResponseWriter, r *http.Request, output interface{}, advanceOnly bool) bool {
+  if err := json.NewDecoder(r.Body).Decode(output); err != nil {
+    writeError(w, fmt.Sprintf("invalid body: %s", err))
+    return false
+  }
+  if s.current == nil {
+    writeError(w, "no request being processed")
+    return false
+  }
+  if advanceOnly && s.current.Input.Type != AdvanceState {
+    writeError(w, "output not allowed on inspect")
+    return false
+  }
+  return true
+}
+
+func (s *Server) handleNotice(w http.ResponseWriter, r *http.Request) {
+  s.mu.Lock()
+  defer s.mu.Unlock()
+  var notice rollups.Notice
+  if !s.currentOutput(w, r, &notice, true) {
+    return
+  }
+  s.current.Notices = append(s.current.Notices, notice)
+  writeJson(w, rollups.IndexResponse{Index: uint64(len(s.current.Notices) - 1)})
+}
+
+func (s *Server) handleVoucher(w http.ResponseWriter, r *http.Request) {
+  s.mu.Lock()
+  defer s.mu.Unlock()
+  var voucher rollups.Voucher
+  if !s.currentOutput(w, r, &voucher, true) {
+    return
+  }
+  s.current.Vouchers = append(s.current.Vouchers, voucher)
+  writeJson(w, rollups.IndexResponse{Index: uint64(len(s.current.Vouchers) + len(s.current.DelegateCallVouchers) - 1)})
+}
+
+func (s *Server) handleDelegateCallVoucher(w http.ResponseWriter, r *http.Request) {
+  s.mu.Lock()
+  defer s.mu.Unlock()
+  var voucher rollups.DelegateCallVoucher
+  if !s.currentOutput(w, r, &voucher, true) {
+    return
+  }
+  s.current.DelegateCallVouchers = append(s.current.DelegateCallVouchers, voucher)
+  writeJson(w, rollups.IndexResponse{Index: uint64(len(s.current.Vouchers) + len(s.current.DelegateCallVouchers) - 1)})
+}
+
+func (s *Server) handleReport(w http.ResponseWriter, r *http.Request) {
+  s.mu.Lock()
+  defer s.mu.Unlock()
+  var report rollups.Report
+  if !s.currentOutput(w, r, &report, false) {
+    return
+  }
+  s.current.Reports = append(s.current.Reports, report)
+}
+
+func (s *Server) handleException(w http.ResponseWriter, r *http.Request) {
+  s.mu.Lock()
+  defer s.mu.Unlock()
+  var exception rollups.Exception
+  if !s.currentOutput(w, r, &exception, false) {
+    return
+  }
+  s.current.Exception = &exception
+  s.current.Status = "exception"
+  s.results = append(s.results, s.current)
+  s.current = nil
+  s.notify()
+}
+
+func (s *Server) handleGio(w http.ResponseWriter, r *http.Request) {
+  var request rollups.GioRequest
+  if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+    writeError(w, fmt.Sprintf("invalid body: %s", err))
+    return
+  }
+  response, err := s.gio(&request)
+  if err != nil {
+    writeError(w, err.Error())
+    return
+  }
+  writeJson(w, response)
+}
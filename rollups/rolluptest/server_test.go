@@ -0,0 +1,140 @@
+package rolluptest_test
+
+import (
+  "fmt"
+  "reflect"
+  "testing"
+
+  hdl "github.com/prototyp3-dev/go-rollups/handler"
+  "github.com/prototyp3-dev/go-rollups/rollups"
+  "github.com/prototyp3-dev/go-rollups/rollups/rolluptest"
+)
+
+const (
+  sender = "0xf39fd6e51aad88f6f4ce6ab8827279cfffb92266"
+  destination = "0x70997970c51812dc3a010c7d01b50e0d17dc79c8"
+  gioDomain uint16 = 0x2a
+)
+
+// newDapp returns a handler that, for each payload:
+//  "outputs": sends a notice, a voucher and a report
+//  "gio": reads id 0x0102 and sends its data as a notice
+//  "fail": rejects the input
+//  "exception": sends an exception
+// and reports the payload of the inspects, or sends a notice for "notice"
+func newDapp(transport rollups.Transport) *hdl.Handler {
+  h := hdl.NewHandler(transport)
+  h.HandleAdvance(func(metadata *rollups.Metadata, payloadHex string) error {
+    payload, err := rollups.Hex2Str(payloadHex)
+    if err != nil {
+      return err
+    }
+    switch payload {
+    case "outputs":
+      if _, err := h.SendNotice(rollups.Str2Hex("notice")); err != nil {
+        return err
+      }
+      if _, err := h.SendVoucher(destination, rollups.Str2Hex("voucher")); err != nil {
+        return err
+      }
+      return h.SendReport(rollups.Str2Hex("report"))
+    case "gio":
+      response, err := h.Gio(gioDomain, "0x0102")
+      if err != nil {
+        return err
+      }
+      _, err = h.SendNotice(response.Data)
+      return err
+    case "exception":
+      return h.SendException(rollups.Str2Hex("exception"))
+    }
+    return fmt.Errorf("fail")
+  })
+  h.HandleInspect(func(payloadHex string) error {
+    if payload, _ := rollups.Hex2Str(payloadHex); payload == "notice" {
+      _, err := h.SendNotice(payloadHex)
+      return err
+    }
+    return h.SendReport(payloadHex)
+  })
+  return h
+}
+
+func TestServer(t *testing.T) {
+  srv := rolluptest.NewServer()
+  defer srv.Close()
+  srv.HandleGioData(gioDomain, map[string][]byte{"0x0102": []byte("gio data")})
+  srv.AddAdvance(sender, rollups.Str2Hex("outputs"))
+  srv.AddAdvance(sender, rollups.Str2Hex("fail"))
+  srv.AddInspect(rollups.Str2Hex("status"))
+  srv.AddInspect(rollups.Str2Hex("notice"))
+  srv.AddAdvance(sender, rollups.Str2Hex("gio"))
+  srv.AddAdvance(sender, rollups.Str2Hex("exception"))
+
+  results, err := srv.Run(newDapp(rollups.NewHttpTransport(srv.URL)).RunContext)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if len(results) != 6 {
+    t.Fatalf("%d results, expected 6", len(results))
+  }
+
+  outputs := results[0]
+  if outputs.Status != "accept" || outputs.Input.Metadata.MsgSender != sender || outputs.Input.Metadata.InputIndex != 0 {
+    t.Errorf("outputs: status %s, metadata %+v", outputs.Status, outputs.Input.Metadata)
+  }
+  if !reflect.DeepEqual(outputs.Notices, []rollups.Notice{{Payload: rollups.Str2Hex("notice")}}) {
+    t.Errorf("outputs: notices %v", outputs.Notices)
+  }
+  if !reflect.DeepEqual(outputs.Vouchers, []rollups.Voucher{{Destination: destination, Payload: rollups.Str2Hex("voucher")}}) {
+    t.Errorf("outputs: vouchers %v", outputs.Vouchers)
+  }
+  if !reflect.DeepEqual(outputs.Reports, []rollups.Report{{Payload: rollups.Str2Hex("report")}}) {
+    t.Errorf("outputs: reports %v", outputs.Reports)
+  }
+
+  if results[1].Status != "reject" || results[1].Input.Metadata.InputIndex != 1 {
+    t.Errorf("fail: status %s, input index %d", results[1].Status, results[1].Input.Metadata.InputIndex)
+  }
+  inspect := results[2]
+  if inspect.Status != "accept" || inspect.Input.Type != rolluptest.InspectState ||
+      !reflect.DeepEqual(inspect.Reports, []rollups.Report{{Payload: rollups.Str2Hex("status")}}) {
+    t.Errorf("inspect: status %s, reports %v", inspect.Status, inspect.Reports)
+  }
+  // the server refuses notices on inspects
+  if results[3].Status != "reject" || len(results[3].Notices) != 0 {
+    t.Errorf("inspect notice: status %s, notices %v", results[3].Status, results[3].Notices)
+  }
+  gio := results[4]
+  if gio.Status != "accept" || !reflect.DeepEqual(gio.Notices, []rollups.Notice{{Payload: rollups.Str2Hex("gio data")}}) {
+    t.Errorf("gio: status %s, notices %v", gio.Status, gio.Notices)
+  }
+  exception := results[5]
+  if exception.Status != "exception" || exception.Exception == nil || exception.Exception.Payload != rollups.Str2Hex("exception") {
+    t.Errorf("exception: status %s, exception %v", exception.Status, exception.Exception)
+  }
+}
+
+func TestServerGioError(t *testing.T) {
+  srv := rolluptest.NewServer()
+  defer srv.Close()
+  srv.HandleGioData(gioDomain, map[string][]byte{})
+  var gioErr error
+  h := hdl.NewHandler(rollups.NewHttpTransport(srv.URL))
+  h.HandleAdvance(func(metadata *rollups.Metadata, payloadHex string) error {
+    _, gioErr = h.Gio(gioDomain, "0x0102")
+    return gioErr
+  })
+  srv.AddAdvance(sender, rollups.Str2Hex("gio"))
+
+  results, err := srv.Run(h.RunContext)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if len(results) != 1 || results[0].Status != "reject" {
+    t.Fatalf("results %v, expected a rejected input", results)
+  }
+  if _, ok := gioErr.(*rollups.GioError); !ok {
+    t.Errorf("gio error %v, expected a *rollups.GioError", gioErr)
+  }
+}
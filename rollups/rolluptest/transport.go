@@ -0,0 +1,133 @@
+package rolluptest
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/prototyp3-dev/go-rollups/rollups"
+)
+
+// Transport is an in-memory rollups.Transport that records the outputs of
+// the input being processed. Inputs are not fetched through Finish, they are
+// delimited with Begin and End by whoever is feeding the handler.
+type Transport struct {
+  gioHandlers
+
+  mu sync.Mutex
+  current *Result
+  results []*Result
+}
+
+func NewTransport() *Transport {
+  return &Transport{}
+}
+
+// Begin starts recording the outputs of input.
+func (t *Transport) Begin(input *Input) {
+  t.mu.Lock()
+  defer t.mu.Unlock()
+  t.current = &Result{Input: input}
+}
+
+// End finishes the current input with the handler returned error and returns
+// its result.
+func (t *Transport) End(err error) *Result {
+  t.mu.Lock()
+  defer t.mu.Unlock()
+  result := t.current
+  if result == nil {
+    return nil
+  }
+  t.current = nil
+  result.Err = err
+  if result.Exception == nil {
+    if err != nil {
+      result.Status = "reject"
+    } else {
+      result.Status = "accept"
+    }
+  }
+  t.results = append(t.results, result)
+  return result
+}
+
+// Results returns the results of all the inputs processed so far.
+func (t *Transport) Results() []*Result {
+  t.mu.Lock()
+  defer t.mu.Unlock()
+  return append([]*Result{}, t.results...)
+}
+
+func (t *Transport) Finish(finish *rollups.Finish) (*rollups.FinishResponse, error) {
+  return nil, nil
+}
+
+func (t *Transport) currentOutput(advanceOnly bool) (*Result, error) {
+  if t.current == nil {
+    return nil, fmt.Errorf("no request being processed")
+  }
+  if advanceOnly && t.current.Input.Type != AdvanceState {
+    return nil, fmt.Errorf("output not allowed on inspect")
+  }
+  return t.current, nil
+}
+
+func (t *Transport) Notice(notice *rollups.Notice) (uint64, error) {
+  t.mu.Lock()
+  defer t.mu.Unlock()
+  result, err := t.currentOutput(true)
+  if err != nil {
+    return 0, err
+  }
+  result.Notices = append(result.Notices, *notice)
+  return uint64(len(result.Notices) - 1), nil
+}
+
+func (t *Transport) Voucher(voucher *rollups.Voucher) (uint64, error) {
+  t.mu.Lock()
+  defer t.mu.Unlock()
+  result, err := t.currentOutput(true)
+  if err != nil {
+    return 0, err
+  }
+  result.Vouchers = append(result.Vouchers, *voucher)
+  return uint64(len(result.Vouchers) + len(result.DelegateCallVouchers) - 1), nil
+}
+
+func (t *Transport) DelegateCallVoucher(voucher *rollups.DelegateCallVoucher) (uint64, error) {
+  t.mu.Lock()
+  defer t.mu.Unlock()
+  result, err := t.currentOutput(true)
+  if err != nil {
+    return 0, err
+  }
+  result.DelegateCallVouchers = append(result.DelegateCallVouchers, *voucher)
+  return uint64(len(result.Vouchers) + len(result.DelegateCallVouchers) - 1), nil
+}
+
+func (t *Transport) Report(report *rollups.Report) error {
+  t.mu.Lock()
+  defer t.mu.Unlock()
+  result, err := t.currentOutput(false)
+  if err != nil {
+    return err
+  }
+  result.Reports = append(result.Reports, *report)
+  return nil
+}
+
+func (t *Transport) Exception(exception *rollups.Exception) error {
+  t.mu.Lock()
+  defer t.mu.Unlock()
+  result, err := t.currentOutput(false)
+  if err != nil {
+    return err
+  }
+  result.Exception = exception
+  result.Status = "exception"
+  return nil
+}
+
+func (t *Transport) Gio(request *rollups.GioRequest) (*rollups.GioResponse, error) {
+  return t.gio(request)
+}
@@ -0,0 +1,161 @@
+package rollups
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Transport is the channel a handler uses to talk to the rollup server:
+// it fetches the next request when finishing the current one and delivers
+// the outputs generated while processing it.
+type Transport interface {
+  // Finish reports the status of the current request and waits for the next
+  // one. It returns a nil response when there is no pending request.
+  Finish(finish *Finish) (*FinishResponse, error)
+  Notice(notice *Notice) (uint64, error)
+  Voucher(voucher *Voucher) (uint64, error)
+  DelegateCallVoucher(voucher *DelegateCallVoucher) (uint64, error)
+  Report(report *Report) error
+  Exception(exception *Exception) error
+  Gio(request *GioRequest) (*GioResponse, error)
+}
+
+// HttpTransport is the default Transport, it posts to the rollup http server api.
+type HttpTransport struct {
+  Url string
+  Client *http.Client
+  // Retries is how many times a failure to connect to the server is retried,
+  // waiting RetryBackoff before the first retry and doubling it after each
+  // attempt. Only the errors dialing the server are retried: a request that
+  // may have reached it (e.g. the connection dropped while waiting for the
+  // response) isn't sent again, so outputs aren't duplicated and finish isn't
+  // repeated.
+  Retries int
+  RetryBackoff time.Duration
+}
+
+func NewHttpTransport(url string) *HttpTransport {
+  return &HttpTransport{Url: url, Client: http.DefaultClient, RetryBackoff: 100 * time.Millisecond}
+}
+
+func (t *HttpTransport) do(endpoint string, jsonData []byte) (*http.Response, error) {
+  client := t.Client
+  if client == nil {
+    client = http.DefaultClient
+  }
+  backoff := t.RetryBackoff
+  for attempt := 0; ; attempt++ {
+    req, err := http.NewRequest(http.MethodPost, t.Url+"/"+endpoint, bytes.NewBuffer(jsonData))
+    if err != nil {
+      return nil, err
+    }
+    req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+
+    res, err := client.Do(req)
+    if err == nil {
+      return res, nil
+    }
+    if attempt >= t.Retries || !notSent(err) {
+      return nil, fmt.Errorf("%s: %w: %s", endpoint, ErrServerUnavailable, err)
+    }
+    time.Sleep(backoff)
+    backoff *= 2
+  }
+}
+
+// notSent reports whether err proves the request never reached the server
+func notSent(err error) bool {
+  var opErr *net.OpError
+  return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+func (t *HttpTransport) post(endpoint string, data interface{}) ([]byte, int, error) {
+  jsonData, err := json.Marshal(data)
+  if err != nil {
+    return nil, 0, err
+  }
+  res, err := t.do(endpoint, jsonData)
+  if err != nil {
+    return nil, 0, err
+  }
+  defer res.Body.Close()
+
+  body, err := io.ReadAll(res.Body)
+  if err != nil {
+    return nil, res.StatusCode, fmt.Errorf("could not read response body: %s", err)
+  }
+  if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusAccepted {
+    return body, res.StatusCode, newStatusError(endpoint, res.StatusCode, body)
+  }
+  return body, res.StatusCode, nil
+}
+
+func (t *HttpTransport) postIndex(endpoint string, data interface{}) (uint64, error) {
+  body, _, err := t.post(endpoint, data)
+  if err != nil {
+    return 0, err
+  }
+  var indexRes IndexResponse
+  err = json.Unmarshal(body, &indexRes)
+  if err != nil {
+    return 0, fmt.Errorf("error unmarshaling body: %s", err)
+  }
+  return indexRes.Index, nil
+}
+
+func (t *HttpTransport) Finish(finish *Finish) (*FinishResponse, error) {
+  body, status, err := t.post("finish", finish)
+  if err != nil {
+    return nil, err
+  }
+  if status == http.StatusAccepted {
+    return nil, nil
+  }
+  var response FinishResponse
+  err = json.Unmarshal(body, &response)
+  if err != nil {
+    return nil, fmt.Errorf("error unmarshaling body: %s", err)
+  }
+  return &response, nil
+}
+
+func (t *HttpTransport) Notice(notice *Notice) (uint64, error) {
+  return t.postIndex("notice", notice)
+}
+
+func (t *HttpTransport) Voucher(voucher *Voucher) (uint64, error) {
+  return t.postIndex("voucher", voucher)
+}
+
+func (t *HttpTransport) DelegateCallVoucher(voucher *DelegateCallVoucher) (uint64, error) {
+  return t.postIndex("delegate-call-voucher", voucher)
+}
+
+func (t *HttpTransport) Report(report *Report) error {
+  _, _, err := t.post("report", report)
+  return err
+}
+
+func (t *HttpTransport) Exception(exception *Exception) error {
+  _, _, err := t.post("exception", exception)
+  return err
+}
+
+func (t *HttpTransport) Gio(request *GioRequest) (*GioResponse, error) {
+  body, _, err := t.post("gio", request)
+  if err != nil {
+    return nil, err
+  }
+  var response GioResponse
+  err = json.Unmarshal(body, &response)
+  if err != nil {
+    return nil, fmt.Errorf("error unmarshaling body: %s", err)
+  }
+  return &response, nil
+}
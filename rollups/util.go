@@ -2,6 +2,8 @@ package rollups
 
 import (
 	"encoding/hex"
+	"fmt"
+	"math/big"
 )
 
 func Hex2Str(hx string) (string, error) {
@@ -13,6 +15,9 @@ func Hex2Str(hx string) (string, error) {
 }
 
 func Hex2Bin(hx string) ([]byte, error) {
+  if len(hx) < 2 {
+    return nil, fmt.Errorf("Hex2Bin: invalid hex %q", hx)
+  }
   bin, err := hex.DecodeString(hx[2:])
 	if err != nil {
     return bin, err
@@ -51,4 +56,7 @@ func PadBytesRight(bin []byte,size int) []byte {
   tmp := make([]byte, size)
   copy(tmp[:size], bin)
   return tmp
-}
\ No newline at end of file
+}
+func Uint256Hex(value *big.Int) string {
+  return Bin2Hex(PadBytes(value.Bytes(),32))
+}
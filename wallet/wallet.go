@@ -1,10 +1,9 @@
 package wallet
 
 import (
-  "os"
+  "context"
   "fmt"
   "math/big"
-  "log"
   "encoding/json"
   "github.com/prototyp3-dev/go-rollups/rollups"
   hdl "github.com/prototyp3-dev/go-rollups/handler"
@@ -12,8 +11,6 @@ import (
   "github.com/prototyp3-dev/go-rollups/handler/uri"
 )
 
-var DebugLogger *log.Logger
-
 //
 // Wallet
 //
@@ -153,6 +150,28 @@ func (w *Wallet) WithdrawErc1155(tokenAddress abihandler.Address, tokenId *big.I
   return nil
 }
 
+// Copy returns a deep copy of the wallet
+func (w *Wallet) Copy() *Wallet {
+  c := &Wallet{Ether: new(big.Int).Set(w.Ether), Erc20: make(map[abihandler.Address]*big.Int),
+    Erc721: make(map[abihandler.Address]map[[32]byte]struct{}), Erc1155: make(map[abihandler.Address]map[[32]byte]*big.Int)}
+  for tokenAddress, amount := range w.Erc20 {
+    c.Erc20[tokenAddress] = new(big.Int).Set(amount)
+  }
+  for tokenAddress, ids := range w.Erc721 {
+    c.Erc721[tokenAddress] = make(map[[32]byte]struct{})
+    for tokenIdBytes := range ids {
+      c.Erc721[tokenAddress][tokenIdBytes] = struct{}{}
+    }
+  }
+  for tokenAddress, amounts := range w.Erc1155 {
+    c.Erc1155[tokenAddress] = make(map[[32]byte]*big.Int)
+    for tokenIdBytes, amount := range amounts {
+      c.Erc1155[tokenAddress][tokenIdBytes] = new(big.Int).Set(amount)
+    }
+  }
+  return c
+}
+
 //
 // WalletApp
 //
@@ -202,15 +221,142 @@ const (
   BalanceUriInspectRoute
 )
 
+// Route args, the abi tags are the fields of the route codecs
+
+type RelayArgs struct {
+  DappAddress abihandler.Address `abi:"address"`
+}
+
+type EtherDepositArgs struct {
+  Depositor abihandler.Address `abi:"address"`
+  Amount *big.Int `abi:"uint256"`
+  Data []byte `abi:"bytes"`
+}
+
+type Erc20DepositArgs struct {
+  Success bool `abi:"bool"`
+  Token abihandler.Address `abi:"address"`
+  Depositor abihandler.Address `abi:"address"`
+  Amount *big.Int `abi:"uint256"`
+  Data []byte `abi:"bytes"`
+}
+
+type Erc20DepositV2Args struct {
+  Token abihandler.Address `abi:"address"`
+  Depositor abihandler.Address `abi:"address"`
+  Amount *big.Int `abi:"uint256"`
+  Data []byte `abi:"bytes"`
+}
+
+type Erc721DepositArgs struct {
+  Token abihandler.Address `abi:"address"`
+  Depositor abihandler.Address `abi:"address"`
+  TokenId *big.Int `abi:"uint256"`
+  Data []byte `abi:"bytes"`
+}
+
+type Erc1155SingleDepositArgs struct {
+  Token abihandler.Address `abi:"address"`
+  Depositor abihandler.Address `abi:"address"`
+  TokenId *big.Int `abi:"uint256"`
+  Amount *big.Int `abi:"uint256"`
+  Data []byte `abi:"bytes"`
+}
+
+type Erc1155BatchDepositArgs struct {
+  Token abihandler.Address `abi:"address"`
+  Depositor abihandler.Address `abi:"address"`
+  Data []byte `abi:"bytes"`
+}
+
+type EtherWithdrawArgs struct {
+  Amount *big.Int `abi:"uint256"`
+  Data []byte `abi:"bytes"`
+}
+
+type Erc20WithdrawArgs struct {
+  Token abihandler.Address `abi:"address"`
+  Amount *big.Int `abi:"uint256"`
+  Data []byte `abi:"bytes"`
+}
+
+type Erc721WithdrawArgs struct {
+  Token abihandler.Address `abi:"address"`
+  TokenId *big.Int `abi:"uint256"`
+  Data []byte `abi:"bytes"`
+}
+
+type Erc1155SingleWithdrawArgs struct {
+  Token abihandler.Address `abi:"address"`
+  TokenId *big.Int `abi:"uint256"`
+  Amount *big.Int `abi:"uint256"`
+  Data []byte `abi:"bytes"`
+}
+
+type Erc1155BatchWithdrawArgs struct {
+  Token abihandler.Address `abi:"address"`
+  TokenIds []*big.Int `abi:"uint256[]"`
+  Amounts []*big.Int `abi:"uint256[]"`
+  Data []byte `abi:"bytes"`
+}
+
+type EtherTransferArgs struct {
+  Receiver abihandler.Address `abi:"address"`
+  Amount *big.Int `abi:"uint256"`
+  Data []byte `abi:"bytes"`
+}
+
+type Erc20TransferArgs struct {
+  Token abihandler.Address `abi:"address"`
+  Receiver abihandler.Address `abi:"address"`
+  Amount *big.Int `abi:"uint256"`
+  Data []byte `abi:"bytes"`
+}
+
+type Erc721TransferArgs struct {
+  Token abihandler.Address `abi:"address"`
+  Receiver abihandler.Address `abi:"address"`
+  TokenId *big.Int `abi:"uint256"`
+  Data []byte `abi:"bytes"`
+}
+
+type Erc1155SingleTransferArgs struct {
+  Token abihandler.Address `abi:"address"`
+  Receiver abihandler.Address `abi:"address"`
+  TokenId *big.Int `abi:"uint256"`
+  Amount *big.Int `abi:"uint256"`
+  Data []byte `abi:"bytes"`
+}
+
+type Erc1155BatchTransferArgs struct {
+  Token abihandler.Address `abi:"address"`
+  Receiver abihandler.Address `abi:"address"`
+  TokenIds []*big.Int `abi:"uint256[]"`
+  Amounts []*big.Int `abi:"uint256[]"`
+  Data []byte `abi:"bytes"`
+}
+
+type BalanceArgs struct {
+  Address abihandler.Address `abi:"address address"`
+}
+
 type WalletApp struct {
   handler *hdl.Handler
   abiHandler *abihandler.AbiHandler
   uriHandler *urihandler.UriHandler
+  uriPrefix string
   DappAddress abihandler.Address
+  // Wallets are rolled back with the rejected advances when changed through
+  // GetWallet, as the wallet routes do. Wallets set or deleted straight in the
+  // map, or changed through a *Wallet got before the advance, aren't.
   Wallets map[abihandler.Address]*Wallet
+  snapshot *walletAppSnapshot
 }
 
 func (w *WalletApp) GetWallet(address abihandler.Address) *Wallet {
+  if w.snapshot != nil && w.processingAdvance() {
+    w.snapshot.save(address, w.Wallets[address])
+  }
   if w.Wallets[address] == nil {
     w.Wallets[address] = &Wallet{Ether: new(big.Int), Erc20: make(map[abihandler.Address]*big.Int), 
       Erc721: make(map[abihandler.Address]map[[32]byte]struct{}), Erc1155: make(map[abihandler.Address]map[[32]byte]*big.Int)}
@@ -218,6 +364,15 @@ func (w *WalletApp) GetWallet(address abihandler.Address) *Wallet {
   return w.Wallets[address]
 }
 
+// processingAdvance reports whether the handler is processing an advance
+func (w *WalletApp) processingAdvance() bool {
+  if w.handler == nil {
+    return false
+  }
+  req := w.handler.Request()
+  return req != nil && req.IsAdvance()
+}
+
 var etherNoticeCodec *abihandler.Codec
 var erc20NoticeCodec *abihandler.Codec
 var erc721NoticeCodec *abihandler.Codec
@@ -232,12 +387,6 @@ var erc1155SingleVoucherCodec *abihandler.Codec
 var erc1155BatchVoucherCodec *abihandler.Codec
 
 func NewWalletApp() *WalletApp {
-  if hdl.KnownRollupsAddresses == nil {
-		panic("NewWalletApp: Rollups Addresses not initialized")
-  }
-
-  DebugLogger = log.New(os.Stderr, "[ debug ] ", log.Lshortfile)
-
   etherNoticeCodec = abihandler.NewCodec([]string{"address","int256","uint256"}) // address, amount, balance
   erc20NoticeCodec = abihandler.NewCodec([]string{"address","address","int256","uint256"}) // address, tokenAddress, amount, balance
   erc721NoticeCodec = abihandler.NewCodec([]string{"address","address","int256","uint256[]"}) // address, tokenAddress, tokenId, tokenIdList
@@ -261,6 +410,58 @@ func (w *WalletApp) SetHandler(handler *hdl.Handler) {
     panic("Nil handler")
   }
   w.handler = handler
+  handler.RegisterState(w)
+}
+
+// walletAppSnapshot keeps a copy of the wallets as they were before their
+// first change since the snapshot (copy on write), nil for the wallets created
+// after it, so taking a snapshot doesn't copy the wallets that aren't touched.
+// It only exists while an advance is processed, so inspects don't copy.
+type walletAppSnapshot struct {
+  dappAddress abihandler.Address
+  wallets map[abihandler.Address]*Wallet
+}
+
+func (s *walletAppSnapshot) save(address abihandler.Address, wallet *Wallet) {
+  if _, ok := s.wallets[address]; ok {
+    return
+  }
+  if wallet != nil {
+    wallet = wallet.Copy()
+  }
+  s.wallets[address] = wallet
+}
+
+// Snapshot implements hdl.Stateful, so the wallets are rolled back when an
+// input is rejected. It starts a new snapshot, dropping the previous one.
+func (w *WalletApp) Snapshot() (interface{}, error) {
+  w.snapshot = &walletAppSnapshot{dappAddress: w.DappAddress, wallets: make(map[abihandler.Address]*Wallet)}
+  return w.snapshot, nil
+}
+
+// Commit implements hdl.Committer, dropping the snapshot of an accepted advance
+func (w *WalletApp) Commit() {
+  w.snapshot = nil
+}
+
+func (w *WalletApp) Restore(snapshot interface{}) error {
+  s, ok := snapshot.(*walletAppSnapshot)
+  if !ok {
+    return fmt.Errorf("Restore: invalid wallet snapshot")
+  }
+  if s != w.snapshot {
+    return fmt.Errorf("Restore: not the last wallet snapshot")
+  }
+  w.DappAddress = s.dappAddress
+  for address, wallet := range s.wallets {
+    if wallet == nil {
+      delete(w.Wallets, address)
+    } else {
+      w.Wallets[address] = wallet
+    }
+  }
+  w.snapshot = nil
+  return nil
 }
 
 func (w *WalletApp) SetAbiHandler(abiHdl *abihandler.AbiHandler) {
@@ -283,6 +484,12 @@ func (w *WalletApp) SetUriHandler(uriHdl *urihandler.UriHandler) {
   w.uriHandler = uriHdl
 }
 
+// SetUriPrefix sets the prefix of the wallet uri routes (e.g. "/wallet" for
+// "/wallet/balance/:address"), it must be called before SetupRoutes
+func (w *WalletApp) SetUriPrefix(prefix string) {
+  w.uriPrefix = prefix
+}
+
 func (w *WalletApp) Handler() *hdl.Handler {
   if w.handler == nil {
     w.SetHandler(hdl.NewSimpleHandler())
@@ -304,127 +511,260 @@ func (w *WalletApp) UriHandler() *urihandler.UriHandler {
   return w.uriHandler
 }
 
+func (w *WalletApp) erc20PortalRoute() (*abihandler.Codec, hdl.ContextHandlerFunc) {
+  if w.Handler().IsV2() {
+    return abihandler.NewPackedCodec(abihandler.StructFields(Erc20DepositV2Args{})), w.Erc20PortalDepositV2Context
+  }
+  return abihandler.NewPackedCodec(abihandler.StructFields(Erc20DepositArgs{})), w.Erc20PortalDepositContext
+}
+
 func (w *WalletApp) SetupRoutes(routes []WalletRoute) {
+  addresses := w.Handler().Addresses()
+  if addresses == (hdl.NetworkAddresses{}) {
+		panic("SetupRoutes: Rollups Addresses not initialized")
+  }
 
   var forceRelayRoute bool
   var relayRouteAdded bool
+  erc20PortalCodec, erc20PortalDeposit := w.erc20PortalRoute()
 
   for _, route := range routes {
     switch route {
     case DappRelayAdvanceRoute:
       relayRouteAdded = true
-      w.AbiHandler().HandleFixedAddressAdvance(hdl.RollupsAddresses.DappAddressRelay, abihandler.NewPackedCodec([]string{"address"}), w.HandleRelay)
+      if addresses.DappAddressRelay == "" {
+        // v2 has no relay, the app address comes in the metadata
+        continue
+      }
+      w.AbiHandler().HandleFixedAddressAdvanceContext(addresses.DappAddressRelay, abihandler.NewPackedCodec(abihandler.StructFields(RelayArgs{})), w.HandleRelayContext)
     case EtherCodecAdvanceRoutes:
       forceRelayRoute = true
-      w.AbiHandler().HandleFixedAddressAdvance(hdl.RollupsAddresses.EtherPortalAddress, abihandler.NewPackedCodec([]string{"address","uint256","bytes"}), w.EtherPortalDeposit)
-      w.AbiHandler().HandleAdvanceRoute(abihandler.NewHeaderCodec("wallet","EtherWithdraw",[]string{"uint256","bytes"}), w.EtherWithdraw)
-      w.AbiHandler().HandleAdvanceRoute(abihandler.NewHeaderCodec("wallet","EtherTransfer",[]string{"address","uint256","bytes"}), w.TransferEtherCodec)
+      w.AbiHandler().HandleFixedAddressAdvanceContext(addresses.EtherPortalAddress, abihandler.NewPackedCodec(abihandler.StructFields(EtherDepositArgs{})), w.EtherPortalDepositContext)
+      w.AbiHandler().HandleAdvanceRouteContext(abihandler.NewHeaderStructCodec("wallet","EtherWithdraw",EtherWithdrawArgs{}), w.EtherWithdrawContext)
+      w.AbiHandler().HandleAdvanceRouteContext(abihandler.NewHeaderStructCodec("wallet","EtherTransfer",EtherTransferArgs{}), w.TransferEtherCodecContext)
     case DepositEtherAdvanceRoute:
-      w.AbiHandler().HandleFixedAddressAdvance(hdl.RollupsAddresses.EtherPortalAddress, abihandler.NewPackedCodec([]string{"address","uint256","bytes"}), w.EtherPortalDeposit)
+      w.AbiHandler().HandleFixedAddressAdvanceContext(addresses.EtherPortalAddress, abihandler.NewPackedCodec(abihandler.StructFields(EtherDepositArgs{})), w.EtherPortalDepositContext)
     case Erc20CodecAdvanceRoutes:
-      w.AbiHandler().HandleFixedAddressAdvance(hdl.RollupsAddresses.Erc20PortalAddress, abihandler.NewPackedCodec([]string{"bool","address","address","uint256","bytes"}), w.Erc20PortalDeposit)
-      w.AbiHandler().HandleAdvanceRoute(abihandler.NewHeaderCodec("wallet","Erc20Withdraw",[]string{"address","uint256","bytes"}), w.Erc20Withdraw)
-      w.AbiHandler().HandleAdvanceRoute(abihandler.NewHeaderCodec("wallet","Erc20Transfer",[]string{"address","address","uint256","bytes"}), w.TransferErc20Codec)
+      w.AbiHandler().HandleFixedAddressAdvanceContext(addresses.Erc20PortalAddress, erc20PortalCodec, erc20PortalDeposit)
+      w.AbiHandler().HandleAdvanceRouteContext(abihandler.NewHeaderStructCodec("wallet","Erc20Withdraw",Erc20WithdrawArgs{}), w.Erc20WithdrawContext)
+      w.AbiHandler().HandleAdvanceRouteContext(abihandler.NewHeaderStructCodec("wallet","Erc20Transfer",Erc20TransferArgs{}), w.TransferErc20CodecContext)
     case DepositErc20AdvanceRoute:
-      w.AbiHandler().HandleFixedAddressAdvance(hdl.RollupsAddresses.Erc20PortalAddress, abihandler.NewPackedCodec([]string{"bool","address","address","uint256","bytes"}), w.Erc20PortalDeposit)
+      w.AbiHandler().HandleFixedAddressAdvanceContext(addresses.Erc20PortalAddress, erc20PortalCodec, erc20PortalDeposit)
     case Erc721CodecAdvanceRoutes:
       forceRelayRoute = true
-      w.AbiHandler().HandleFixedAddressAdvance(hdl.RollupsAddresses.Erc721PortalAddress, abihandler.NewPackedCodec([]string{"address","address","uint256","bytes"}), w.Erc721PortalDeposit)
-      w.AbiHandler().HandleAdvanceRoute(abihandler.NewHeaderCodec("wallet","Erc721Withdraw",[]string{"address","uint256","bytes"}), w.Erc721Withdraw)
-      w.AbiHandler().HandleAdvanceRoute(abihandler.NewHeaderCodec("wallet","Erc721Transfer",[]string{"address","address","uint256","bytes"}), w.TransferErc721Codec)
+      w.AbiHandler().HandleFixedAddressAdvanceContext(addresses.Erc721PortalAddress, abihandler.NewPackedCodec(abihandler.StructFields(Erc721DepositArgs{})), w.Erc721PortalDepositContext)
+      w.AbiHandler().HandleAdvanceRouteContext(abihandler.NewHeaderStructCodec("wallet","Erc721Withdraw",Erc721WithdrawArgs{}), w.Erc721WithdrawContext)
+      w.AbiHandler().HandleAdvanceRouteContext(abihandler.NewHeaderStructCodec("wallet","Erc721Transfer",Erc721TransferArgs{}), w.TransferErc721CodecContext)
     case DepositErc721AdvanceRoute:
-      w.AbiHandler().HandleFixedAddressAdvance(hdl.RollupsAddresses.Erc721PortalAddress, abihandler.NewPackedCodec([]string{"address","address","uint256","bytes"}), w.Erc721PortalDeposit)
+      w.AbiHandler().HandleFixedAddressAdvanceContext(addresses.Erc721PortalAddress, abihandler.NewPackedCodec(abihandler.StructFields(Erc721DepositArgs{})), w.Erc721PortalDepositContext)
     case Erc1155CodecAdvanceRoutes:
       forceRelayRoute = true
-      w.AbiHandler().HandleFixedAddressAdvance(hdl.RollupsAddresses.Erc1155SinglePortalAddress, abihandler.NewPackedCodec([]string{"address","address","uint256","uint256","bytes"}), w.Erc1155SinglePortalDeposit)
-      w.AbiHandler().HandleFixedAddressAdvance(hdl.RollupsAddresses.Erc1155BatchPortalAddress, abihandler.NewPackedCodec([]string{"address","address","bytes"}), w.Erc1155BatchPortalDeposit)
-      w.AbiHandler().HandleAdvanceRoute(abihandler.NewHeaderCodec("wallet","Erc1155BatchWithdraw",[]string{"address","uint256[]","uint256[]","bytes"}), w.Erc1155BatchWithdraw)
-      w.AbiHandler().HandleAdvanceRoute(abihandler.NewHeaderCodec("wallet","Erc1155SingleWithdraw",[]string{"address","uint256","uint256","bytes"}), w.Erc1155SingleWithdraw)
-      w.AbiHandler().HandleAdvanceRoute(abihandler.NewHeaderCodec("wallet","Erc1155SingleTransfer",[]string{"address","address","uint256","uint256","bytes"}), w.TransferErc1155SingleCodec)
-      w.AbiHandler().HandleAdvanceRoute(abihandler.NewHeaderCodec("wallet","Erc1155BatchTransfer",[]string{"address","address","uint256[]","uint256[]","bytes"}), w.TransferErc1155BatchCodec)
+      w.AbiHandler().HandleFixedAddressAdvanceContext(addresses.Erc1155SinglePortalAddress, abihandler.NewPackedCodec(abihandler.StructFields(Erc1155SingleDepositArgs{})), w.Erc1155SinglePortalDepositContext)
+      w.AbiHandler().HandleFixedAddressAdvanceContext(addresses.Erc1155BatchPortalAddress, abihandler.NewPackedCodec(abihandler.StructFields(Erc1155BatchDepositArgs{})), w.Erc1155BatchPortalDepositContext)
+      w.AbiHandler().HandleAdvanceRouteContext(abihandler.NewHeaderStructCodec("wallet","Erc1155BatchWithdraw",Erc1155BatchWithdrawArgs{}), w.Erc1155BatchWithdrawContext)
+      w.AbiHandler().HandleAdvanceRouteContext(abihandler.NewHeaderStructCodec("wallet","Erc1155SingleWithdraw",Erc1155SingleWithdrawArgs{}), w.Erc1155SingleWithdrawContext)
+      w.AbiHandler().HandleAdvanceRouteContext(abihandler.NewHeaderStructCodec("wallet","Erc1155SingleTransfer",Erc1155SingleTransferArgs{}), w.TransferErc1155SingleCodecContext)
+      w.AbiHandler().HandleAdvanceRouteContext(abihandler.NewHeaderStructCodec("wallet","Erc1155BatchTransfer",Erc1155BatchTransferArgs{}), w.TransferErc1155BatchCodecContext)
     case Erc1155SingleCodecAdvanceRoutes:
       forceRelayRoute = true
-      w.AbiHandler().HandleFixedAddressAdvance(hdl.RollupsAddresses.Erc1155SinglePortalAddress, abihandler.NewPackedCodec([]string{"address","address","uint256","uint256","bytes"}), w.Erc1155SinglePortalDeposit)
-      w.AbiHandler().HandleAdvanceRoute(abihandler.NewHeaderCodec("wallet","Erc1155SingleWithdraw",[]string{"address","uint256","uint256","bytes"}), w.Erc1155SingleWithdraw)
-      w.AbiHandler().HandleAdvanceRoute(abihandler.NewHeaderCodec("wallet","Erc1155SingleTransfer",[]string{"address","address","uint256","uint256","bytes"}), w.TransferErc1155SingleCodec)
+      w.AbiHandler().HandleFixedAddressAdvanceContext(addresses.Erc1155SinglePortalAddress, abihandler.NewPackedCodec(abihandler.StructFields(Erc1155SingleDepositArgs{})), w.Erc1155SinglePortalDepositContext)
+      w.AbiHandler().HandleAdvanceRouteContext(abihandler.NewHeaderStructCodec("wallet","Erc1155SingleWithdraw",Erc1155SingleWithdrawArgs{}), w.Erc1155SingleWithdrawContext)
+      w.AbiHandler().HandleAdvanceRouteContext(abihandler.NewHeaderStructCodec("wallet","Erc1155SingleTransfer",Erc1155SingleTransferArgs{}), w.TransferErc1155SingleCodecContext)
     case DepositErc1155SingleAdvanceRoute:
-      w.AbiHandler().HandleFixedAddressAdvance(hdl.RollupsAddresses.Erc1155SinglePortalAddress, abihandler.NewPackedCodec([]string{"address","address","uint256","uint256","bytes"}), w.Erc1155SinglePortalDeposit)
+      w.AbiHandler().HandleFixedAddressAdvanceContext(addresses.Erc1155SinglePortalAddress, abihandler.NewPackedCodec(abihandler.StructFields(Erc1155SingleDepositArgs{})), w.Erc1155SinglePortalDepositContext)
     case Erc1155BatchCodecAdvanceRoutes:
       forceRelayRoute = true
-      w.AbiHandler().HandleFixedAddressAdvance(hdl.RollupsAddresses.Erc1155BatchPortalAddress, abihandler.NewPackedCodec([]string{"address","address","bytes"}), w.Erc1155BatchPortalDeposit)
-      w.AbiHandler().HandleAdvanceRoute(abihandler.NewHeaderCodec("wallet","Erc1155BatchWithdraw",[]string{"address","uint256[]","uint256[]","bytes"}), w.Erc1155BatchWithdraw)
-      w.AbiHandler().HandleAdvanceRoute(abihandler.NewHeaderCodec("wallet","Erc1155BatchTransfer",[]string{"address","address","uint256[]","uint256[]","bytes"}), w.TransferErc1155BatchCodec)
+      w.AbiHandler().HandleFixedAddressAdvanceContext(addresses.Erc1155BatchPortalAddress, abihandler.NewPackedCodec(abihandler.StructFields(Erc1155BatchDepositArgs{})), w.Erc1155BatchPortalDepositContext)
+      w.AbiHandler().HandleAdvanceRouteContext(abihandler.NewHeaderStructCodec("wallet","Erc1155BatchWithdraw",Erc1155BatchWithdrawArgs{}), w.Erc1155BatchWithdrawContext)
+      w.AbiHandler().HandleAdvanceRouteContext(abihandler.NewHeaderStructCodec("wallet","Erc1155BatchTransfer",Erc1155BatchTransferArgs{}), w.TransferErc1155BatchCodecContext)
     case DepositErc1155AdvanceRoute,DepositErc1155BatchAdvanceRoute:
-      w.AbiHandler().HandleFixedAddressAdvance(hdl.RollupsAddresses.Erc1155BatchPortalAddress, abihandler.NewPackedCodec([]string{"address","address","bytes"}), w.Erc1155BatchPortalDeposit)
+      w.AbiHandler().HandleFixedAddressAdvanceContext(addresses.Erc1155BatchPortalAddress, abihandler.NewPackedCodec(abihandler.StructFields(Erc1155BatchDepositArgs{})), w.Erc1155BatchPortalDepositContext)
     case WithdrawEtherAdvanceRoute,WithdrawEtherCodecAdvanceRoute:
       forceRelayRoute = true
-      w.AbiHandler().HandleAdvanceRoute(abihandler.NewHeaderCodec("wallet","EtherWithdraw",[]string{"uint256","bytes"}), w.EtherWithdraw)
+      w.AbiHandler().HandleAdvanceRouteContext(abihandler.NewHeaderStructCodec("wallet","EtherWithdraw",EtherWithdrawArgs{}), w.EtherWithdrawContext)
     case WithdrawErc20AdvanceRoute,WithdrawErc20CodecAdvanceRoute:
-      w.AbiHandler().HandleAdvanceRoute(abihandler.NewHeaderCodec("wallet","Erc20Withdraw",[]string{"address","uint256","bytes"}), w.Erc20Withdraw)
+      w.AbiHandler().HandleAdvanceRouteContext(abihandler.NewHeaderStructCodec("wallet","Erc20Withdraw",Erc20WithdrawArgs{}), w.Erc20WithdrawContext)
     case WithdrawErc721AdvanceRoute,WithdrawErc721CodecAdvanceRoute:
-      w.AbiHandler().HandleAdvanceRoute(abihandler.NewHeaderCodec("wallet","Erc721Withdraw",[]string{"address","uint256","bytes"}), w.Erc721Withdraw)
+      w.AbiHandler().HandleAdvanceRouteContext(abihandler.NewHeaderStructCodec("wallet","Erc721Withdraw",Erc721WithdrawArgs{}), w.Erc721WithdrawContext)
       forceRelayRoute = true
     case WithdrawErc1155SingleAdvanceRoute,WithdrawErc1155SingleCodecAdvanceRoute:
-      w.AbiHandler().HandleAdvanceRoute(abihandler.NewHeaderCodec("wallet","Erc1155SingleWithdraw",[]string{"address","uint256","uint256","bytes"}), w.Erc1155SingleWithdraw)
+      w.AbiHandler().HandleAdvanceRouteContext(abihandler.NewHeaderStructCodec("wallet","Erc1155SingleWithdraw",Erc1155SingleWithdrawArgs{}), w.Erc1155SingleWithdrawContext)
       forceRelayRoute = true
     case WithdrawErc1155AdvanceRoute,WithdrawErc1155BatchAdvanceRoute,WithdrawErc1155BatchCodecAdvanceRoute:
-      w.AbiHandler().HandleAdvanceRoute(abihandler.NewHeaderCodec("wallet","Erc1155BatchWithdraw",[]string{"address","uint256[]","uint256[]","bytes"}), w.Erc1155BatchWithdraw)
+      w.AbiHandler().HandleAdvanceRouteContext(abihandler.NewHeaderStructCodec("wallet","Erc1155BatchWithdraw",Erc1155BatchWithdrawArgs{}), w.Erc1155BatchWithdrawContext)
       forceRelayRoute = true
     case TransferEtherAdvanceRoute,TransferEtherCodecAdvanceRoute:
-      w.AbiHandler().HandleAdvanceRoute(abihandler.NewHeaderCodec("wallet","EtherTransfer",[]string{"address","uint256","bytes"}), w.TransferEtherCodec)
+      w.AbiHandler().HandleAdvanceRouteContext(abihandler.NewHeaderStructCodec("wallet","EtherTransfer",EtherTransferArgs{}), w.TransferEtherCodecContext)
     case TransferErc20AdvanceRoute,TransferErc20CodecAdvanceRoute:
-      w.AbiHandler().HandleAdvanceRoute(abihandler.NewHeaderCodec("wallet","Erc20Transfer",[]string{"address","address","uint256","bytes"}), w.TransferErc20Codec)
+      w.AbiHandler().HandleAdvanceRouteContext(abihandler.NewHeaderStructCodec("wallet","Erc20Transfer",Erc20TransferArgs{}), w.TransferErc20CodecContext)
     case TransferErc721AdvanceRoute,TransferErc721CodecAdvanceRoute:
-      w.AbiHandler().HandleAdvanceRoute(abihandler.NewHeaderCodec("wallet","Erc721Transfer",[]string{"address","address","uint256","bytes"}), w.TransferErc721Codec)
+      w.AbiHandler().HandleAdvanceRouteContext(abihandler.NewHeaderStructCodec("wallet","Erc721Transfer",Erc721TransferArgs{}), w.TransferErc721CodecContext)
     case TransferErc1155SingleAdvanceRoute,TransferErc1155SingleCodecAdvanceRoute:
-      w.AbiHandler().HandleAdvanceRoute(abihandler.NewHeaderCodec("wallet","Erc1155SingleTransfer",[]string{"address","address","uint256","uint256","bytes"}), w.TransferErc1155SingleCodec)
+      w.AbiHandler().HandleAdvanceRouteContext(abihandler.NewHeaderStructCodec("wallet","Erc1155SingleTransfer",Erc1155SingleTransferArgs{}), w.TransferErc1155SingleCodecContext)
     case TransferErc1155AdvanceRoute,TransferErc1155BatchAdvanceRoute,TransferErc1155BatchCodecAdvanceRoute:
-      w.AbiHandler().HandleAdvanceRoute(abihandler.NewHeaderCodec("wallet","Erc1155BatchTransfer",[]string{"address","address","uint256[]","uint256[]","bytes"}), w.TransferErc1155BatchCodec)
+      w.AbiHandler().HandleAdvanceRouteContext(abihandler.NewHeaderStructCodec("wallet","Erc1155BatchTransfer",Erc1155BatchTransferArgs{}), w.TransferErc1155BatchCodecContext)
     case BalanceInspectRoute,BalanceCodecInspectRoute:
-      w.AbiHandler().HandleInspectRoute(abihandler.NewHeaderCodec("wallet","Balance",[]string{"address address"}), w.BalanceAbi)
+      w.AbiHandler().HandleInspectRouteContext(abihandler.NewHeaderStructCodec("wallet","Balance",BalanceArgs{}), w.BalanceAbiContext)
     case BalanceUriInspectRoute:
-      w.UriHandler().HandleInspectRoute("/balance/:address", w.BalanceUri)
+      w.UriHandler().Group(w.uriPrefix).HandleInspectRouteContext("/balance/:address", w.BalanceUriContext)
     default:
       panic("Unrecognized route")
     }
   }
-  if forceRelayRoute && !relayRouteAdded {
-    w.AbiHandler().HandleFixedAddressAdvance(hdl.RollupsAddresses.DappAddressRelay, abihandler.NewPackedCodec([]string{"address"}), w.HandleRelay)
+  if forceRelayRoute && !relayRouteAdded && addresses.DappAddressRelay != "" {
+    w.AbiHandler().HandleFixedAddressAdvanceContext(addresses.DappAddressRelay, abihandler.NewPackedCodec(abihandler.StructFields(RelayArgs{})), w.HandleRelayContext)
   }
 }
 
 //
-// Relay
+// Map handlers
 //
 
+// The map handlers are the routes with the abi map handler signature, they
+// run the context routes with the given metadata and params, also between
+// inputs
+
+// mapRequest returns a copy of the input being processed with metadata and
+// payloadMap
+func (w *WalletApp) mapRequest(metadata *rollups.Metadata, payloadMap map[string]interface{}) *hdl.Request {
+  return w.Handler().DeriveRequest(metadata, payloadMap)
+}
+
 func (w *WalletApp) HandleRelay(metadata *rollups.Metadata, payloadMap map[string]interface{}) error {
-  addr, ok1 := payloadMap["0"].(abihandler.Address)
+  req := w.mapRequest(metadata, payloadMap)
+  return w.HandleRelayContext(req.Context(), req)
+}
 
-  if !ok1 {
-    message := "HandleRelay: parameters error"
-    return fmt.Errorf(message)
+func (w *WalletApp) EtherPortalDeposit(metadata *rollups.Metadata, payloadMap map[string]interface{}) error {
+  req := w.mapRequest(metadata, payloadMap)
+  return w.EtherPortalDepositContext(req.Context(), req)
+}
+
+func (w *WalletApp) Erc20PortalDeposit(metadata *rollups.Metadata, payloadMap map[string]interface{}) error {
+  req := w.mapRequest(metadata, payloadMap)
+  return w.Erc20PortalDepositContext(req.Context(), req)
+}
+
+func (w *WalletApp) Erc20PortalDepositV2(metadata *rollups.Metadata, payloadMap map[string]interface{}) error {
+  req := w.mapRequest(metadata, payloadMap)
+  return w.Erc20PortalDepositV2Context(req.Context(), req)
+}
+
+func (w *WalletApp) Erc721PortalDeposit(metadata *rollups.Metadata, payloadMap map[string]interface{}) error {
+  req := w.mapRequest(metadata, payloadMap)
+  return w.Erc721PortalDepositContext(req.Context(), req)
+}
+
+func (w *WalletApp) Erc1155SinglePortalDeposit(metadata *rollups.Metadata, payloadMap map[string]interface{}) error {
+  req := w.mapRequest(metadata, payloadMap)
+  return w.Erc1155SinglePortalDepositContext(req.Context(), req)
+}
+
+func (w *WalletApp) Erc1155BatchPortalDeposit(metadata *rollups.Metadata, payloadMap map[string]interface{}) error {
+  req := w.mapRequest(metadata, payloadMap)
+  return w.Erc1155BatchPortalDepositContext(req.Context(), req)
+}
+
+func (w *WalletApp) EtherWithdraw(metadata *rollups.Metadata, payloadMap map[string]interface{}) error {
+  req := w.mapRequest(metadata, payloadMap)
+  return w.EtherWithdrawContext(req.Context(), req)
+}
+
+func (w *WalletApp) Erc20Withdraw(metadata *rollups.Metadata, payloadMap map[string]interface{}) error {
+  req := w.mapRequest(metadata, payloadMap)
+  return w.Erc20WithdrawContext(req.Context(), req)
+}
+
+func (w *WalletApp) Erc721Withdraw(metadata *rollups.Metadata, payloadMap map[string]interface{}) error {
+  req := w.mapRequest(metadata, payloadMap)
+  return w.Erc721WithdrawContext(req.Context(), req)
+}
+
+func (w *WalletApp) Erc1155SingleWithdraw(metadata *rollups.Metadata, payloadMap map[string]interface{}) error {
+  req := w.mapRequest(metadata, payloadMap)
+  return w.Erc1155SingleWithdrawContext(req.Context(), req)
+}
+
+func (w *WalletApp) Erc1155BatchWithdraw(metadata *rollups.Metadata, payloadMap map[string]interface{}) error {
+  req := w.mapRequest(metadata, payloadMap)
+  return w.Erc1155BatchWithdrawContext(req.Context(), req)
+}
+
+func (w *WalletApp) TransferEtherCodec(metadata *rollups.Metadata, payloadMap map[string]interface{}) error {
+  req := w.mapRequest(metadata, payloadMap)
+  return w.TransferEtherCodecContext(req.Context(), req)
+}
+
+func (w *WalletApp) TransferErc20Codec(metadata *rollups.Metadata, payloadMap map[string]interface{}) error {
+  req := w.mapRequest(metadata, payloadMap)
+  return w.TransferErc20CodecContext(req.Context(), req)
+}
+
+func (w *WalletApp) TransferErc721Codec(metadata *rollups.Metadata, payloadMap map[string]interface{}) error {
+  req := w.mapRequest(metadata, payloadMap)
+  return w.TransferErc721CodecContext(req.Context(), req)
+}
+
+func (w *WalletApp) TransferErc1155SingleCodec(metadata *rollups.Metadata, payloadMap map[string]interface{}) error {
+  req := w.mapRequest(metadata, payloadMap)
+  return w.TransferErc1155SingleCodecContext(req.Context(), req)
+}
+
+func (w *WalletApp) TransferErc1155BatchCodec(metadata *rollups.Metadata, payloadMap map[string]interface{}) error {
+  req := w.mapRequest(metadata, payloadMap)
+  return w.TransferErc1155BatchCodecContext(req.Context(), req)
+}
+
+func (w *WalletApp) BalanceAbi(payloadMap map[string]interface{}) error {
+  req := w.mapRequest(nil, payloadMap)
+  return w.BalanceAbiContext(req.Context(), req)
+}
+
+func (w *WalletApp) BalanceUri(payloadMap map[string]interface{}) error {
+  req := w.mapRequest(nil, payloadMap)
+  return w.BalanceUriContext(req.Context(), req)
+}
+
+//
+// Relay
+//
+
+func (w *WalletApp) HandleRelayContext(ctx context.Context, req *hdl.Request) error {
+  var args RelayArgs
+  if err := abihandler.DecodeParams(req.Params, &args); err != nil {
+    return fmt.Errorf("HandleRelay: parameters error: %s", err)
   }
+  addr := args.DappAddress
 
   w.DappAddress = addr
 
-  if w.handler.LogLevel >= hdl.Debug {DebugLogger.Println("Dapp Relay, dapp address is", addr)}
+  req.Logger().Debug("Dapp Relay", "dapp_address", addr)
 
   return nil
 }
 
+// appAddress returns the application address from the metadata (v2) or the
+// one received from the dapp address relay (v1)
+func (w *WalletApp) appAddress(metadata *rollups.Metadata) (abihandler.Address, error) {
+  if metadata != nil && metadata.AppContract != "" {
+    return abihandler.Hex2Address(metadata.AppContract)
+  }
+  if w.DappAddress == (abihandler.Address{}) {
+    return abihandler.Address{}, fmt.Errorf("no dapp address configured")
+  }
+  return w.DappAddress, nil
+}
+
 //
 // Deposit
 //
 
-func (w *WalletApp) EtherPortalDeposit(metadata *rollups.Metadata, payloadMap map[string]interface{}) error {
-  depositor, ok1 := payloadMap["0"].(abihandler.Address)
-  amount, ok2 := payloadMap["1"].(*big.Int)
-  // dataBytes, ok3 := payloadMap["2"].([]byte)
-
-  if !ok1 || !ok2 {
-    message := "EtherPortalDeposit: parameters error"
-    return fmt.Errorf(message)
+func (w *WalletApp) EtherPortalDepositContext(ctx context.Context, req *hdl.Request) error {
+  var args EtherDepositArgs
+  if err := abihandler.DecodeParams(req.Params, &args); err != nil {
+    return fmt.Errorf("EtherPortalDeposit: parameters error: %s", err)
   }
+  depositor, amount := args.Depositor, args.Amount
 
   wallet := w.GetWallet(depositor)
 
@@ -439,30 +779,37 @@ func (w *WalletApp) EtherPortalDeposit(metadata *rollups.Metadata, payloadMap ma
   if err != nil {
     return fmt.Errorf("EtherPortalDeposit: encoding notice: %s", err)
   }
-  notice := rollups.Notice{Payload:noticePayload}
-  _, err = rollups.SendNotice(&notice)
+  _, err = req.Notice(noticePayload)
   if err != nil {
     return fmt.Errorf("EtherPortalDeposit: error making http request: %s", err)
   }
 
-  if w.handler.LogLevel >= hdl.Debug {DebugLogger.Println("Received",amount,"native token deposit from",depositor)}
+  req.Logger().Debug("Received ether deposit", "depositor", depositor, "amount", amount)
   
   return nil
 }
 
-func (w *WalletApp) Erc20PortalDeposit(metadata *rollups.Metadata, payloadMap map[string]interface{}) error {
+func (w *WalletApp) Erc20PortalDepositContext(ctx context.Context, req *hdl.Request) error {
+  var args Erc20DepositArgs
+  if err := abihandler.DecodeParams(req.Params, &args); err != nil {
+    return fmt.Errorf("Erc20PortalDeposit: parameters error: %s", err)
+  }
+  tokenAddress, depositor, amount := args.Token, args.Depositor, args.Amount
 
-  // ret, ok1 := payloadMap["0"].(bool)
-  tokenAddress, ok2 := payloadMap["1"].(abihandler.Address)
-  depositor, ok3 := payloadMap["2"].(abihandler.Address)
-  amount, ok4 := payloadMap["3"].(*big.Int)
-  // dataBytes, ok5 := payloadMap["4"].([]byte)
+  return w.depositErc20(req, tokenAddress, depositor, amount)
+}
 
-  if !ok2 || !ok3 || !ok4 {
-    message := "Erc20PortalDeposit: parameters error"
-    return fmt.Errorf(message)
+func (w *WalletApp) Erc20PortalDepositV2Context(ctx context.Context, req *hdl.Request) error {
+  var args Erc20DepositV2Args
+  if err := abihandler.DecodeParams(req.Params, &args); err != nil {
+    return fmt.Errorf("Erc20PortalDepositV2: parameters error: %s", err)
   }
+  tokenAddress, depositor, amount := args.Token, args.Depositor, args.Amount
 
+  return w.depositErc20(req, tokenAddress, depositor, amount)
+}
+
+func (w *WalletApp) depositErc20(req *hdl.Request, tokenAddress abihandler.Address, depositor abihandler.Address, amount *big.Int) error {
   wallet := w.GetWallet(depositor)
 
   // Deposit
@@ -476,26 +823,22 @@ func (w *WalletApp) Erc20PortalDeposit(metadata *rollups.Metadata, payloadMap ma
   if err != nil {
     return fmt.Errorf("Erc20Withdraw: encoding notice: %s", err)
   }
-  _, err = w.handler.SendNotice(noticePayload)
+  _, err = req.Notice(noticePayload)
   if err != nil {
     return fmt.Errorf("Erc20Withdraw: error making http request: %s", err)
   }
 
-  if w.handler.LogLevel >= hdl.Debug {DebugLogger.Println("Received",amount,"tokens",tokenAddress,"Erc20 deposit from",depositor)}
+  req.Logger().Debug("Received Erc20 deposit", "depositor", depositor, "token", tokenAddress, "amount", amount)
 
   return nil
 }
 
-func (w *WalletApp) Erc721PortalDeposit(metadata *rollups.Metadata, payloadMap map[string]interface{}) error {
-  tokenAddress, ok1 := payloadMap["0"].(abihandler.Address)
-  depositor, ok2 := payloadMap["1"].(abihandler.Address)
-  tokenId, ok3 := payloadMap["2"].(*big.Int)
-  // dataBytes, ok4 := payloadMap["3"].([]byte)
-
-  if !ok1 || !ok2 || !ok3 {
-    message := "Erc721PortalDeposit: parameters error"
-    return fmt.Errorf(message)
+func (w *WalletApp) Erc721PortalDepositContext(ctx context.Context, req *hdl.Request) error {
+  var args Erc721DepositArgs
+  if err := abihandler.DecodeParams(req.Params, &args); err != nil {
+    return fmt.Errorf("Erc721PortalDeposit: parameters error: %s", err)
   }
+  tokenAddress, depositor, tokenId := args.Token, args.Depositor, args.TokenId
 
   wallet := w.GetWallet(depositor)
 
@@ -510,27 +853,22 @@ func (w *WalletApp) Erc721PortalDeposit(metadata *rollups.Metadata, payloadMap m
   if err != nil {
     return fmt.Errorf("Erc721PortalDeposit: encoding notice: %s", err)
   }
-  _, err = w.handler.SendNotice(noticePayload)
+  _, err = req.Notice(noticePayload)
   if err != nil {
     return fmt.Errorf("Erc721PortalDeposit: error making http request: %s", err)
   }
 
-  if w.handler.LogLevel >= hdl.Debug {DebugLogger.Println("Received id",tokenId,tokenAddress,"Erc721 deposit from",depositor)}
+  req.Logger().Debug("Received Erc721 deposit", "depositor", depositor, "token", tokenAddress, "id", tokenId)
 
   return nil
 }
 
-func (w *WalletApp) Erc1155SinglePortalDeposit(metadata *rollups.Metadata, payloadMap map[string]interface{}) error {
-  tokenAddress, ok1 := payloadMap["0"].(abihandler.Address)
-  depositor, ok2 := payloadMap["1"].(abihandler.Address)
-  tokenId, ok3 := payloadMap["2"].(*big.Int)
-  amount, ok4 := payloadMap["3"].(*big.Int)
-  // dataBytes, ok4 := payloadMap["3"].([]byte)
-
-  if !ok1 || !ok2 || !ok3 || !ok4 {
-    message := "Erc1155SinglePortalDeposit: parameters error"
-    return fmt.Errorf(message)
+func (w *WalletApp) Erc1155SinglePortalDepositContext(ctx context.Context, req *hdl.Request) error {
+  var args Erc1155SingleDepositArgs
+  if err := abihandler.DecodeParams(req.Params, &args); err != nil {
+    return fmt.Errorf("Erc1155SinglePortalDeposit: parameters error: %s", err)
   }
+  tokenAddress, depositor, tokenId, amount := args.Token, args.Depositor, args.TokenId, args.Amount
 
   wallet := w.GetWallet(depositor)
 
@@ -546,25 +884,22 @@ func (w *WalletApp) Erc1155SinglePortalDeposit(metadata *rollups.Metadata, paylo
   if err != nil {
     return fmt.Errorf("Erc1155SinglePortalDeposit: encoding notice: %s", err)
   }
-  _, err = w.handler.SendNotice(noticePayload)
+  _, err = req.Notice(noticePayload)
   if err != nil {
     return fmt.Errorf("Erc1155SinglePortalDeposit: error making http request: %s", err)
   }
 
-  if w.handler.LogLevel >= hdl.Debug {DebugLogger.Println("Received",amount,"tokens from id",tokenId,tokenAddress,"Erc1155 deposit from",depositor)}
+  req.Logger().Debug("Received Erc1155 deposit", "depositor", depositor, "token", tokenAddress, "id", tokenId, "amount", amount)
 
   return nil
 }
 
-func (w *WalletApp) Erc1155BatchPortalDeposit(metadata *rollups.Metadata, payloadMap map[string]interface{}) error {
-  tokenAddress, ok1 := payloadMap["0"].(abihandler.Address)
-  depositor, ok2 := payloadMap["1"].(abihandler.Address)
-  valueBytes, ok3 := payloadMap["2"].([]byte)
-
-  if !ok1 || !ok2 || !ok3 {
-    message := "Erc1155BatchPortalDeposit: parameters error"
-    return fmt.Errorf(message)
+func (w *WalletApp) Erc1155BatchPortalDepositContext(ctx context.Context, req *hdl.Request) error {
+  var args Erc1155BatchDepositArgs
+  if err := abihandler.DecodeParams(req.Params, &args); err != nil {
+    return fmt.Errorf("Erc1155BatchPortalDeposit: parameters error: %s", err)
   }
+  tokenAddress, depositor, valueBytes := args.Token, args.Depositor, args.Data
 
   valueMap, err := erc1155BatchValueCodec.Decode(rollups.Bin2Hex(valueBytes))
   if err != nil {
@@ -600,12 +935,12 @@ func (w *WalletApp) Erc1155BatchPortalDeposit(metadata *rollups.Metadata, payloa
   if err != nil {
     return fmt.Errorf("Erc1155BatchPortalDeposit: encoding notice: %s", err)
   }
-  _, err = w.handler.SendNotice(noticePayload)
+  _, err = req.Notice(noticePayload)
   if err != nil {
     return fmt.Errorf("Erc1155BatchPortalDeposit: error making http request: %s", err)
   }
 
-  if w.handler.LogLevel >= hdl.Debug {DebugLogger.Println("Received",amounts,"tokens from ids",tokenIds,tokenAddress,"Erc1155 deposit from",depositor)}
+  req.Logger().Debug("Received Erc1155 batch deposit", "depositor", depositor, "token", tokenAddress, "ids", tokenIds, "amounts", amounts)
 
   return nil
 }
@@ -614,19 +949,19 @@ func (w *WalletApp) Erc1155BatchPortalDeposit(metadata *rollups.Metadata, payloa
 // Withdraw
 //
 
-func (w *WalletApp) EtherWithdraw(metadata *rollups.Metadata, payloadMap map[string]interface{}) error {
-  if w.DappAddress == (abihandler.Address{}) {
-    return fmt.Errorf("EtherWithdraw: Can not generate voucher as there is no dapp address configured")
+func (w *WalletApp) EtherWithdrawContext(ctx context.Context, req *hdl.Request) error {
+  metadata, payloadMap := req.Metadata, req.Params
+  dappAddress, err := w.appAddress(metadata)
+  if err != nil {
+    return fmt.Errorf("EtherWithdraw: Can not generate voucher: %s", err)
   }
 
-  if w.handler.LogLevel >= hdl.Debug {DebugLogger.Println("EtherWithdraw: payload:",payloadMap)}
-  amount, ok1 := payloadMap["0"].(*big.Int)
-  dataBytes, ok2 := payloadMap["1"].([]byte)
-
-  if !ok1 || !ok2 {
-    message := "EtherWithdraw: parameters error"
-    return fmt.Errorf(message)
+  req.Logger().Debug("Withdraw request", "params", payloadMap)
+  var args EtherWithdrawArgs
+  if err := abihandler.DecodeParams(req.Params, &args); err != nil {
+    return fmt.Errorf("EtherWithdraw: parameters error: %s", err)
   }
+  amount, dataBytes := args.Amount, args.Data
 
   addr,err := abihandler.Hex2Address(metadata.MsgSender)
   if err != nil {
@@ -642,13 +977,19 @@ func (w *WalletApp) EtherWithdraw(metadata *rollups.Metadata, payloadMap map[str
   }
 
   // Voucher
-  voucherPayload,err := etherVoucherCodec.Encode([]interface{}{addr,amount})
-  if err != nil {
-    return fmt.Errorf("EtherWithdraw: encoding voucher: %s", err)
+  if w.Handler().IsV2() {
+    // v2 applications hold the ether, so the voucher just sends it to the user
+    _, err = req.PayableVoucher(addr.String(),amount,"0x")
+  } else {
+    var voucherPayload string
+    voucherPayload,err = etherVoucherCodec.Encode([]interface{}{addr,amount})
+    if err != nil {
+      return fmt.Errorf("EtherWithdraw: encoding voucher: %s", err)
+    }
+    _, err = req.Voucher(dappAddress.String(),voucherPayload)
   }
-  _, err = w.handler.SendVoucher(w.DappAddress.String(),voucherPayload)
   if err != nil {
-    return fmt.Errorf("EtherPortalDeposit: error making http request: %s", err)
+    return fmt.Errorf("EtherWithdraw: error making http request: %s", err)
   }
 
   // Notice
@@ -656,26 +997,24 @@ func (w *WalletApp) EtherWithdraw(metadata *rollups.Metadata, payloadMap map[str
   if err != nil {
     return fmt.Errorf("EtherWithdraw: encoding notice: %s", err)
   }
-  _, err = w.handler.SendNotice(noticePayload)
+  _, err = req.Notice(noticePayload)
   if err != nil {
     return fmt.Errorf("EtherWithdraw: error making http request: %s", err)
   }
 
-  if w.handler.LogLevel >= hdl.Debug {DebugLogger.Println("Withdrawn",amount,"ETH from",addr,"data:",dataBytes)}
+  req.Logger().Debug("Withdrawn ether", "address", addr, "amount", amount, "data", dataBytes)
 
   return nil
 }
 
-func (w *WalletApp) Erc20Withdraw(metadata *rollups.Metadata, payloadMap map[string]interface{}) error {
-  if w.handler.LogLevel >= hdl.Debug {DebugLogger.Println("EtherWithdraw: payload:",payloadMap)}
-  tokenAddress, ok1 := payloadMap["0"].(abihandler.Address)
-  amount, ok2 := payloadMap["1"].(*big.Int)
-  dataBytes, ok3 := payloadMap["2"].([]byte)
-
-  if !ok1 || !ok2 || !ok3 {
-    message := "Erc20Withdraw: parameters error"
-    return fmt.Errorf(message)
+func (w *WalletApp) Erc20WithdrawContext(ctx context.Context, req *hdl.Request) error {
+  metadata, payloadMap := req.Metadata, req.Params
+  req.Logger().Debug("Withdraw request", "params", payloadMap)
+  var args Erc20WithdrawArgs
+  if err := abihandler.DecodeParams(req.Params, &args); err != nil {
+    return fmt.Errorf("Erc20Withdraw: parameters error: %s", err)
   }
+  tokenAddress, amount, dataBytes := args.Token, args.Amount, args.Data
 
   addr,err := abihandler.Hex2Address(metadata.MsgSender)
   if err != nil {
@@ -695,7 +1034,7 @@ func (w *WalletApp) Erc20Withdraw(metadata *rollups.Metadata, payloadMap map[str
   if err != nil {
     return fmt.Errorf("Erc20Withdraw: encoding voucher: %s", err)
   }
-  _, err = w.handler.SendVoucher(tokenAddress.String(),voucherPayload)
+  _, err = req.Voucher(tokenAddress.String(),voucherPayload)
   if err != nil {
     return fmt.Errorf("Erc20Withdraw: error making http request: %s", err)
   }
@@ -705,31 +1044,30 @@ func (w *WalletApp) Erc20Withdraw(metadata *rollups.Metadata, payloadMap map[str
   if err != nil {
     return fmt.Errorf("Erc20Withdraw: encoding notice: %s", err)
   }
-  _, err = w.handler.SendNotice(noticePayload)
+  _, err = req.Notice(noticePayload)
   if err != nil {
     return fmt.Errorf("Erc20Withdraw: error making http request: %s", err)
   }
 
-  if w.handler.LogLevel >= hdl.Debug {DebugLogger.Println("Withdrawn",amount,"of Erc20",tokenAddress,"from",addr,"data:",dataBytes)}
+  req.Logger().Debug("Withdrawn Erc20", "address", addr, "token", tokenAddress, "amount", amount, "data", dataBytes)
 
   return nil
 }
 
-func (w *WalletApp) Erc721Withdraw(metadata *rollups.Metadata, payloadMap map[string]interface{}) error {
-  if w.handler.LogLevel >= hdl.Debug {DebugLogger.Println("Erc721Withdraw: payload:",payloadMap)}
+func (w *WalletApp) Erc721WithdrawContext(ctx context.Context, req *hdl.Request) error {
+  metadata, payloadMap := req.Metadata, req.Params
+  req.Logger().Debug("Withdraw request", "params", payloadMap)
 
-  if w.DappAddress == (abihandler.Address{}) {
-    return fmt.Errorf("Erc721Withdraw: Can not generate voucher as there is no dapp address configured")
+  dappAddress, err := w.appAddress(metadata)
+  if err != nil {
+    return fmt.Errorf("Erc721Withdraw: Can not generate voucher: %s", err)
   }
 
-  tokenAddress, ok1 := payloadMap["0"].(abihandler.Address)
-  tokenId, ok2 := payloadMap["1"].(*big.Int)
-  dataBytes, ok3 := payloadMap["2"].([]byte)
-
-  if !ok1 || !ok2 || !ok3 {
-    message := "Erc721Withdraw: parameters error"
-    return fmt.Errorf(message)
+  var args Erc721WithdrawArgs
+  if err := abihandler.DecodeParams(req.Params, &args); err != nil {
+    return fmt.Errorf("Erc721Withdraw: parameters error: %s", err)
   }
+  tokenAddress, tokenId, dataBytes := args.Token, args.TokenId, args.Data
 
   addr,err := abihandler.Hex2Address(metadata.MsgSender)
   if err != nil {
@@ -745,11 +1083,11 @@ func (w *WalletApp) Erc721Withdraw(metadata *rollups.Metadata, payloadMap map[st
   }
     
   // Voucher
-  voucherPayload,err := erc721VoucherCodec.Encode([]interface{}{w.DappAddress,addr,tokenId})
+  voucherPayload,err := erc721VoucherCodec.Encode([]interface{}{dappAddress,addr,tokenId})
   if err != nil {
     return fmt.Errorf("Erc721Withdraw: encoding voucher: %s", err)
   }
-  _, err = w.handler.SendVoucher(tokenAddress.String(),voucherPayload)
+  _, err = req.Voucher(tokenAddress.String(),voucherPayload)
   if err != nil {
     return fmt.Errorf("Erc721Withdraw: error making http request: %s", err)
   }
@@ -759,32 +1097,30 @@ func (w *WalletApp) Erc721Withdraw(metadata *rollups.Metadata, payloadMap map[st
   if err != nil {
     return fmt.Errorf("Erc721Withdraw: encoding notice: %s", err)
   }
-  _, err = w.handler.SendNotice(noticePayload)
+  _, err = req.Notice(noticePayload)
   if err != nil {
     return fmt.Errorf("Erc721Withdraw: error making http request: %s", err)
   }
 
-  if w.handler.LogLevel >= hdl.Debug {DebugLogger.Println("Withdrawn id",tokenId,"of Erc721",tokenAddress,"from",addr,"data:",dataBytes)}
+  req.Logger().Debug("Withdrawn Erc721", "address", addr, "token", tokenAddress, "id", tokenId, "data", dataBytes)
 
   return nil
 }
 
-func (w *WalletApp) Erc1155SingleWithdraw(metadata *rollups.Metadata, payloadMap map[string]interface{}) error {
-  if w.handler.LogLevel >= hdl.Debug {DebugLogger.Println("Erc1155SingleWithdraw: payload:",payloadMap)}
+func (w *WalletApp) Erc1155SingleWithdrawContext(ctx context.Context, req *hdl.Request) error {
+  metadata, payloadMap := req.Metadata, req.Params
+  req.Logger().Debug("Withdraw request", "params", payloadMap)
 
-  if w.DappAddress == (abihandler.Address{}) {
-    return fmt.Errorf("Erc1155SingleWithdraw: Can not generate voucher as there is no dapp address configured")
+  dappAddress, err := w.appAddress(metadata)
+  if err != nil {
+    return fmt.Errorf("Erc1155SingleWithdraw: Can not generate voucher: %s", err)
   }
 
-  tokenAddress, ok1 := payloadMap["0"].(abihandler.Address)
-  tokenId, ok2 := payloadMap["1"].(*big.Int)
-  amount, ok3 := payloadMap["2"].(*big.Int)
-  dataBytes, ok4 := payloadMap["3"].([]byte)
-
-  if !ok1 || !ok2 || !ok3 || !ok4 {
-    message := "Erc1155SingleWithdraw: parameters error"
-    return fmt.Errorf(message)
+  var args Erc1155SingleWithdrawArgs
+  if err := abihandler.DecodeParams(req.Params, &args); err != nil {
+    return fmt.Errorf("Erc1155SingleWithdraw: parameters error: %s", err)
   }
+  tokenAddress, tokenId, amount, dataBytes := args.Token, args.TokenId, args.Amount, args.Data
 
   addr,err := abihandler.Hex2Address(metadata.MsgSender)
   if err != nil {
@@ -800,11 +1136,11 @@ func (w *WalletApp) Erc1155SingleWithdraw(metadata *rollups.Metadata, payloadMap
   }
 
   // Voucher
-  voucherPayload,err := erc1155SingleVoucherCodec.Encode([]interface{}{w.DappAddress,addr,tokenId,amount,[]byte{}})
+  voucherPayload,err := erc1155SingleVoucherCodec.Encode([]interface{}{dappAddress,addr,tokenId,amount,[]byte{}})
   if err != nil {
     return fmt.Errorf("Erc1155SingleWithdraw: encoding voucher: %s", err)
   }
-  _, err = w.handler.SendVoucher(tokenAddress.String(),voucherPayload)
+  _, err = req.Voucher(tokenAddress.String(),voucherPayload)
   if err != nil {
     return fmt.Errorf("Erc1155SingleWithdraw: error making http request: %s", err)
   }
@@ -815,32 +1151,30 @@ func (w *WalletApp) Erc1155SingleWithdraw(metadata *rollups.Metadata, payloadMap
   if err != nil {
     return fmt.Errorf("Erc1155SingleWithdraw: encoding notice: %s", err)
   }
-  _, err = w.handler.SendNotice(noticePayload)
+  _, err = req.Notice(noticePayload)
   if err != nil {
     return fmt.Errorf("Erc1155SingleWithdraw: error making http request: %s", err)
   }
 
-  if w.handler.LogLevel >= hdl.Debug {DebugLogger.Println("Withdrawn",amount,"tokens of id",tokenId,"of Erc1155",tokenAddress,"from",addr,"data:",dataBytes)}
+  req.Logger().Debug("Withdrawn Erc1155", "address", addr, "token", tokenAddress, "id", tokenId, "amount", amount, "data", dataBytes)
 
   return nil
 }
 
-func (w *WalletApp) Erc1155BatchWithdraw(metadata *rollups.Metadata, payloadMap map[string]interface{}) error {
-  if w.handler.LogLevel >= hdl.Debug {DebugLogger.Println("Erc1155SingleWithdraw: payload:",payloadMap)}
+func (w *WalletApp) Erc1155BatchWithdrawContext(ctx context.Context, req *hdl.Request) error {
+  metadata, payloadMap := req.Metadata, req.Params
+  req.Logger().Debug("Withdraw request", "params", payloadMap)
 
-  if w.DappAddress == (abihandler.Address{}) {
-    return fmt.Errorf("Erc1155BatchWithdraw: Can not generate voucher as there is no dapp address configured")
+  dappAddress, err := w.appAddress(metadata)
+  if err != nil {
+    return fmt.Errorf("Erc1155BatchWithdraw: Can not generate voucher: %s", err)
   }
 
-  tokenAddress, ok1 := payloadMap["0"].(abihandler.Address)
-  tokenIds, ok2 := payloadMap["1"].([]*big.Int)
-  amounts, ok3 := payloadMap["2"].([]*big.Int)
-  dataBytes, ok4 := payloadMap["3"].([]byte)
-
-  if !ok1 || !ok2 || !ok3 || !ok4 {
-    message := "Erc1155BatchWithdraw: parameters error"
-    return fmt.Errorf(message)
+  var args Erc1155BatchWithdrawArgs
+  if err := abihandler.DecodeParams(req.Params, &args); err != nil {
+    return fmt.Errorf("Erc1155BatchWithdraw: parameters error: %s", err)
   }
+  tokenAddress, tokenIds, amounts, dataBytes := args.Token, args.TokenIds, args.Amounts, args.Data
 
   if len(tokenIds) != len(amounts) {
     message := "Erc1155BatchPortalDeposit: parameters error"
@@ -868,11 +1202,11 @@ func (w *WalletApp) Erc1155BatchWithdraw(metadata *rollups.Metadata, payloadMap
   }
 
   // Voucher
-  voucherPayload,err := erc1155BatchVoucherCodec.Encode([]interface{}{w.DappAddress,addr,tokenIds,amounts,[]byte{}})
+  voucherPayload,err := erc1155BatchVoucherCodec.Encode([]interface{}{dappAddress,addr,tokenIds,amounts,[]byte{}})
   if err != nil {
     return fmt.Errorf("Erc1155BatchWithdraw: encoding voucher: %s", err)
   }
-  _, err = w.handler.SendVoucher(tokenAddress.String(),voucherPayload)
+  _, err = req.Voucher(tokenAddress.String(),voucherPayload)
   if err != nil {
     return fmt.Errorf("Erc1155BatchWithdraw: error making http request: %s", err)
   }
@@ -883,12 +1217,12 @@ func (w *WalletApp) Erc1155BatchWithdraw(metadata *rollups.Metadata, payloadMap
   if err != nil {
     return fmt.Errorf("Erc1155BatchWithdraw: encoding notice: %s", err)
   }
-  _, err = w.handler.SendNotice(noticePayload)
+  _, err = req.Notice(noticePayload)
   if err != nil {
     return fmt.Errorf("Erc1155BatchWithdraw: error making http request: %s", err)
   }
 
-  if w.handler.LogLevel >= hdl.Debug {DebugLogger.Println("Withdrawn",amounts,"tokens of ids",tokenIds,"of Erc1155",tokenAddress,"from",addr,"data:",dataBytes)}
+  req.Logger().Debug("Withdrawn Erc1155 batch", "address", addr, "token", tokenAddress, "ids", tokenIds, "amounts", amounts, "data", dataBytes)
 
   return nil
 }
@@ -897,25 +1231,30 @@ func (w *WalletApp) Erc1155BatchWithdraw(metadata *rollups.Metadata, payloadMap
 // Transfer
 //
 
-func (w *WalletApp) TransferEtherCodec(metadata *rollups.Metadata, payloadMap map[string]interface{}) error {
-  receiver, ok1 := payloadMap["0"].(abihandler.Address)
-  amount, ok2 := payloadMap["1"].(*big.Int)
-  // dataBytes, ok3 := payloadMap["2"].([]byte)
-  if !ok1 || !ok2 {
-    message := "TransferEtherCodec: parameters error"
-    return fmt.Errorf(message)
+func (w *WalletApp) TransferEtherCodecContext(ctx context.Context, req *hdl.Request) error {
+  metadata := req.Metadata
+  var args EtherTransferArgs
+  if err := abihandler.DecodeParams(req.Params, &args); err != nil {
+    return fmt.Errorf("TransferEtherCodec: parameters error: %s", err)
   }
+  receiver, amount := args.Receiver, args.Amount
 
   sender,err := abihandler.Hex2Address(metadata.MsgSender)
   if err != nil {
     return fmt.Errorf("TransferEtherCodec: error converting address: %s", err)
   }
 
-  return w.TransferEther(sender,receiver,amount)
+  return w.transferEther(req,sender,receiver,amount)
 }
 
+// TransferEther moves the funds and sends the notices of the transfer as outputs of
+// the input being processed
 func (w *WalletApp) TransferEther(sender abihandler.Address, receiver abihandler.Address, amount *big.Int) error {
+  req := w.mapRequest(nil, nil)
+  return w.transferEther(req,sender,receiver,amount)
+}
 
+func (w *WalletApp) transferEther(req *hdl.Request, sender abihandler.Address, receiver abihandler.Address, amount *big.Int) error {
   walletSender := w.GetWallet(sender)
 
   // Withdrawal
@@ -937,7 +1276,7 @@ func (w *WalletApp) TransferEther(sender abihandler.Address, receiver abihandler
   if err != nil {
     return fmt.Errorf("TransferEther: encoding notice: %s", err)
   }
-  _, err = w.handler.SendNotice(noticePayload)
+  _, err = req.Notice(noticePayload)
   if err != nil {
     return fmt.Errorf("TransferEther: error making http request: %s", err)
   }
@@ -946,36 +1285,38 @@ func (w *WalletApp) TransferEther(sender abihandler.Address, receiver abihandler
   if err != nil {
     return fmt.Errorf("TransferEther: encoding notice: %s", err)
   }
-  _, err = w.handler.SendNotice(noticePayload)
+  _, err = req.Notice(noticePayload)
   if err != nil {
     return fmt.Errorf("TransferEther: error making http request: %s", err)
   }
 
-  if w.handler.LogLevel >= hdl.Debug {DebugLogger.Println("Transfered",amount,"ETH from",sender,"to",receiver)}
+  req.Logger().Debug("Transfered ether", "sender", sender, "receiver", receiver, "amount", amount)
 
   return nil
 }
 
-func (w *WalletApp) TransferErc20Codec(metadata *rollups.Metadata, payloadMap map[string]interface{}) error {
-  tokenAddress, ok1 := payloadMap["0"].(abihandler.Address)
-  receiver, ok2 := payloadMap["1"].(abihandler.Address)
-  amount, ok3 := payloadMap["2"].(*big.Int)
-  // dataBytes, ok3 := payloadMap["2"].([]byte)
-  if !ok1 || !ok2 || !ok3 {
-    message := "TransferErc20Codec: parameters error"
-    return fmt.Errorf(message)
+func (w *WalletApp) TransferErc20CodecContext(ctx context.Context, req *hdl.Request) error {
+  metadata := req.Metadata
+  var args Erc20TransferArgs
+  if err := abihandler.DecodeParams(req.Params, &args); err != nil {
+    return fmt.Errorf("TransferErc20Codec: parameters error: %s", err)
   }
+  tokenAddress, receiver, amount := args.Token, args.Receiver, args.Amount
 
   sender,err := abihandler.Hex2Address(metadata.MsgSender)
   if err != nil {
     return fmt.Errorf("TransferErc20Codec: error converting address: %s", err)
   }
 
-  return w.TransferErc20(tokenAddress,sender,receiver,amount)
+  return w.transferErc20(req,tokenAddress,sender,receiver,amount)
 }
 
 func (w *WalletApp) TransferErc20(tokenAddress abihandler.Address, sender abihandler.Address, receiver abihandler.Address, amount *big.Int) error {
+  req := w.mapRequest(nil, nil)
+  return w.transferErc20(req,tokenAddress,sender,receiver,amount)
+}
 
+func (w *WalletApp) transferErc20(req *hdl.Request, tokenAddress abihandler.Address, sender abihandler.Address, receiver abihandler.Address, amount *big.Int) error {
   walletSender := w.GetWallet(sender)
 
   // Withdrawal
@@ -997,7 +1338,7 @@ func (w *WalletApp) TransferErc20(tokenAddress abihandler.Address, sender abihan
   if err != nil {
     return fmt.Errorf("TransferErc20: encoding notice: %s", err)
   }
-  _, err = w.handler.SendNotice(noticePayload)
+  _, err = req.Notice(noticePayload)
   if err != nil {
     return fmt.Errorf("TransferErc20: error making http request: %s", err)
   }
@@ -1006,36 +1347,38 @@ func (w *WalletApp) TransferErc20(tokenAddress abihandler.Address, sender abihan
   if err != nil {
     return fmt.Errorf("TransferErc20: encoding notice: %s", err)
   }
-  _, err = w.handler.SendNotice(noticePayload)
+  _, err = req.Notice(noticePayload)
   if err != nil {
     return fmt.Errorf("TransferErc20: error making http request: %s", err)
   }
 
-  if w.handler.LogLevel >= hdl.Debug {DebugLogger.Println("Transfered",amount,"Erc20",tokenAddress,"from",sender,"to",receiver)}
+  req.Logger().Debug("Transfered Erc20", "sender", sender, "receiver", receiver, "token", tokenAddress, "amount", amount)
 
   return nil
 }
 
-func (w *WalletApp) TransferErc721Codec(metadata *rollups.Metadata, payloadMap map[string]interface{}) error {
-  tokenAddress, ok1 := payloadMap["0"].(abihandler.Address)
-  receiver, ok2 := payloadMap["1"].(abihandler.Address)
-  tokenId, ok3 := payloadMap["2"].(*big.Int)
-  // dataBytes, ok3 := payloadMap["2"].([]byte)
-  if !ok1 || !ok2 || !ok3 {
-    message := "TransferErc721Codec: parameters error"
-    return fmt.Errorf(message)
+func (w *WalletApp) TransferErc721CodecContext(ctx context.Context, req *hdl.Request) error {
+  metadata := req.Metadata
+  var args Erc721TransferArgs
+  if err := abihandler.DecodeParams(req.Params, &args); err != nil {
+    return fmt.Errorf("TransferErc721Codec: parameters error: %s", err)
   }
+  tokenAddress, receiver, tokenId := args.Token, args.Receiver, args.TokenId
 
   sender,err := abihandler.Hex2Address(metadata.MsgSender)
   if err != nil {
     return fmt.Errorf("TransferErc721Codec: error converting address: %s", err)
   }
 
-  return w.TransferErc721(tokenAddress,sender,receiver,tokenId)
+  return w.transferErc721(req,tokenAddress,sender,receiver,tokenId)
 }
 
 func (w *WalletApp) TransferErc721(tokenAddress abihandler.Address, sender abihandler.Address, receiver abihandler.Address, tokenId *big.Int) error {
+  req := w.mapRequest(nil, nil)
+  return w.transferErc721(req,tokenAddress,sender,receiver,tokenId)
+}
 
+func (w *WalletApp) transferErc721(req *hdl.Request, tokenAddress abihandler.Address, sender abihandler.Address, receiver abihandler.Address, tokenId *big.Int) error {
   walletSender := w.GetWallet(sender)
 
   // Withdrawal
@@ -1057,7 +1400,7 @@ func (w *WalletApp) TransferErc721(tokenAddress abihandler.Address, sender abiha
   if err != nil {
     return fmt.Errorf("TransferErc721: encoding notice: %s", err)
   }
-  _, err = w.handler.SendNotice(noticePayload)
+  _, err = req.Notice(noticePayload)
   if err != nil {
     return fmt.Errorf("TransferErc721: error making http request: %s", err)
   }
@@ -1066,56 +1409,54 @@ func (w *WalletApp) TransferErc721(tokenAddress abihandler.Address, sender abiha
   if err != nil {
     return fmt.Errorf("TransferErc721: encoding notice: %s", err)
   }
-  _, err = w.handler.SendNotice(noticePayload)
+  _, err = req.Notice(noticePayload)
   if err != nil {
     return fmt.Errorf("TransferErc721: error making http request: %s", err)
   }
 
-  if w.handler.LogLevel >= hdl.Debug {DebugLogger.Println("Transfered",tokenId,"Erc721",tokenAddress,"from",sender,"to",receiver)}
+  req.Logger().Debug("Transfered Erc721", "sender", sender, "receiver", receiver, "token", tokenAddress, "id", tokenId)
 
   return nil
 }
 
-func (w *WalletApp) TransferErc1155SingleCodec(metadata *rollups.Metadata, payloadMap map[string]interface{}) error {
-  tokenAddress, ok1 := payloadMap["0"].(abihandler.Address)
-  receiver, ok2 := payloadMap["1"].(abihandler.Address)
-  tokenId, ok3 := payloadMap["2"].(*big.Int)
-  amount, ok4 := payloadMap["3"].(*big.Int)
-  // dataBytes, ok3 := payloadMap["2"].([]byte)
-  if !ok1 || !ok2 || !ok3 || !ok4 {
-    message := "TransferErc1155SingleCodec: parameters error"
-    return fmt.Errorf(message)
+func (w *WalletApp) TransferErc1155SingleCodecContext(ctx context.Context, req *hdl.Request) error {
+  metadata := req.Metadata
+  var args Erc1155SingleTransferArgs
+  if err := abihandler.DecodeParams(req.Params, &args); err != nil {
+    return fmt.Errorf("TransferErc1155SingleCodec: parameters error: %s", err)
   }
+  tokenAddress, receiver, tokenId, amount := args.Token, args.Receiver, args.TokenId, args.Amount
 
   sender,err := abihandler.Hex2Address(metadata.MsgSender)
   if err != nil {
     return fmt.Errorf("TransferErc1155SingleCodec: error converting address: %s", err)
   }
 
-  return w.TransferErc1155Batch(tokenAddress,sender,receiver,[]*big.Int{tokenId},[]*big.Int{amount})
+  return w.transferErc1155Batch(req,tokenAddress,sender,receiver,[]*big.Int{tokenId},[]*big.Int{amount})
 }
 
-func (w *WalletApp) TransferErc1155BatchCodec(metadata *rollups.Metadata, payloadMap map[string]interface{}) error {
-  tokenAddress, ok1 := payloadMap["0"].(abihandler.Address)
-  receiver, ok2 := payloadMap["1"].(abihandler.Address)
-  tokenIds, ok3 := payloadMap["2"].([]*big.Int)
-  amounts, ok4 := payloadMap["3"].([]*big.Int)
-  // dataBytes, ok3 := payloadMap["2"].([]byte)
-  if !ok1 || !ok2 || !ok3 || !ok4 {
-    message := "TransferErc1155BatchCodec: parameters error"
-    return fmt.Errorf(message)
+func (w *WalletApp) TransferErc1155BatchCodecContext(ctx context.Context, req *hdl.Request) error {
+  metadata := req.Metadata
+  var args Erc1155BatchTransferArgs
+  if err := abihandler.DecodeParams(req.Params, &args); err != nil {
+    return fmt.Errorf("TransferErc1155BatchCodec: parameters error: %s", err)
   }
+  tokenAddress, receiver, tokenIds, amounts := args.Token, args.Receiver, args.TokenIds, args.Amounts
 
   sender,err := abihandler.Hex2Address(metadata.MsgSender)
   if err != nil {
     return fmt.Errorf("TransferErc1155BatchCodec: error converting address: %s", err)
   }
 
-  return w.TransferErc1155Batch(tokenAddress,sender,receiver,tokenIds,amounts)
+  return w.transferErc1155Batch(req,tokenAddress,sender,receiver,tokenIds,amounts)
 }
 
 func (w *WalletApp) TransferErc1155Batch(tokenAddress abihandler.Address, sender abihandler.Address, receiver abihandler.Address, tokenIds []*big.Int, amounts []*big.Int) error {
+  req := w.mapRequest(nil, nil)
+  return w.transferErc1155Batch(req,tokenAddress,sender,receiver,tokenIds,amounts)
+}
 
+func (w *WalletApp) transferErc1155Batch(req *hdl.Request, tokenAddress abihandler.Address, sender abihandler.Address, receiver abihandler.Address, tokenIds []*big.Int, amounts []*big.Int) error {
   numTokens := len(tokenIds)
 
   // Withdrawal
@@ -1146,7 +1487,7 @@ func (w *WalletApp) TransferErc1155Batch(tokenAddress abihandler.Address, sender
   if err != nil {
     return fmt.Errorf("TransferErc1155: encoding notice: %s", err)
   }
-  _, err = w.handler.SendNotice(noticePayload)
+  _, err = req.Notice(noticePayload)
   if err != nil {
     return fmt.Errorf("TransferErc1155: error making http request: %s", err)
   }
@@ -1156,12 +1497,12 @@ func (w *WalletApp) TransferErc1155Batch(tokenAddress abihandler.Address, sender
   if err != nil {
     return fmt.Errorf("TransferErc1155: encoding notice: %s", err)
   }
-  _, err = w.handler.SendNotice(noticePayload)
+  _, err = req.Notice(noticePayload)
   if err != nil {
     return fmt.Errorf("TransferErc1155: error making http request: %s", err)
   }
 
-  if w.handler.LogLevel >= hdl.Debug {DebugLogger.Println("Transfered",amounts,"tokens with ids",tokenIds,"Erc155",tokenAddress,"from",sender,"to",receiver)}
+  req.Logger().Debug("Transfered Erc1155", "sender", sender, "receiver", receiver, "token", tokenAddress, "ids", tokenIds, "amounts", amounts)
 
   return nil
 }
@@ -1170,13 +1511,16 @@ func (w *WalletApp) TransferErc1155Batch(tokenAddress abihandler.Address, sender
 // Balance
 //
 
-func (w *WalletApp) BalanceAbi(payloadMap map[string]interface{}) error {
-  addr, ok1 := payloadMap["address"].(abihandler.Address)
-  if !ok1 {
-    message := "Balance: parameters error"
-    return fmt.Errorf("%s",message)
+func (w *WalletApp) BalanceAbiContext(ctx context.Context, req *hdl.Request) error {
+  var args BalanceArgs
+  if err := abihandler.DecodeParams(req.Params, &args); err != nil {
+    return fmt.Errorf("Balance: parameters error: %s", err)
   }
 
+  return w.balance(req, args.Address)
+}
+
+func (w *WalletApp) balance(req *hdl.Request, addr abihandler.Address) error {
   wallet := w.GetWallet(addr)
 
   balanceJson, err := json.Marshal(wallet)
@@ -1184,26 +1528,33 @@ func (w *WalletApp) BalanceAbi(payloadMap map[string]interface{}) error {
     return fmt.Errorf("balance: error converting wallet to json: %s", err)
   }
 
-  err = w.handler.SendReport(rollups.Str2Hex(string(balanceJson)))
+  err = req.Report(rollups.Str2Hex(string(balanceJson)))
   if err != nil {
     return fmt.Errorf("balance: error making http request: %s", err)
   }
 
-  if w.handler.LogLevel >= hdl.Debug {DebugLogger.Println(addr,"balance",string(balanceJson))}
+  req.Logger().Debug("Balance", "address", addr, "balance", string(balanceJson))
 
   return nil
 }
 
-func (w *WalletApp) BalanceUri(payloadMap map[string]interface{}) error {
-  addrStr, ok1 := payloadMap["address"].(string)
-  if !ok1 {
+// BalanceUriContext takes the address as the hex string of the untyped uri
+// param, as before the typed params, or as an address (e.g. the param of a
+// "/balance/:address<address>" route)
+func (w *WalletApp) BalanceUriContext(ctx context.Context, req *hdl.Request) error {
+  var addr abihandler.Address
+  switch value := req.Params["address"].(type) {
+  case abihandler.Address:
+    addr = value
+  case string:
+    var err error
+    addr, err = abihandler.Hex2Address(value)
+    if err != nil {
+      return fmt.Errorf("balance: parameters error: %s", err)
+    }
+  default:
     return fmt.Errorf("balance: parameters error")
   }
 
-  addr,err := abihandler.Hex2Address(addrStr)
-  if err != nil {
-    return fmt.Errorf("balance: parameters error: %s", err)
-  }
-
-  return w.BalanceAbi(map[string]interface{}{"address":addr})
+  return w.balance(req, addr)
 }
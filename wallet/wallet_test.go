@@ -0,0 +1,200 @@
+package wallet_test
+
+import (
+  "context"
+  "fmt"
+  "math/big"
+  "strings"
+  "testing"
+
+  hdl "github.com/prototyp3-dev/go-rollups/handler"
+  "github.com/prototyp3-dev/go-rollups/handler/abi"
+  "github.com/prototyp3-dev/go-rollups/handler/handlertest"
+  "github.com/prototyp3-dev/go-rollups/rollups"
+  "github.com/prototyp3-dev/go-rollups/rollups/rolluptest"
+  "github.com/prototyp3-dev/go-rollups/wallet"
+)
+
+const (
+  etherPortal = "0xffdbe43d4c855bf7e0f105c400a50857f53ab044"
+  relay = "0xf5de34d6bbc0446e2a45719e718efebaae179dae"
+)
+
+var (
+  user = mustAddress("0xf39fd6e51aad88f6f4ce6ab8827279cfffb92266")
+  other = mustAddress("0x70997970c51812dc3a010c7d01b50e0d17dc79c8")
+)
+
+var transferCodec = abihandler.NewHeaderStructCodec("wallet", "EtherTransfer", wallet.EtherTransferArgs{})
+var failCodec = abihandler.NewHeaderCodec("test", "TransferAndFail", []string{})
+
+// newWalletApp has the ether routes and a route that transfers 4 wei from the
+// user to other and rejects the input
+func newWalletApp() (*wallet.WalletApp, *handlertest.Driver) {
+  abiHandler := abihandler.NewAbiHandler()
+  if err := abiHandler.Handler.SetRollupsAddresses(hdl.NetworkAddresses{DappAddressRelay: relay, EtherPortalAddress: etherPortal}); err != nil {
+    panic(err)
+  }
+  app := wallet.NewWalletApp()
+  app.SetAbiHandler(abiHandler)
+  app.SetupRoutes([]wallet.WalletRoute{wallet.EtherCodecAdvanceRoutes})
+  abiHandler.HandleAdvanceRouteContext(failCodec, func(ctx context.Context, req *hdl.Request) error {
+    if err := app.TransferEther(user, other, big.NewInt(4)); err != nil {
+      return err
+    }
+    return fmt.Errorf("fail")
+  })
+  return app, handlertest.NewDriver(abiHandler.Handler)
+}
+
+func deposit(depositor abihandler.Address, amount int64) string {
+  payload, err := abihandler.NewPackedCodec(abihandler.StructFields(wallet.EtherDepositArgs{})).
+    Encode([]interface{}{depositor, big.NewInt(amount), []byte{}})
+  if err != nil {
+    panic(err)
+  }
+  return payload
+}
+
+func encode(codec *abihandler.Codec, values []interface{}) string {
+  payload, err := codec.Encode(values)
+  if err != nil {
+    panic(err)
+  }
+  return payload
+}
+
+// balances returns the ether of the user and other, -1 if there is no wallet
+func balances(app *wallet.WalletApp) [2]int64 {
+  var result [2]int64
+  for i, address := range []abihandler.Address{user, other} {
+    result[i] = -1
+    if w, ok := app.Wallets[address]; ok {
+      result[i] = w.Ether.Int64()
+    }
+  }
+  return result
+}
+
+func TestWalletRollback(t *testing.T) {
+  app, driver := newWalletApp()
+  steps := []struct {
+    name string
+    sender string
+    payload string
+    accepted bool
+    balances [2]int64
+  }{
+    {"deposit", etherPortal, deposit(user, 10), true, [2]int64{10, -1}},
+    // the wallet of other created by the rejected input is removed
+    {"rejected transfer", user.String(), encode(failCodec, []interface{}{}), false, [2]int64{10, -1}},
+    {"transfer", user.String(), encode(transferCodec, []interface{}{other, big.NewInt(3), []byte{}}), true, [2]int64{7, 3}},
+    {"rejected transfer after a transfer", user.String(), encode(failCodec, []interface{}{}), false, [2]int64{7, 3}},
+    {"transfer over the balance", other.String(), encode(transferCodec, []interface{}{user, big.NewInt(5), []byte{}}), false, [2]int64{7, 3}},
+    {"second deposit", etherPortal, deposit(other, 2), true, [2]int64{7, 5}},
+  }
+  for _, step := range steps {
+    result := driver.Advance(step.sender, step.payload)
+    if result.Accepted() != step.accepted {
+      t.Errorf("%s: status %s, expected accepted %t: %v", step.name, result.Status, step.accepted, result.Err)
+    }
+    if got := balances(app); got != step.balances {
+      t.Errorf("%s: balances %v, expected %v", step.name, got, step.balances)
+    }
+  }
+
+  // the wallets got outside an advance aren't saved for a rollback, so the
+  // next rejected advance doesn't restore them
+  app.GetWallet(user).DepositEther(big.NewInt(100))
+  driver.Advance(user.String(), encode(failCodec, []interface{}{}))
+  if got := balances(app); got != [2]int64{107, 5} {
+    t.Errorf("balances %v after a change between inputs, expected [107 5]", got)
+  }
+}
+
+func TestMapHandlers(t *testing.T) {
+  app, driver := newWalletApp()
+  driver.Advance(etherPortal, deposit(other, 10))
+
+  // the route withdraws on behalf of the account with the map handler
+  withdrawForCodec := abihandler.NewHeaderCodec("test", "WithdrawFor", []string{"address account", "uint256 amount"})
+  app.AbiHandler().HandleAdvanceRouteContext(withdrawForCodec, func(ctx context.Context, req *hdl.Request) error {
+    metadata := *req.Metadata
+    metadata.MsgSender = req.Params["account"].(abihandler.Address).String()
+    metadata.AppContract = relay
+    if err := app.EtherWithdraw(&metadata, map[string]interface{}{"0": req.Params["amount"], "1": []byte{}}); err != nil {
+      return err
+    }
+    if req.Metadata.MsgSender != user.String() || len(req.Params) != 2 || req.Params["0"] != nil {
+      return fmt.Errorf("request changed by the map handler: %s %v", req.Metadata.MsgSender, req.Params)
+    }
+    return nil
+  })
+  result := driver.Advance(user.String(), encode(withdrawForCodec, []interface{}{other, big.NewInt(4)}))
+  if !result.Accepted() {
+    t.Fatalf("withdraw for other: status %s: %v", result.Status, result.Err)
+  }
+  if len(result.Vouchers) != 1 || len(result.Notices) != 1 {
+    t.Errorf("withdraw for other: %d vouchers, %d notices, expected 1 and 1", len(result.Vouchers), len(result.Notices))
+  }
+  if got := balances(app); got != [2]int64{-1, 6} {
+    t.Errorf("balances %v after withdrawing for other, expected [-1 6]", got)
+  }
+
+  // between inputs the map handlers send the outputs to the input the rollup
+  // server is processing
+  driver.Transport.Begin(&rolluptest.Input{Type: rolluptest.AdvanceState})
+  err := app.EtherPortalDeposit(&rollups.Metadata{MsgSender: etherPortal}, map[string]interface{}{"0": user, "1": big.NewInt(5), "2": []byte{}})
+  if err != nil {
+    t.Fatalf("deposit between inputs: %s", err)
+  }
+  if result := driver.Transport.End(nil); len(result.Notices) != 1 {
+    t.Errorf("deposit between inputs: %d notices, expected 1", len(result.Notices))
+  }
+  if got := balances(app); got != [2]int64{5, 6} {
+    t.Errorf("balances %v after a deposit between inputs, expected [5 6]", got)
+  }
+}
+
+// TestBalanceUri checks the untyped address param takes any hex address
+func TestBalanceUri(t *testing.T) {
+  app, driver := newWalletApp()
+  app.SetupRoutes([]wallet.WalletRoute{wallet.BalanceUriInspectRoute})
+  driver.Advance(etherPortal, deposit(user, 10))
+
+  tests := []struct {
+    path string
+    ether string
+  }{
+    {"/balance/" + strings.ToLower(user.String()), `"ether":10`},
+    {"/balance/" + user.String(), `"ether":10`},
+    // a wrong checksum is accepted, like the untyped param always did
+    {"/balance/0xF39fd6e51aad88f6f4ce6ab8827279cfffb92266", `"ether":10`},
+    {"/balance/" + other.String(), `"ether":0`},
+    {"/balance/0x1234", ""},
+  }
+  for _, tt := range tests {
+    result := driver.Inspect(rollups.Str2Hex(tt.path))
+    if tt.ether == "" {
+      if result.Accepted() {
+        t.Errorf("%s: accepted", tt.path)
+      }
+      continue
+    }
+    if !result.Accepted() || len(result.Reports) != 1 {
+      t.Errorf("%s: status %s, %d reports: %v", tt.path, result.Status, len(result.Reports), result.Err)
+      continue
+    }
+    if report, _ := rollups.Hex2Str(result.Reports[0].Payload); !strings.Contains(report, tt.ether) {
+      t.Errorf("%s: report %s, expected %s", tt.path, report, tt.ether)
+    }
+  }
+}
+
+func mustAddress(hex string) abihandler.Address {
+  address, err := abihandler.Hex2Address(hex)
+  if err != nil {
+    panic(err)
+  }
+  return address
+}